@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+const alarm_history_file = "alarm_history.json"
+
+// last seen history entry of every device scope, kept across restarts
+type AlarmHistoryState struct {
+	mutex     sync.Mutex
+	loaded    bool
+	last_seen map[string]string
+}
+
+var alarm_history_state = AlarmHistoryState{last_seen: make(map[string]string)}
+
+func (state *AlarmHistoryState) load() {
+	if state.loaded {
+		return
+	}
+	state.loaded = true
+	file_content, err := os.ReadFile(filepath.Join(plugin_dir, alarm_history_file))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Println("Ошибка чтения файла: ", err)
+		}
+		return
+	}
+	err = json.Unmarshal(file_content, &state.last_seen)
+	if err != nil {
+		logger.Printf("Ошибка чтения %s: %v", alarm_history_file, err)
+	}
+}
+
+func (state *AlarmHistoryState) save() {
+	json_data, err := json.Marshal(state.last_seen)
+	if err != nil {
+		logger.Printf("Ошибка преобразования данных (%s) в json %v", alarm_history_file, err)
+		return
+	}
+	err = os.WriteFile(filepath.Join(plugin_dir, alarm_history_file), json_data, 0644)
+	if err != nil {
+		logger.Println("Ошибка записи json-данных в файл:", err)
+	}
+}
+
+func (state *AlarmHistoryState) LastSeen(scope_key string) string {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	state.load()
+	return state.last_seen[scope_key]
+}
+
+func (state *AlarmHistoryState) SetLastSeen(scope_key string, entry_key string) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	state.load()
+	state.last_seen[scope_key] = entry_key
+	state.save()
+}
+
+// history entries after the last seen one, oldest first
+func ReadAlarmHistory(client CNCClient, scope_key string) ([]CncAlarmHistoryEntry, int16) {
+	result := make([]CncAlarmHistoryEntry, 0)
+	count, ret := client.GetAlarmHistoryCount()
+	if ret != 0 {
+		return result, ret
+	}
+	last_seen := alarm_history_state.LastSeen(scope_key)
+	found := false
+	// entry 1 is the latest alarm
+	for start := int32(1); start <= count && !found; start += alarm_history_batch {
+		end := min(start+alarm_history_batch-1, count)
+		entries, ret := client.GetAlarmHistory(start, end)
+		if ret != 0 {
+			return make([]CncAlarmHistoryEntry, 0), ret
+		}
+		for _, entry := range entries {
+			if entry.Key() == last_seen {
+				found = true
+				break
+			}
+			result = append(result, entry)
+		}
+	}
+	if len(result) == 0 {
+		return result, 0
+	}
+	alarm_history_state.SetLastSeen(scope_key, result[0].Key())
+	slices.Reverse(result)
+	return result, 0
+}
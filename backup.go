@@ -0,0 +1,524 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const backup_dir = "backup"
+const backup_history_file = "history.json"
+const backup_listing_file = "listed.json"
+
+// programs uploaded per collector cycle by default
+const default_backup_uploads = 1
+
+// backup schedule of the device programs
+type BackupConfig struct {
+	// directory check for changed programs
+	IntervalMs int `json:"interval_ms" yaml:"interval_ms"`
+	// upload of all programs, 0 - changed programs only
+	FullIntervalMs int `json:"full_interval_ms" yaml:"full_interval_ms"`
+	// programs uploaded per collector cycle, the rest in the next cycles
+	UploadsPerCycle int `json:"uploads_per_cycle" yaml:"uploads_per_cycle"`
+}
+
+// stored program version, content is objects/<hash>
+type BackupVersion struct {
+	Version  int    `json:"version"`
+	Time     string `json:"time"`
+	Hash     string `json:"hash"`
+	Size     int    `json:"size"`
+	Modified string `json:"modified"`
+}
+
+// versions of the device scope programs
+type BackupArchive struct {
+	dir      string
+	Programs map[string][]BackupVersion `json:"programs"`
+}
+
+// programs as listed at their last upload, kept between connections
+type BackupListing struct {
+	dir      string
+	FullTime time.Time             `json:"full_time"`
+	Programs map[string]CncProgram `json:"programs"`
+}
+
+// backup pass of one device, lives for one connection
+type ProgramBackup struct {
+	device   *Device
+	interval time.Duration
+	full     time.Duration
+	uploads  int
+	last_run time.Time
+	// paths left in the pass, 0 - CNC without paths, the first one is in progress
+	paths []int16
+	scope *BackupScope
+}
+
+// directory listing of the path in progress
+type BackupScope struct {
+	name    string
+	full    bool
+	archive *BackupArchive
+	listing *BackupListing
+	current map[string]CncProgram
+	pending []CncProgram
+}
+
+func GetBackupDir(device_name string, scope string) string {
+	dir := filepath.Join(plugin_dir, backup_dir, device_name)
+	if scope != "" {
+		dir = filepath.Join(dir, GetPathFolderName(scope))
+	}
+	return dir
+}
+
+func LoadBackupArchive(dir string) (*BackupArchive, error) {
+	archive := &BackupArchive{dir: dir, Programs: make(map[string][]BackupVersion)}
+	file_content, err := os.ReadFile(filepath.Join(dir, backup_history_file))
+	if os.IsNotExist(err) {
+		return archive, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(file_content, archive); err != nil {
+		return nil, err
+	}
+	if archive.Programs == nil {
+		archive.Programs = make(map[string][]BackupVersion)
+	}
+	return archive, nil
+}
+
+func (archive *BackupArchive) save() error {
+	json_data, err := json.MarshalIndent(archive, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(archive.dir, backup_history_file), json_data, 0644)
+}
+
+func (archive *BackupArchive) objectPath(hash string) string {
+	return filepath.Join(archive.dir, "objects", hash)
+}
+
+// new version unless the content equals the latest one, true if added
+func (archive *BackupArchive) Add(program CncProgram, content []byte, now time.Time) (bool, error) {
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+	versions := archive.Programs[program.Name]
+	if len(versions) != 0 && versions[len(versions)-1].Hash == hash {
+		return false, nil
+	}
+	object_path := archive.objectPath(hash)
+	if _, err := os.Stat(object_path); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(object_path), 0755); err != nil {
+			return false, err
+		}
+		if err := os.WriteFile(object_path, content, 0644); err != nil {
+			return false, err
+		}
+	}
+	archive.Programs[program.Name] = append(versions, BackupVersion{
+		Version:  len(versions) + 1,
+		Time:     now.Format(time.DateTime),
+		Hash:     hash,
+		Size:     len(content),
+		Modified: program.Modified,
+	})
+	return true, archive.save()
+}
+
+func (archive *BackupArchive) Version(program string, version int) (BackupVersion, bool) {
+	versions := archive.Programs[program]
+	index := slices.IndexFunc(versions, func(item BackupVersion) bool { return item.Version == version })
+	if index < 0 {
+		return BackupVersion{}, false
+	}
+	return versions[index], true
+}
+
+func (archive *BackupArchive) Content(version BackupVersion) ([]byte, error) {
+	return os.ReadFile(archive.objectPath(version.Hash))
+}
+
+func LoadBackupListing(dir string) (*BackupListing, error) {
+	listing := &BackupListing{dir: dir, Programs: make(map[string]CncProgram)}
+	file_content, err := os.ReadFile(filepath.Join(dir, backup_listing_file))
+	if os.IsNotExist(err) {
+		return listing, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(file_content, listing); err != nil {
+		return nil, err
+	}
+	if listing.Programs == nil {
+		listing.Programs = make(map[string]CncProgram)
+	}
+	return listing, nil
+}
+
+func (listing *BackupListing) save() error {
+	json_data, err := json.MarshalIndent(listing, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(listing.dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(listing.dir, backup_listing_file), json_data, 0644)
+}
+
+func NewProgramBackup(device *Device) *ProgramBackup {
+	if device.Backup == nil {
+		return nil
+	}
+	backup := &ProgramBackup{
+		device:   device,
+		interval: time.Duration(device.Backup.IntervalMs) * time.Millisecond,
+		full:     time.Duration(device.Backup.FullIntervalMs) * time.Millisecond,
+		uploads:  device.Backup.UploadsPerCycle,
+	}
+	if backup.interval == 0 {
+		backup.interval = time.Minute
+	}
+	if backup.uploads <= 0 {
+		backup.uploads = default_backup_uploads
+	}
+	return backup
+}
+
+// a pass every interval uploads new and changed programs, all programs on full backup,
+// a few programs per collector cycle
+func (backup *ProgramBackup) Run(client CNCClient, now time.Time) int16 {
+	if backup == nil {
+		return 0
+	}
+	if len(backup.paths) == 0 {
+		if !backup.last_run.IsZero() && now.Sub(backup.last_run) < backup.interval {
+			return 0
+		}
+		backup.last_run = now
+		backup.paths = []int16{0}
+		if len(backup.device.Paths) != 0 {
+			backup.paths = slices.Clone(backup.device.Paths)
+		}
+	}
+	uploads := backup.uploads
+	for len(backup.paths) != 0 && uploads > 0 {
+		path := backup.paths[0]
+		scope := ""
+		if path != 0 {
+			scope = GetPathKey(path)
+			if ret := client.SetPath(path); ret != 0 {
+				backup.stop()
+				return ret
+			}
+		}
+		if backup.scope == nil {
+			backup_scope, ret := backup.listScope(client, scope, now)
+			if backup_scope == nil {
+				backup.stop()
+				return ret
+			}
+			backup.scope = backup_scope
+		}
+		if ret := backup.scope.upload(client, backup.device.ProgramFolder, &uploads, now); ret != 0 {
+			if !IsProtocolError(ret) {
+				backup.stop()
+				return 0
+			}
+			return ret
+		}
+		if len(backup.scope.pending) == 0 {
+			backup.scope.finish(now)
+			backup.scope = nil
+			backup.paths = backup.paths[1:]
+		}
+	}
+	return 0
+}
+
+// the pass is started again after the interval
+func (backup *ProgramBackup) stop() {
+	backup.paths = nil
+	backup.scope = nil
+}
+
+// programs to upload, nil on directory or archive errors
+func (backup *ProgramBackup) listScope(client CNCClient, scope string, now time.Time) (*BackupScope, int16) {
+	scope_name := GetScopeName(backup.device, scope)
+	programs, ret := client.GetProgramDirectory(backup.device.ProgramFolder)
+	if ret != 0 {
+		logger.Printf("Ошибка чтения каталога программ %s, error: %d", scope_name, ret)
+		return nil, ret
+	}
+	dir := GetBackupDir(backup.device.Name, scope)
+	archive, err := LoadBackupArchive(dir)
+	if err != nil {
+		logger.Printf("Ошибка чтения архива программ %s: %v", scope_name, err)
+		return nil, 0
+	}
+	listing, err := LoadBackupListing(dir)
+	if err != nil {
+		logger.Printf("Ошибка чтения списка программ архива %s: %v", scope_name, err)
+		return nil, 0
+	}
+	backup_scope := &BackupScope{
+		name:    scope_name,
+		full:    backup.full != 0 && now.Sub(listing.FullTime) >= backup.full,
+		archive: archive,
+		listing: listing,
+		current: make(map[string]CncProgram),
+	}
+	for _, program := range programs {
+		backup_scope.current[program.Name] = program
+		if old_program, ok := listing.Programs[program.Name]; ok && old_program == program && !backup_scope.full {
+			continue
+		}
+		backup_scope.pending = append(backup_scope.pending, program)
+	}
+	return backup_scope, 0
+}
+
+// pending programs within the uploads left in the cycle, protocol error or 1 on archive errors
+func (backup_scope *BackupScope) upload(client CNCClient, folder string, uploads *int, now time.Time) int16 {
+	for len(backup_scope.pending) != 0 && *uploads > 0 {
+		program := backup_scope.pending[0]
+		backup_scope.pending = backup_scope.pending[1:]
+		*uploads--
+		content, ret := client.UploadProgram(folder + program.Name)
+		if ret != 0 {
+			logger.Printf("Ошибка чтения программы %s %s, error: %d", backup_scope.name, program.Name, ret)
+			if IsProtocolError(ret) {
+				return ret
+			}
+			// uploaded again in the next pass
+			continue
+		}
+		added, err := backup_scope.archive.Add(program, content, now)
+		if err != nil {
+			logger.Printf("Ошибка записи архива программ %s: %v", backup_scope.name, err)
+			return 1
+		}
+		if added {
+			logger.Printf("Сохранена версия %d программы %s: %s", len(backup_scope.archive.Programs[program.Name]), backup_scope.name, program.Name)
+		}
+		backup_scope.listing.Programs[program.Name] = program
+		if err := backup_scope.listing.save(); err != nil {
+			logger.Printf("Ошибка записи списка программ архива %s: %v", backup_scope.name, err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// deleted programs are removed from the listing
+func (backup_scope *BackupScope) finish(now time.Time) {
+	for name := range backup_scope.listing.Programs {
+		if _, ok := backup_scope.current[name]; !ok {
+			delete(backup_scope.listing.Programs, name)
+		}
+	}
+	if backup_scope.full {
+		backup_scope.listing.FullTime = now
+	}
+	if err := backup_scope.listing.save(); err != nil {
+		logger.Printf("Ошибка записи списка программ архива %s: %v", backup_scope.name, err)
+	}
+}
+
+// plugin backup list|restore|diff, exit code
+func RunBackupCommand(args []string) int {
+	usage := func() int {
+		fmt.Fprintln(os.Stderr, "Использование:")
+		fmt.Fprintln(os.Stderr, "  backup list <устройство[/канал]> [программа]")
+		fmt.Fprintln(os.Stderr, "  backup restore <устройство[/канал]> <программа> <версия> [файл]")
+		fmt.Fprintln(os.Stderr, "  backup diff <устройство[/канал]> <программа> <версия> [версия]")
+		return 2
+	}
+	if len(args) < 2 {
+		return usage()
+	}
+	plugin_path, err := os.Executable()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Ошибка при определении пути исполняемого файла:", err)
+		return 1
+	}
+	plugin_dir = filepath.Dir(plugin_path)
+	device_name, scope := CutScope(args[1])
+	archive, err := LoadBackupArchive(GetBackupDir(device_name, scope))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка чтения архива программ %s: %v\n", args[1], err)
+		return 1
+	}
+	switch {
+	case args[0] == "list" && len(args) <= 3:
+		return ListBackup(archive, args[2:])
+	case args[0] == "restore" && (len(args) == 4 || len(args) == 5):
+		return RestoreBackup(archive, args[2], args[3], args[4:])
+	case args[0] == "diff" && (len(args) == 4 || len(args) == 5):
+		return DiffBackup(archive, args[2], args[3], args[4:])
+	}
+	return usage()
+}
+
+// device name and path key of "device/path"
+func CutScope(name string) (string, string) {
+	index := strings.LastIndex(name, "/")
+	if index < 0 {
+		return name, ""
+	}
+	if _, err := strconv.Atoi(name[index+1:]); err != nil {
+		return name, ""
+	}
+	return name[:index], name[index+1:]
+}
+
+func ListBackup(archive *BackupArchive, programs []string) int {
+	if len(programs) == 0 {
+		for _, name := range slices.Sorted(maps.Keys(archive.Programs)) {
+			versions := archive.Programs[name]
+			last := versions[len(versions)-1]
+			fmt.Printf("%s\tверсий: %d\tпоследняя %s\n", name, len(versions), last.Time)
+		}
+		return 0
+	}
+	versions, ok := archive.Programs[programs[0]]
+	if !ok {
+		fmt.Fprintln(os.Stderr, "Программа отсутствует в архиве:", programs[0])
+		return 1
+	}
+	for _, version := range versions {
+		fmt.Printf("%d\t%s\t%d байт\tизменена %s\t%s\n", version.Version, version.Time, version.Size, version.Modified, version.Hash[:12])
+	}
+	return 0
+}
+
+func ReadBackupVersion(archive *BackupArchive, program string, version_text string) ([]byte, bool) {
+	version_number, err := strconv.Atoi(version_text)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Некорректный номер версии:", version_text)
+		return nil, false
+	}
+	version, ok := archive.Version(program, version_number)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Версия %d программы %s отсутствует в архиве\n", version_number, program)
+		return nil, false
+	}
+	content, err := archive.Content(version)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка чтения версии %d программы %s: %v\n", version_number, program, err)
+		return nil, false
+	}
+	return content, true
+}
+
+// version content to the file or stdout
+func RestoreBackup(archive *BackupArchive, program string, version string, file []string) int {
+	content, ok := ReadBackupVersion(archive, program, version)
+	if !ok {
+		return 1
+	}
+	if len(file) == 0 {
+		os.Stdout.Write(content)
+		return 0
+	}
+	if err := os.WriteFile(file[0], content, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка записи файла %s: %v\n", file[0], err)
+		return 1
+	}
+	return 0
+}
+
+// version against another version or the latest one
+func DiffBackup(archive *BackupArchive, program string, version string, other []string) int {
+	old_content, ok := ReadBackupVersion(archive, program, version)
+	if !ok {
+		return 1
+	}
+	new_version := strconv.Itoa(len(archive.Programs[program]))
+	if len(other) != 0 {
+		new_version = other[0]
+	}
+	new_content, ok := ReadBackupVersion(archive, program, new_version)
+	if !ok {
+		return 1
+	}
+	fmt.Printf("--- %s версия %s\n+++ %s версия %s\n", program, version, program, new_version)
+	for _, line := range DiffLines(SplitProgramLines(old_content), SplitProgramLines(new_content)) {
+		fmt.Println(line)
+	}
+	return 0
+}
+
+// lines without CR, the % end mark is a line too
+func SplitProgramLines(content []byte) []string {
+	text := strings.ReplaceAll(string(content), "\r", "")
+	return strings.Split(strings.TrimRight(text, "\n"), "\n")
+}
+
+// lines of the longest common subsequence diff with " ", "-", "+" marks
+func DiffLines(old_lines []string, new_lines []string) []string {
+	var result []string
+	// common head and tail are not compared
+	head := 0
+	for head < len(old_lines) && head < len(new_lines) && old_lines[head] == new_lines[head] {
+		head++
+	}
+	tail := 0
+	for tail < len(old_lines)-head && tail < len(new_lines)-head && old_lines[len(old_lines)-1-tail] == new_lines[len(new_lines)-1-tail] {
+		tail++
+	}
+	old_middle := old_lines[head : len(old_lines)-tail]
+	new_middle := new_lines[head : len(new_lines)-tail]
+	for _, line := range old_lines[max(0, head-3):head] {
+		result = append(result, " "+line)
+	}
+	// lcs[i][j] - common lines of old_middle[i:] and new_middle[j:]
+	lcs := make([][]int32, len(old_middle)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(new_middle)+1)
+	}
+	for i := len(old_middle) - 1; i >= 0; i-- {
+		for j := len(new_middle) - 1; j >= 0; j-- {
+			if old_middle[i] == new_middle[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(old_middle) || j < len(new_middle) {
+		switch {
+		case i < len(old_middle) && j < len(new_middle) && old_middle[i] == new_middle[j]:
+			result = append(result, " "+old_middle[i])
+			i++
+			j++
+		case i < len(old_middle) && (j == len(new_middle) || lcs[i+1][j] >= lcs[i][j+1]):
+			result = append(result, "-"+old_middle[i])
+			i++
+		default:
+			result = append(result, "+"+new_middle[j])
+			j++
+		}
+	}
+	for _, line := range old_lines[len(old_lines)-tail : min(len(old_lines), len(old_lines)-tail+3)] {
+		result = append(result, " "+line)
+	}
+	return result
+}
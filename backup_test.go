@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func UseTestPluginDir(t *testing.T) {
+	t.Helper()
+	saved_dir := plugin_dir
+	plugin_dir = t.TempDir()
+	t.Cleanup(func() { plugin_dir = saved_dir })
+}
+
+func TestProgramBackupUploadsPerCycle(t *testing.T) {
+	UseTestPluginDir(t)
+	fake := NewFakeClient()
+	fake.Connect("", 0, 0)
+	programs := []CncProgram{
+		{Number: 1, Name: "O0001", Modified: "2026-01-01 10:00"},
+		{Number: 2, Name: "O0002", Modified: "2026-01-01 10:00"},
+	}
+	fake.SetValue("GetProgramDirectory", programs)
+	fake.SetValue("UploadProgram", []byte("%\nO0001\nM30\n%"))
+	device := NewTestDevice()
+	device.Backup = &BackupConfig{IntervalMs: 60000}
+	backup := NewProgramBackup(&device)
+	now := time.Now()
+	for cycle := range 3 {
+		if ret := backup.Run(fake, now.Add(time.Duration(cycle)*time.Second)); ret != 0 {
+			t.Fatalf("cycle %d: %d", cycle, ret)
+		}
+	}
+	if count := CountCalls(fake.Calls(), "UploadProgram"); count != 2 {
+		t.Fatalf("uploads %d, expected one per cycle: %v", count, fake.Calls())
+	}
+	if count := CountCalls(fake.Calls(), "GetProgramDirectory"); count != 1 {
+		t.Fatalf("directory read %d times in one pass: %v", count, fake.Calls())
+	}
+	// the listing is kept between connections, only changed programs are uploaded
+	programs[1].Modified = "2026-01-02 10:00"
+	fake = NewFakeClient()
+	fake.Connect("", 0, 0)
+	fake.SetValue("GetProgramDirectory", programs)
+	fake.SetValue("UploadProgram", []byte("%\nO0002\nM30\n%"))
+	backup = NewProgramBackup(&device)
+	for cycle := range 3 {
+		backup.Run(fake, now.Add(time.Minute+time.Duration(cycle)*time.Second))
+	}
+	if count := CountCalls(fake.Calls(), "UploadProgram"); count != 1 {
+		t.Fatalf("uploads after reconnect %d, expected 1: %v", count, fake.Calls())
+	}
+	archive, err := LoadBackupArchive(GetBackupDir(device.Name, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archive.Programs["O0001"]) != 1 || len(archive.Programs["O0002"]) != 2 {
+		t.Fatalf("archive: %v", archive.Programs)
+	}
+}
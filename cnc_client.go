@@ -0,0 +1,404 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var available_protocols = []string{
+	"fwlib",
+	"native",
+	"replay",
+}
+
+// backend factory and connection probe of DataCollector, replaced by FakeClient in tests
+var new_cnc_client = NewCNCClient
+var is_connect_alive = IsConnectAlive
+
+// cnc_statinfo data of the mode and alarm tags
+type CncStatInfo struct {
+	Aut       int16 `json:"aut"`
+	Run       int16 `json:"run"`
+	Edit      int16 `json:"edit"`
+	Mstb      int16 `json:"mstb"`
+	Motion    int16 `json:"motion"`
+	Emergency int16 `json:"emergency"`
+	Alarm     int16 `json:"alarm"`
+}
+
+// alarm of cnc_rdalmmsg2
+type CncAlarmMessage struct {
+	Number   int32  `json:"number"`
+	Type     int16  `json:"type"`
+	TypeName string `json:"type_name"`
+	Axis     int16  `json:"axis"`
+	Message  string `json:"message"`
+}
+
+const max_alarm_messages = 10
+
+var alarm_type_names = map[int16]string{
+	0: "SW", 1: "PW", 2: "IO", 3: "PS", 4: "OT", 5: "OH", 6: "SV", 7: "SR",
+	8: "MC", 9: "SP", 10: "DS", 11: "IE", 12: "BG", 13: "SN", 15: "EX", 19: "PC",
+}
+
+// entry of cnc_rdalmhistry with the CNC time of the alarm
+type CncAlarmHistoryEntry struct {
+	Time     string `json:"time"`
+	Number   int32  `json:"number"`
+	Type     int16  `json:"type"`
+	TypeName string `json:"type_name"`
+	Axis     int16  `json:"axis"`
+	Message  string `json:"message"`
+}
+
+// entries per cnc_rdalmhistry call
+const alarm_history_batch = 10
+
+// message of cnc_rdopmsg3, text in the CNC encoding
+type CncOperatorMessage struct {
+	Number int16  `json:"number"`
+	Type   int16  `json:"type"`
+	Data   []byte `json:"data"`
+}
+
+// operator_messages tag item
+type OperatorMessage struct {
+	Number  int16  `json:"number"`
+	Type    int16  `json:"type"`
+	Message string `json:"message"`
+}
+
+// one message of every type
+const max_operator_messages = 5
+
+// executing program of cnc_rdexecprog, executed is the number of blocks before the executing one
+type CncExecBlocks struct {
+	Text     string `json:"text"`
+	Executed int16  `json:"executed"`
+}
+
+// timer of cnc_rdtimer, msec is below a minute
+type CncTimer struct {
+	Minute int32 `json:"minute"`
+	Msec   int32 `json:"msec"`
+}
+
+// tool offset system of cnc_rdtofsinfo, type is M series memory A, B, C
+type CncToolOffsetInfo struct {
+	MtType string `json:"mt_type"`
+	Type   int16  `json:"type"`
+	Count  int16  `json:"count"`
+}
+
+// tool life management of cnc_rdtlinfo and cnc_rdtlusegrp
+type CncToolLifeInfo struct {
+	MaxGroups int32 `json:"max_groups"`
+	MaxTools  int32 `json:"max_tools"`
+	UseGroup  int32 `json:"use_group"`
+	NextGroup int32 `json:"next_group"`
+}
+
+// tool group of cnc_rdtlgrp, count type 0 - cycles, 1 - minutes
+type CncToolLifeGroup struct {
+	Group      int32 `json:"group"`
+	ToolCount  int32 `json:"tool_count"`
+	Life       int32 `json:"life"`
+	Count      int32 `json:"count"`
+	UseTool    int32 `json:"use_tool"`
+	RestSignal bool  `json:"rest_signal"`
+	CountType  int16 `json:"count_type"`
+}
+
+// tool of cnc_rdtltool, info 0 - unused, 1 - in use, 2 - expired, 3 - skipped
+type CncToolLifeTool struct {
+	Number int32 `json:"number"`
+	Info   int32 `json:"info"`
+}
+
+// parameter or diagnosis data, real value is value / 10^decimal
+type CncDataValue struct {
+	Value   int32 `json:"value"`
+	Decimal int32 `json:"decimal"`
+}
+
+// custom macro variable, value is mantissa / 10^exponent
+type CncMacroValue struct {
+	Mantissa int32 `json:"mantissa"`
+	Exponent int16 `json:"exponent"`
+}
+
+// vacant (#0) variable is 0 with exponent -1
+func (value CncMacroValue) IsVacant() bool {
+	return value.Mantissa == 0 && value.Exponent == -1
+}
+
+func (value CncMacroValue) Float() float64 {
+	return float64(value.Mantissa) / math.Pow10(int(value.Exponent))
+}
+
+// the shortest exponent keeping the value in the mantissa
+func NewMacroValue(value float64) CncMacroValue {
+	exponent := 0
+	for exponent < 8 && math.Abs(value*math.Pow10(exponent+1)) < math.MaxInt32 && value*math.Pow10(exponent) != math.Round(value*math.Pow10(exponent)) {
+		exponent++
+	}
+	mantissa := max(min(math.Round(value*math.Pow10(exponent)), math.MaxInt32), math.MinInt32)
+	return CncMacroValue{Mantissa: int32(mantissa), Exponent: int16(exponent)}
+}
+
+// value of cnc_rdmacror2, vacant variable of the IEEE double version is NaN
+func NewMacroDouble(value float64) CncMacroValue {
+	if math.IsNaN(value) {
+		return CncMacroValue{Exponent: -1}
+	}
+	return NewMacroValue(value)
+}
+
+type CNCClient interface {
+	Connect(address string, port int, timeout int) int16
+	Free() int16
+	Handle() uint16
+	// Path functions
+	GetMaxPath() (int16, int16)
+	SetPath(path int16) int16
+	// Name functions
+	GetAxisNames() ([]string, int16)
+	GetSpindleNames() ([]string, int16)
+	// Read plan functions, one FOCAS call shared by several tags
+	GetStatInfo() (CncStatInfo, int16)
+	GetExecProgram() (string, int16)
+	GetExecBlocks() (CncExecBlocks, int16)
+	GetBlockCount() (int64, int16)
+	GetTimerParams(numbers []int32) (map[int32]int64, int16)
+	// Mode functions
+	GetAut() (int16, int16)
+	GetRun() (int16, int16)
+	GetEdit() (int16, int16)
+	GetMstb() (int16, int16)
+	GetMotion() (int16, int16)
+	GetG00() (int16, int16)
+	GetGCodes() ([]CncGCode, int16)
+	GetModalCodes() (map[string]int32, int16)
+	GetShutdowns() (int16, int16)
+	GetLoadExcess() (int16, int16)
+	// Program functions
+	GetMainProgNum() (int32, int16)
+	GetSubProgNum() (int32, int16)
+	GetProgramPath() (string, int16)
+	GetProgramDirectory(folder string) ([]CncProgram, int16)
+	UploadProgram(path string) ([]byte, int16)
+	GetFrameNumber() (int64, int16)
+	GetFrame() (string, int16)
+	GetPartsCount() (int64, int16)
+	GetToolNumber() (int64, int16)
+	// Tool offset functions
+	GetToolOffsetInfo() (CncToolOffsetInfo, int16)
+	GetToolOffsets(offset_type int16, start int16, end int16) ([]int32, int16)
+	// Tool life functions
+	GetToolLifeInfo() (CncToolLifeInfo, int16)
+	GetToolLifeGroups(start int32, count int16) ([]CncToolLifeGroup, int16)
+	GetToolLifeTools(group int32, count int16) ([]CncToolLifeTool, int16)
+	// Work offset functions
+	GetWorkOffsetCount() (int16, int16)
+	GetWorkOffsets(start int16, end int16, axes int16) ([][]int32, int16)
+	GetWorkShift(axes int16) ([]int32, int16)
+	// Axis functions
+	GetAbsolutePositions() (map[string]float64, int16)
+	GetRelativePositions() (map[string]float64, int16)
+	GetMachinePositions() (map[string]float64, int16)
+	GetDistanceToGo() (map[string]float64, int16)
+	GetCommandedPositions() (map[string]float64, int16)
+	GetFeedRate() (float64, int16)
+	GetFeedRateParam1() (map[string]float64, int16)
+	GetFeedRateParam2() (map[string]float64, int16)
+	GetFeedOverride() (int16, int16)
+	GetJogOverride() (int16, int16)
+	GetJogSpeed() (map[string]float64, int16)
+	GetServoLoad() (map[string]int64, int16)
+	GetServoCurrentLoad() (map[string]float64, int16)
+	GetServoCurrentLoadPercent() (map[string]int64, int16)
+	// Spindle functions
+	GetSpindleSpeed() (float64, int16)
+	GetSpindleSpeedParam() (map[string]int64, int16)
+	GetSpindleMotorSpeed() (map[string]int64, int16)
+	GetSpindleLoad() (map[string]int64, int16)
+	GetSpindleAlarms() ([]int16, int16)
+	GetSpindleOverride() (int16, int16)
+	// Alarm functions
+	GetEmergency() (int16, int16)
+	GetAlarm() (int16, int16)
+	GetAlarmMessages() ([]CncAlarmMessage, int16)
+	GetAlarmHistoryCount() (int32, int16)
+	GetAlarmHistory(start int32, end int32) ([]CncAlarmHistoryEntry, int16)
+	GetOperatorMessages() ([]CncOperatorMessage, int16)
+	// Operating functions
+	GetPowerOnTime() (int64, int16)
+	GetOperationTime() (float64, int16)
+	GetCuttingTime() (float64, int16)
+	GetCycleTime() (float64, int16)
+	GetTimer(timer_type int16) (CncTimer, int16)
+	GetSeriesNumber() (string, int16)
+	GetVersionNumber() (string, int16)
+	GetCtrlAxesNumber() (int16, int16)
+	GetCtrlSpindlesNumber() (int16, int16)
+	GetCtrlPathsNumber() (int16, int16)
+	GetSerialNumber() (int64, int16)
+	GetCncId() (string, int16)
+	// PMC functions
+	ReadPmcRange(area int16, start uint16, end uint16) ([]byte, int16)
+	ReadMacroRange(start int32, end int32) ([]CncMacroValue, int16)
+	// Parameter and diagnosis functions
+	ReadParameter(number int32, axis int16, size int16) (CncDataValue, int16)
+	ReadDiagnosis(number int32, axis int16, size int16) (CncDataValue, int16)
+}
+
+func GetDeviceProtocol(device *Device) string {
+	if device.Simulate {
+		return "native"
+	}
+	if device.Protocol != "" {
+		return device.Protocol
+	}
+	if fwlib_available {
+		return "fwlib"
+	}
+	return "native"
+}
+
+func NewCNCClient(device *Device) CNCClient {
+	var client CNCClient
+	switch GetDeviceProtocol(device) {
+	case "native":
+		client = NewNativeClient()
+		if device.Simulate {
+			client = NewSimulatorClient()
+		}
+	case "replay":
+		return NewReplayClient(device)
+	default:
+		client = NewFwlibClient()
+	}
+	if device.Record {
+		return NewRecordingClient(client, GetRecordPath(device))
+	}
+	return client
+}
+
+func IsDeviceAlive(device *Device, client CNCClient, running *bool) bool {
+	if prober, ok := client.(interface{ Alive() bool }); ok {
+		return prober.Alive()
+	}
+	return is_connect_alive(device.Address, device.Port, 10*time.Second, running)
+}
+
+// Decode functions shared by the backends
+func ParseShutdowns(program string) int16 {
+	commands := []string{"M00", "M01", "G04"}
+	splitted_str := strings.Split(program, "\n")
+	for index := range commands {
+		for _, part := range splitted_str {
+			if strings.Contains(part, commands[index]) {
+				return int16(index)
+			}
+		}
+	}
+	return 3
+}
+
+func ParseFrame(program string, frame_number int64, frame_number_error int16) string {
+	splitted_str := strings.Split(program, "\n")
+	find_part := "N"
+	if frame_number_error == 0 && frame_number > 0 {
+		find_part = fmt.Sprintf("N%d", frame_number)
+	}
+	for _, check_str := range splitted_str {
+		if strings.Contains(check_str, find_part) {
+			return check_str
+		}
+	}
+	return splitted_str[0]
+}
+
+func GetLoadExcessState(servo_loads []float64, spindle_loads []float64) int16 {
+	result := int16(0)
+	for _, value := range servo_loads {
+		if value > 100 {
+			result = 1
+			break
+		}
+	}
+	for _, value := range spindle_loads {
+		if value > 100 {
+			if result == 0 {
+				return 2
+			}
+			return 3
+		}
+	}
+	return 0
+}
+
+func JoinTimeParams(ms_value int64, min_value int64) float64 {
+	return float64(min_value)*60 + float64(ms_value)/1000.0
+}
+
+func GetAlarmTypeName(alarm_type int16) string {
+	if name, ok := alarm_type_names[alarm_type]; ok {
+		return name
+	}
+	return strconv.Itoa(int(alarm_type))
+}
+
+func NewAlarmMessage(number int32, alarm_type int16, axis int16, message string) CncAlarmMessage {
+	return CncAlarmMessage{
+		Number:   number,
+		Type:     alarm_type,
+		TypeName: GetAlarmTypeName(alarm_type),
+		Axis:     axis,
+		Message:  strings.TrimSpace(message),
+	}
+}
+
+// alarm with type and number like PS0010
+func (alarm CncAlarmMessage) Code() string {
+	return fmt.Sprintf("%s%04d", alarm.TypeName, alarm.Number)
+}
+
+// year is 2 digits in the CNC history
+func NewAlarmHistoryEntry(year, month, day, hour, minute, second int, number int32, alarm_type int16, axis int16, message string) CncAlarmHistoryEntry {
+	alarm_time := time.Date(2000+year, time.Month(month), day, hour, minute, second, 0, time.Local)
+	alarm := NewAlarmMessage(number, alarm_type, axis, message)
+	return CncAlarmHistoryEntry{
+		Time:     alarm_time.Format(time.DateTime),
+		Number:   alarm.Number,
+		Type:     alarm.Type,
+		TypeName: alarm.TypeName,
+		Axis:     alarm.Axis,
+		Message:  alarm.Message,
+	}
+}
+
+// identity of the entry, history numbers shift with every new alarm
+func (entry CncAlarmHistoryEntry) Key() string {
+	return fmt.Sprintf("%s %s%04d/%d", entry.Time, entry.TypeName, entry.Number, entry.Axis)
+}
+
+func FormatCncId(cnc_ids [4]uint32) string {
+	return fmt.Sprintf("%08X-%08X-%08X-%08X", cnc_ids[0], cnc_ids[1], cnc_ids[2], cnc_ids[3])
+}
+
+// axis or spindle name with suffixes, same as the keys of axis data maps
+func FormatAxisName(chars ...byte) string {
+	var name []byte
+	for _, char := range chars {
+		if char == 0 || char == ' ' {
+			break
+		}
+		name = append(name, char)
+	}
+	return string(name)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"maps"
+	"math"
+)
+
+// data size of cnc_rdparam and cnc_diagnoss types
+var cnc_data_types = map[string]int16{
+	"bit":    1,
+	"byte":   1,
+	"word":   2,
+	"2-word": 4,
+	"real":   8,
+}
+
+// parameter or diagnosis number, axis 0 - not an axis data
+type CncDataItem struct {
+	Number int32  `json:"number" yaml:"number"`
+	Axis   int16  `json:"axis" yaml:"axis"`
+	Type   string `json:"type" yaml:"type"`
+	Bit    int16  `json:"bit" yaml:"bit"`
+}
+
+func (item CncDataItem) Size() int16 {
+	return cnc_data_types[item.Type]
+}
+
+// OPC UA type of the item value
+func (item CncDataItem) TagType() string {
+	switch item.Type {
+	case "bit":
+		return "bool"
+	case "byte", "word":
+		return "int16"
+	case "2-word":
+		return "int32"
+	case "real":
+		return "float64"
+	}
+	return ""
+}
+
+// byte is unsigned, word and 2-word are signed
+func GetCncDataValue(item CncDataItem, value CncDataValue) any {
+	switch item.Type {
+	case "bit":
+		return uint8(value.Value)>>item.Bit&1 == 1
+	case "byte":
+		return int16(uint8(value.Value))
+	case "word":
+		return int16(value.Value)
+	case "2-word":
+		return value.Value
+	case "real":
+		return float64(value.Value) / math.Pow10(int(value.Decimal))
+	}
+	return nil
+}
+
+// items of the tags pack with device overrides, type defaults to 2-word
+func MergeCncDataItems(pack_items map[string]CncDataItem, device_items map[string]CncDataItem) map[string]CncDataItem {
+	items := maps.Clone(pack_items)
+	if items == nil {
+		items = make(map[string]CncDataItem)
+	}
+	maps.Copy(items, device_items)
+	for name, item := range items {
+		if item.Type == "" {
+			item.Type = "2-word"
+			items[name] = item
+		}
+	}
+	return items
+}
+
+func GetParameterItems(device *Device) map[string]CncDataItem {
+	return MergeCncDataItems(config.Server.Parameters[device.TagsPackName], device.Parameters)
+}
+
+func GetDiagnosticItems(device *Device) map[string]CncDataItem {
+	return MergeCncDataItems(config.Server.Diagnostics[device.TagsPackName], device.Diagnostics)
+}
+
+// items with errors (number or axis not supported by the CNC) are null
+func ReadCncData(items map[string]CncDataItem, read func(number int32, axis int16, size int16) (CncDataValue, int16)) (map[string]any, int16) {
+	result := make(map[string]any)
+	for name, item := range items {
+		value, ret := read(item.Number, item.Axis, item.Size())
+		if IsProtocolError(ret) {
+			return make(map[string]any), ret
+		}
+		if ret != 0 {
+			result[name] = nil
+			continue
+		}
+		result[name] = GetCncDataValue(item, value)
+	}
+	return result, 0
+}
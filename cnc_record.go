@@ -0,0 +1,1016 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+type CallRecord struct {
+	Time     time.Time       `json:"time"`
+	Method   string          `json:"method"`
+	Args     json.RawMessage `json:"args,omitempty"`
+	Error    int16           `json:"error"`
+	Value    json.RawMessage `json:"value,omitempty"`
+	Duration int64           `json:"duration_us"`
+}
+
+func GetRecordPath(device *Device) string {
+	record_path := device.RecordFile
+	if record_path == "" {
+		file_name := strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(device.Name) + ".jsonl"
+		record_path = filepath.Join("records", file_name)
+	}
+	if !filepath.IsAbs(record_path) {
+		record_path = filepath.Join(plugin_dir, record_path)
+	}
+	return record_path
+}
+
+func GetCallKey(method string, args json.RawMessage) string {
+	// connect arguments belong to the replaying device
+	if method == "Connect" {
+		return method
+	}
+	return method + string(args)
+}
+
+func MarshalArgs(args []any) json.RawMessage {
+	if len(args) == 0 {
+		return nil
+	}
+	json_data, err := json.Marshal(args)
+	if err != nil {
+		return nil
+	}
+	return json_data
+}
+
+// Recording backend, writes every call of the wrapped client to a jsonl file
+type RecordingClient struct {
+	client      CNCClient
+	record_path string
+	file        *os.File
+	mutex       sync.Mutex
+}
+
+func NewRecordingClient(client CNCClient, record_path string) CNCClient {
+	return &RecordingClient{client: client, record_path: record_path}
+}
+
+func (recorder *RecordingClient) open() {
+	if recorder.file != nil {
+		return
+	}
+	err := os.MkdirAll(filepath.Dir(recorder.record_path), os.ModePerm)
+	if err != nil {
+		logger.Println("Ошибка создания каталога записи:", err)
+		return
+	}
+	recorder.file, err = os.OpenFile(recorder.record_path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		logger.Println("Ошибка открытия файла записи:", err)
+	}
+}
+
+func (recorder *RecordingClient) close() {
+	if recorder.file != nil {
+		recorder.file.Close()
+		recorder.file = nil
+	}
+}
+
+func (recorder *RecordingClient) write(record CallRecord) {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	recorder.open()
+	if recorder.file == nil {
+		return
+	}
+	json_data, err := json.Marshal(record)
+	if err != nil {
+		logger.Println("Ошибка преобразования записи в json:", err)
+		return
+	}
+	if _, err = recorder.file.Write(append(json_data, '\n')); err != nil {
+		logger.Println("Ошибка записи файла записи:", err)
+	}
+}
+
+func Record[T any](recorder *RecordingClient, method string, args []any, call func() (T, int16)) (T, int16) {
+	start := time.Now()
+	result, ret := call()
+	record := CallRecord{
+		Time:     start,
+		Method:   method,
+		Args:     MarshalArgs(args),
+		Error:    ret,
+		Duration: time.Since(start).Microseconds(),
+	}
+	if ret == EW_OK {
+		record.Value, _ = json.Marshal(result)
+	}
+	recorder.write(record)
+	return result, ret
+}
+
+func (recorder *RecordingClient) Connect(address string, port int, timeout int) int16 {
+	_, ret := Record(recorder, "Connect", []any{address, port, timeout}, func() (any, int16) {
+		return nil, recorder.client.Connect(address, port, timeout)
+	})
+	if ret != EW_OK {
+		recorder.mutex.Lock()
+		recorder.close()
+		recorder.mutex.Unlock()
+	}
+	return ret
+}
+
+func (recorder *RecordingClient) Free() int16 {
+	_, ret := Record(recorder, "Free", nil, func() (any, int16) {
+		return nil, recorder.client.Free()
+	})
+	recorder.mutex.Lock()
+	recorder.close()
+	recorder.mutex.Unlock()
+	return ret
+}
+
+func (recorder *RecordingClient) Handle() uint16 {
+	return recorder.client.Handle()
+}
+
+func (recorder *RecordingClient) GetMaxPath() (int16, int16) {
+	return Record(recorder, "GetMaxPath", nil, recorder.client.GetMaxPath)
+}
+
+func (recorder *RecordingClient) SetPath(path int16) int16 {
+	_, ret := Record(recorder, "SetPath", []any{path}, func() (any, int16) {
+		return nil, recorder.client.SetPath(path)
+	})
+	return ret
+}
+
+func (recorder *RecordingClient) GetAxisNames() ([]string, int16) {
+	return Record(recorder, "GetAxisNames", nil, recorder.client.GetAxisNames)
+}
+
+func (recorder *RecordingClient) GetSpindleNames() ([]string, int16) {
+	return Record(recorder, "GetSpindleNames", nil, recorder.client.GetSpindleNames)
+}
+
+func (recorder *RecordingClient) GetStatInfo() (CncStatInfo, int16) {
+	return Record(recorder, "GetStatInfo", nil, recorder.client.GetStatInfo)
+}
+
+func (recorder *RecordingClient) GetExecProgram() (string, int16) {
+	return Record(recorder, "GetExecProgram", nil, recorder.client.GetExecProgram)
+}
+
+func (recorder *RecordingClient) GetExecBlocks() (CncExecBlocks, int16) {
+	return Record(recorder, "GetExecBlocks", nil, recorder.client.GetExecBlocks)
+}
+
+func (recorder *RecordingClient) GetBlockCount() (int64, int16) {
+	return Record(recorder, "GetBlockCount", nil, recorder.client.GetBlockCount)
+}
+
+func (recorder *RecordingClient) GetTimerParams(numbers []int32) (map[int32]int64, int16) {
+	return Record(recorder, "GetTimerParams", []any{numbers}, func() (map[int32]int64, int16) {
+		return recorder.client.GetTimerParams(numbers)
+	})
+}
+
+func (recorder *RecordingClient) GetAut() (int16, int16) {
+	return Record(recorder, "GetAut", nil, recorder.client.GetAut)
+}
+
+func (recorder *RecordingClient) GetRun() (int16, int16) {
+	return Record(recorder, "GetRun", nil, recorder.client.GetRun)
+}
+
+func (recorder *RecordingClient) GetEdit() (int16, int16) {
+	return Record(recorder, "GetEdit", nil, recorder.client.GetEdit)
+}
+
+func (recorder *RecordingClient) GetMstb() (int16, int16) {
+	return Record(recorder, "GetMstb", nil, recorder.client.GetMstb)
+}
+
+func (recorder *RecordingClient) GetMotion() (int16, int16) {
+	return Record(recorder, "GetMotion", nil, recorder.client.GetMotion)
+}
+
+func (recorder *RecordingClient) GetG00() (int16, int16) {
+	return Record(recorder, "GetG00", nil, recorder.client.GetG00)
+}
+
+func (recorder *RecordingClient) GetGCodes() ([]CncGCode, int16) {
+	return Record(recorder, "GetGCodes", nil, recorder.client.GetGCodes)
+}
+
+func (recorder *RecordingClient) GetModalCodes() (map[string]int32, int16) {
+	return Record(recorder, "GetModalCodes", nil, recorder.client.GetModalCodes)
+}
+
+func (recorder *RecordingClient) GetShutdowns() (int16, int16) {
+	return Record(recorder, "GetShutdowns", nil, recorder.client.GetShutdowns)
+}
+
+func (recorder *RecordingClient) GetLoadExcess() (int16, int16) {
+	return Record(recorder, "GetLoadExcess", nil, recorder.client.GetLoadExcess)
+}
+
+func (recorder *RecordingClient) GetMainProgNum() (int32, int16) {
+	return Record(recorder, "GetMainProgNum", nil, recorder.client.GetMainProgNum)
+}
+
+func (recorder *RecordingClient) GetSubProgNum() (int32, int16) {
+	return Record(recorder, "GetSubProgNum", nil, recorder.client.GetSubProgNum)
+}
+
+func (recorder *RecordingClient) GetProgramPath() (string, int16) {
+	return Record(recorder, "GetProgramPath", nil, recorder.client.GetProgramPath)
+}
+
+func (recorder *RecordingClient) GetProgramDirectory(folder string) ([]CncProgram, int16) {
+	return Record(recorder, "GetProgramDirectory", []any{folder}, func() ([]CncProgram, int16) {
+		return recorder.client.GetProgramDirectory(folder)
+	})
+}
+
+func (recorder *RecordingClient) UploadProgram(path string) ([]byte, int16) {
+	return Record(recorder, "UploadProgram", []any{path}, func() ([]byte, int16) {
+		return recorder.client.UploadProgram(path)
+	})
+}
+
+func (recorder *RecordingClient) GetFrameNumber() (int64, int16) {
+	return Record(recorder, "GetFrameNumber", nil, recorder.client.GetFrameNumber)
+}
+
+func (recorder *RecordingClient) GetFrame() (string, int16) {
+	return Record(recorder, "GetFrame", nil, recorder.client.GetFrame)
+}
+
+func (recorder *RecordingClient) GetPartsCount() (int64, int16) {
+	return Record(recorder, "GetPartsCount", nil, recorder.client.GetPartsCount)
+}
+
+func (recorder *RecordingClient) GetToolNumber() (int64, int16) {
+	return Record(recorder, "GetToolNumber", nil, recorder.client.GetToolNumber)
+}
+
+func (recorder *RecordingClient) GetToolOffsetInfo() (CncToolOffsetInfo, int16) {
+	return Record(recorder, "GetToolOffsetInfo", nil, recorder.client.GetToolOffsetInfo)
+}
+
+func (recorder *RecordingClient) GetToolOffsets(offset_type int16, start int16, end int16) ([]int32, int16) {
+	return Record(recorder, "GetToolOffsets", []any{offset_type, start, end}, func() ([]int32, int16) {
+		return recorder.client.GetToolOffsets(offset_type, start, end)
+	})
+}
+
+func (recorder *RecordingClient) GetToolLifeInfo() (CncToolLifeInfo, int16) {
+	return Record(recorder, "GetToolLifeInfo", nil, recorder.client.GetToolLifeInfo)
+}
+
+func (recorder *RecordingClient) GetToolLifeGroups(start int32, count int16) ([]CncToolLifeGroup, int16) {
+	return Record(recorder, "GetToolLifeGroups", []any{start, count}, func() ([]CncToolLifeGroup, int16) {
+		return recorder.client.GetToolLifeGroups(start, count)
+	})
+}
+
+func (recorder *RecordingClient) GetToolLifeTools(group int32, count int16) ([]CncToolLifeTool, int16) {
+	return Record(recorder, "GetToolLifeTools", []any{group, count}, func() ([]CncToolLifeTool, int16) {
+		return recorder.client.GetToolLifeTools(group, count)
+	})
+}
+
+func (recorder *RecordingClient) GetWorkOffsetCount() (int16, int16) {
+	return Record(recorder, "GetWorkOffsetCount", nil, recorder.client.GetWorkOffsetCount)
+}
+
+func (recorder *RecordingClient) GetWorkOffsets(start int16, end int16, axes int16) ([][]int32, int16) {
+	return Record(recorder, "GetWorkOffsets", []any{start, end, axes}, func() ([][]int32, int16) {
+		return recorder.client.GetWorkOffsets(start, end, axes)
+	})
+}
+
+func (recorder *RecordingClient) GetWorkShift(axes int16) ([]int32, int16) {
+	return Record(recorder, "GetWorkShift", []any{axes}, func() ([]int32, int16) {
+		return recorder.client.GetWorkShift(axes)
+	})
+}
+
+func (recorder *RecordingClient) GetAbsolutePositions() (map[string]float64, int16) {
+	return Record(recorder, "GetAbsolutePositions", nil, recorder.client.GetAbsolutePositions)
+}
+
+func (recorder *RecordingClient) GetRelativePositions() (map[string]float64, int16) {
+	return Record(recorder, "GetRelativePositions", nil, recorder.client.GetRelativePositions)
+}
+
+func (recorder *RecordingClient) GetMachinePositions() (map[string]float64, int16) {
+	return Record(recorder, "GetMachinePositions", nil, recorder.client.GetMachinePositions)
+}
+
+func (recorder *RecordingClient) GetDistanceToGo() (map[string]float64, int16) {
+	return Record(recorder, "GetDistanceToGo", nil, recorder.client.GetDistanceToGo)
+}
+
+func (recorder *RecordingClient) GetCommandedPositions() (map[string]float64, int16) {
+	return Record(recorder, "GetCommandedPositions", nil, recorder.client.GetCommandedPositions)
+}
+
+func (recorder *RecordingClient) GetFeedRate() (float64, int16) {
+	return Record(recorder, "GetFeedRate", nil, recorder.client.GetFeedRate)
+}
+
+func (recorder *RecordingClient) GetFeedRateParam1() (map[string]float64, int16) {
+	return Record(recorder, "GetFeedRateParam1", nil, recorder.client.GetFeedRateParam1)
+}
+
+func (recorder *RecordingClient) GetFeedRateParam2() (map[string]float64, int16) {
+	return Record(recorder, "GetFeedRateParam2", nil, recorder.client.GetFeedRateParam2)
+}
+
+func (recorder *RecordingClient) GetFeedOverride() (int16, int16) {
+	return Record(recorder, "GetFeedOverride", nil, recorder.client.GetFeedOverride)
+}
+
+func (recorder *RecordingClient) GetJogOverride() (int16, int16) {
+	return Record(recorder, "GetJogOverride", nil, recorder.client.GetJogOverride)
+}
+
+func (recorder *RecordingClient) GetJogSpeed() (map[string]float64, int16) {
+	return Record(recorder, "GetJogSpeed", nil, recorder.client.GetJogSpeed)
+}
+
+func (recorder *RecordingClient) GetServoLoad() (map[string]int64, int16) {
+	return Record(recorder, "GetServoLoad", nil, recorder.client.GetServoLoad)
+}
+
+func (recorder *RecordingClient) GetServoCurrentLoad() (map[string]float64, int16) {
+	return Record(recorder, "GetServoCurrentLoad", nil, recorder.client.GetServoCurrentLoad)
+}
+
+func (recorder *RecordingClient) GetServoCurrentLoadPercent() (map[string]int64, int16) {
+	return Record(recorder, "GetServoCurrentLoadPercent", nil, recorder.client.GetServoCurrentLoadPercent)
+}
+
+func (recorder *RecordingClient) GetSpindleSpeed() (float64, int16) {
+	return Record(recorder, "GetSpindleSpeed", nil, recorder.client.GetSpindleSpeed)
+}
+
+func (recorder *RecordingClient) GetSpindleSpeedParam() (map[string]int64, int16) {
+	return Record(recorder, "GetSpindleSpeedParam", nil, recorder.client.GetSpindleSpeedParam)
+}
+
+func (recorder *RecordingClient) GetSpindleMotorSpeed() (map[string]int64, int16) {
+	return Record(recorder, "GetSpindleMotorSpeed", nil, recorder.client.GetSpindleMotorSpeed)
+}
+
+func (recorder *RecordingClient) GetSpindleLoad() (map[string]int64, int16) {
+	return Record(recorder, "GetSpindleLoad", nil, recorder.client.GetSpindleLoad)
+}
+
+func (recorder *RecordingClient) GetSpindleAlarms() ([]int16, int16) {
+	return Record(recorder, "GetSpindleAlarms", nil, recorder.client.GetSpindleAlarms)
+}
+
+func (recorder *RecordingClient) GetSpindleOverride() (int16, int16) {
+	return Record(recorder, "GetSpindleOverride", nil, recorder.client.GetSpindleOverride)
+}
+
+func (recorder *RecordingClient) GetEmergency() (int16, int16) {
+	return Record(recorder, "GetEmergency", nil, recorder.client.GetEmergency)
+}
+
+func (recorder *RecordingClient) GetAlarm() (int16, int16) {
+	return Record(recorder, "GetAlarm", nil, recorder.client.GetAlarm)
+}
+
+func (recorder *RecordingClient) GetAlarmMessages() ([]CncAlarmMessage, int16) {
+	return Record(recorder, "GetAlarmMessages", nil, recorder.client.GetAlarmMessages)
+}
+
+func (recorder *RecordingClient) GetAlarmHistoryCount() (int32, int16) {
+	return Record(recorder, "GetAlarmHistoryCount", nil, recorder.client.GetAlarmHistoryCount)
+}
+
+func (recorder *RecordingClient) GetAlarmHistory(start int32, end int32) ([]CncAlarmHistoryEntry, int16) {
+	return Record(recorder, "GetAlarmHistory", []any{start, end}, func() ([]CncAlarmHistoryEntry, int16) {
+		return recorder.client.GetAlarmHistory(start, end)
+	})
+}
+
+func (recorder *RecordingClient) GetOperatorMessages() ([]CncOperatorMessage, int16) {
+	return Record(recorder, "GetOperatorMessages", nil, recorder.client.GetOperatorMessages)
+}
+
+func (recorder *RecordingClient) GetPowerOnTime() (int64, int16) {
+	return Record(recorder, "GetPowerOnTime", nil, recorder.client.GetPowerOnTime)
+}
+
+func (recorder *RecordingClient) GetOperationTime() (float64, int16) {
+	return Record(recorder, "GetOperationTime", nil, recorder.client.GetOperationTime)
+}
+
+func (recorder *RecordingClient) GetCuttingTime() (float64, int16) {
+	return Record(recorder, "GetCuttingTime", nil, recorder.client.GetCuttingTime)
+}
+
+func (recorder *RecordingClient) GetCycleTime() (float64, int16) {
+	return Record(recorder, "GetCycleTime", nil, recorder.client.GetCycleTime)
+}
+
+func (recorder *RecordingClient) GetTimer(timer_type int16) (CncTimer, int16) {
+	return Record(recorder, "GetTimer", []any{timer_type}, func() (CncTimer, int16) {
+		return recorder.client.GetTimer(timer_type)
+	})
+}
+
+func (recorder *RecordingClient) GetSeriesNumber() (string, int16) {
+	return Record(recorder, "GetSeriesNumber", nil, recorder.client.GetSeriesNumber)
+}
+
+func (recorder *RecordingClient) GetVersionNumber() (string, int16) {
+	return Record(recorder, "GetVersionNumber", nil, recorder.client.GetVersionNumber)
+}
+
+func (recorder *RecordingClient) GetCtrlAxesNumber() (int16, int16) {
+	return Record(recorder, "GetCtrlAxesNumber", nil, recorder.client.GetCtrlAxesNumber)
+}
+
+func (recorder *RecordingClient) GetCtrlSpindlesNumber() (int16, int16) {
+	return Record(recorder, "GetCtrlSpindlesNumber", nil, recorder.client.GetCtrlSpindlesNumber)
+}
+
+func (recorder *RecordingClient) GetCtrlPathsNumber() (int16, int16) {
+	return Record(recorder, "GetCtrlPathsNumber", nil, recorder.client.GetCtrlPathsNumber)
+}
+
+func (recorder *RecordingClient) GetSerialNumber() (int64, int16) {
+	return Record(recorder, "GetSerialNumber", nil, recorder.client.GetSerialNumber)
+}
+
+func (recorder *RecordingClient) GetCncId() (string, int16) {
+	return Record(recorder, "GetCncId", nil, recorder.client.GetCncId)
+}
+
+func (recorder *RecordingClient) ReadPmcRange(area int16, start uint16, end uint16) ([]byte, int16) {
+	return Record(recorder, "ReadPmcRange", []any{area, start, end}, func() ([]byte, int16) {
+		return recorder.client.ReadPmcRange(area, start, end)
+	})
+}
+
+func (recorder *RecordingClient) ReadMacroRange(start int32, end int32) ([]CncMacroValue, int16) {
+	return Record(recorder, "ReadMacroRange", []any{start, end}, func() ([]CncMacroValue, int16) {
+		return recorder.client.ReadMacroRange(start, end)
+	})
+}
+
+func (recorder *RecordingClient) ReadParameter(number int32, axis int16, size int16) (CncDataValue, int16) {
+	return Record(recorder, "ReadParameter", []any{number, axis, size}, func() (CncDataValue, int16) {
+		return recorder.client.ReadParameter(number, axis, size)
+	})
+}
+
+func (recorder *RecordingClient) ReadDiagnosis(number int32, axis int16, size int16) (CncDataValue, int16) {
+	return Record(recorder, "ReadDiagnosis", []any{number, axis, size}, func() (CncDataValue, int16) {
+		return recorder.client.ReadDiagnosis(number, axis, size)
+	})
+}
+
+// Replay state is shared by all collector sessions of a device
+type ReplaySession struct {
+	mutex        sync.Mutex
+	records      []CallRecord
+	queues       map[string][]CallRecord
+	recorded     map[string]bool
+	speed        float64
+	loop         bool
+	finished     bool
+	start_wall   time.Time
+	start_record time.Time
+	// last returned record of the call, repeated when its queue is empty
+	last map[string]CallRecord
+	// calls made since the rewind, the replay ends when all of their queues are empty
+	requested map[string]bool
+}
+
+var replay_sessions = make(map[string]*ReplaySession)
+var replay_sessions_mutex sync.Mutex
+
+func LoadCallRecords(record_path string) ([]CallRecord, error) {
+	file, err := os.Open(record_path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var records []CallRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var record CallRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+func GetReplaySession(device *Device) *ReplaySession {
+	replay_sessions_mutex.Lock()
+	defer replay_sessions_mutex.Unlock()
+	if session, ok := replay_sessions[device.Name]; ok {
+		return session
+	}
+	replay_path := device.ReplayFile
+	if !filepath.IsAbs(replay_path) {
+		replay_path = filepath.Join(plugin_dir, replay_path)
+	}
+	records, err := LoadCallRecords(replay_path)
+	if err != nil {
+		logger.Printf("Ошибка чтения файла воспроизведения %s: %v", replay_path, err)
+	}
+	speed := device.ReplaySpeed
+	if speed < 0 {
+		speed = 0
+	}
+	session := &ReplaySession{records: records, speed: speed, loop: device.ReplayLoop}
+	session.rewind()
+	replay_sessions[device.Name] = session
+	return session
+}
+
+func (session *ReplaySession) rewind() {
+	session.queues = make(map[string][]CallRecord)
+	session.recorded = make(map[string]bool)
+	session.last = make(map[string]CallRecord)
+	session.requested = make(map[string]bool)
+	for _, record := range session.records {
+		key := GetCallKey(record.Method, record.Args)
+		session.queues[key] = append(session.queues[key], record)
+		session.recorded[key] = true
+	}
+	session.finished = len(session.records) == 0
+	session.start_wall = time.Now()
+	if len(session.records) > 0 {
+		session.start_record = session.records[0].Time
+	}
+}
+
+func (session *ReplaySession) next(method string, args json.RawMessage) (CallRecord, bool) {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+	if session.finished {
+		return CallRecord{}, false
+	}
+	key := GetCallKey(method, args)
+	if !session.recorded[key] {
+		return CallRecord{Method: method, Error: EW_FUNC}, true
+	}
+	session.requested[key] = true
+	if len(session.queues[key]) == 0 {
+		// calls polled more often than recorded repeat the last result
+		if !session.drained() {
+			return session.last[key], true
+		}
+		if !session.loop {
+			session.finished = true
+			logger.Println("Воспроизведение записи завершено")
+			return CallRecord{}, false
+		}
+		session.rewind()
+		session.requested[key] = true
+	}
+	queue := session.queues[key]
+	session.queues[key] = queue[1:]
+	session.last[key] = queue[0]
+	return queue[0], true
+}
+
+// queues of the calls made since the rewind are empty
+func (session *ReplaySession) drained() bool {
+	for key := range session.requested {
+		if len(session.queues[key]) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (session *ReplaySession) wait(record CallRecord) {
+	if session.speed == 0 || record.Time.IsZero() {
+		return
+	}
+	session.mutex.Lock()
+	offset := float64(record.Time.Sub(session.start_record)) / session.speed
+	due := session.start_wall.Add(time.Duration(offset))
+	session.mutex.Unlock()
+	for running {
+		delay := time.Until(due)
+		if delay <= 0 {
+			return
+		}
+		time.Sleep(min(delay, 100*time.Millisecond))
+	}
+}
+
+// values loaded from json or yaml are converted to the method result type
+func ConvertScriptedValue[T any](value any) (T, int16) {
+	var result T
+	if typed, ok := value.(T); ok {
+		return typed, EW_OK
+	}
+	var json_data []byte
+	var err error
+	if raw, ok := value.(json.RawMessage); ok {
+		json_data = raw
+	} else {
+		json_data, err = json.Marshal(value)
+		if err != nil {
+			return result, EW_DATA
+		}
+	}
+	if err = json.Unmarshal(json_data, &result); err != nil {
+		return result, EW_DATA
+	}
+	return result, EW_OK
+}
+
+// Replay backend, returns recorded results at original or accelerated speed
+type ReplayClient struct {
+	session *ReplaySession
+}
+
+func NewReplayClient(device *Device) CNCClient {
+	return &ReplayClient{session: GetReplaySession(device)}
+}
+
+func Replay[T any](replay *ReplayClient, method string, args ...any) (T, int16) {
+	var result T
+	record, ok := replay.session.next(method, MarshalArgs(args))
+	if !ok {
+		return result, EW_SOCKET
+	}
+	replay.session.wait(record)
+	if record.Error != EW_OK {
+		return result, record.Error
+	}
+	if len(record.Value) == 0 {
+		return result, EW_OK
+	}
+	return ConvertScriptedValue[T](record.Value)
+}
+
+func (replay *ReplayClient) Alive() bool {
+	replay.session.mutex.Lock()
+	defer replay.session.mutex.Unlock()
+	return !replay.session.finished
+}
+
+func (replay *ReplayClient) Connect(address string, port int, timeout int) int16 {
+	record, ok := replay.session.next("Connect", nil)
+	if !ok {
+		return EW_SOCKET
+	}
+	replay.session.wait(record)
+	return record.Error
+}
+
+func (replay *ReplayClient) Free() int16 {
+	_, ret := Replay[any](replay, "Free")
+	if ret == EW_SOCKET {
+		return EW_OK
+	}
+	return ret
+}
+
+func (replay *ReplayClient) Handle() uint16 {
+	return 0
+}
+
+func (replay *ReplayClient) GetMaxPath() (int16, int16) {
+	return Replay[int16](replay, "GetMaxPath")
+}
+
+func (replay *ReplayClient) SetPath(path int16) int16 {
+	_, ret := Replay[any](replay, "SetPath", path)
+	return ret
+}
+
+func (replay *ReplayClient) GetAxisNames() ([]string, int16) {
+	return Replay[[]string](replay, "GetAxisNames")
+}
+
+func (replay *ReplayClient) GetSpindleNames() ([]string, int16) {
+	return Replay[[]string](replay, "GetSpindleNames")
+}
+
+func (replay *ReplayClient) GetStatInfo() (CncStatInfo, int16) {
+	return Replay[CncStatInfo](replay, "GetStatInfo")
+}
+
+func (replay *ReplayClient) GetExecProgram() (string, int16) {
+	return Replay[string](replay, "GetExecProgram")
+}
+
+func (replay *ReplayClient) GetExecBlocks() (CncExecBlocks, int16) {
+	return Replay[CncExecBlocks](replay, "GetExecBlocks")
+}
+
+func (replay *ReplayClient) GetBlockCount() (int64, int16) {
+	return Replay[int64](replay, "GetBlockCount")
+}
+
+func (replay *ReplayClient) GetTimerParams(numbers []int32) (map[int32]int64, int16) {
+	return Replay[map[int32]int64](replay, "GetTimerParams", numbers)
+}
+
+func (replay *ReplayClient) GetAut() (int16, int16) {
+	return Replay[int16](replay, "GetAut")
+}
+
+func (replay *ReplayClient) GetRun() (int16, int16) {
+	return Replay[int16](replay, "GetRun")
+}
+
+func (replay *ReplayClient) GetEdit() (int16, int16) {
+	return Replay[int16](replay, "GetEdit")
+}
+
+func (replay *ReplayClient) GetMstb() (int16, int16) {
+	return Replay[int16](replay, "GetMstb")
+}
+
+func (replay *ReplayClient) GetMotion() (int16, int16) {
+	return Replay[int16](replay, "GetMotion")
+}
+
+func (replay *ReplayClient) GetG00() (int16, int16) {
+	return Replay[int16](replay, "GetG00")
+}
+
+func (replay *ReplayClient) GetGCodes() ([]CncGCode, int16) {
+	return Replay[[]CncGCode](replay, "GetGCodes")
+}
+
+func (replay *ReplayClient) GetModalCodes() (map[string]int32, int16) {
+	return Replay[map[string]int32](replay, "GetModalCodes")
+}
+
+func (replay *ReplayClient) GetShutdowns() (int16, int16) {
+	return Replay[int16](replay, "GetShutdowns")
+}
+
+func (replay *ReplayClient) GetLoadExcess() (int16, int16) {
+	return Replay[int16](replay, "GetLoadExcess")
+}
+
+func (replay *ReplayClient) GetMainProgNum() (int32, int16) {
+	return Replay[int32](replay, "GetMainProgNum")
+}
+
+func (replay *ReplayClient) GetSubProgNum() (int32, int16) {
+	return Replay[int32](replay, "GetSubProgNum")
+}
+
+func (replay *ReplayClient) GetProgramPath() (string, int16) {
+	return Replay[string](replay, "GetProgramPath")
+}
+
+func (replay *ReplayClient) GetProgramDirectory(folder string) ([]CncProgram, int16) {
+	return Replay[[]CncProgram](replay, "GetProgramDirectory", folder)
+}
+
+func (replay *ReplayClient) UploadProgram(path string) ([]byte, int16) {
+	return Replay[[]byte](replay, "UploadProgram", path)
+}
+
+func (replay *ReplayClient) GetFrameNumber() (int64, int16) {
+	return Replay[int64](replay, "GetFrameNumber")
+}
+
+func (replay *ReplayClient) GetFrame() (string, int16) {
+	return Replay[string](replay, "GetFrame")
+}
+
+func (replay *ReplayClient) GetPartsCount() (int64, int16) {
+	return Replay[int64](replay, "GetPartsCount")
+}
+
+func (replay *ReplayClient) GetToolNumber() (int64, int16) {
+	return Replay[int64](replay, "GetToolNumber")
+}
+
+func (replay *ReplayClient) GetToolOffsetInfo() (CncToolOffsetInfo, int16) {
+	return Replay[CncToolOffsetInfo](replay, "GetToolOffsetInfo")
+}
+
+func (replay *ReplayClient) GetToolOffsets(offset_type int16, start int16, end int16) ([]int32, int16) {
+	return Replay[[]int32](replay, "GetToolOffsets", offset_type, start, end)
+}
+
+func (replay *ReplayClient) GetToolLifeInfo() (CncToolLifeInfo, int16) {
+	return Replay[CncToolLifeInfo](replay, "GetToolLifeInfo")
+}
+
+func (replay *ReplayClient) GetToolLifeGroups(start int32, count int16) ([]CncToolLifeGroup, int16) {
+	return Replay[[]CncToolLifeGroup](replay, "GetToolLifeGroups", start, count)
+}
+
+func (replay *ReplayClient) GetToolLifeTools(group int32, count int16) ([]CncToolLifeTool, int16) {
+	return Replay[[]CncToolLifeTool](replay, "GetToolLifeTools", group, count)
+}
+
+func (replay *ReplayClient) GetWorkOffsetCount() (int16, int16) {
+	return Replay[int16](replay, "GetWorkOffsetCount")
+}
+
+func (replay *ReplayClient) GetWorkOffsets(start int16, end int16, axes int16) ([][]int32, int16) {
+	return Replay[[][]int32](replay, "GetWorkOffsets", start, end, axes)
+}
+
+func (replay *ReplayClient) GetWorkShift(axes int16) ([]int32, int16) {
+	return Replay[[]int32](replay, "GetWorkShift", axes)
+}
+
+func (replay *ReplayClient) GetAbsolutePositions() (map[string]float64, int16) {
+	return Replay[map[string]float64](replay, "GetAbsolutePositions")
+}
+
+func (replay *ReplayClient) GetRelativePositions() (map[string]float64, int16) {
+	return Replay[map[string]float64](replay, "GetRelativePositions")
+}
+
+func (replay *ReplayClient) GetMachinePositions() (map[string]float64, int16) {
+	return Replay[map[string]float64](replay, "GetMachinePositions")
+}
+
+func (replay *ReplayClient) GetDistanceToGo() (map[string]float64, int16) {
+	return Replay[map[string]float64](replay, "GetDistanceToGo")
+}
+
+func (replay *ReplayClient) GetCommandedPositions() (map[string]float64, int16) {
+	return Replay[map[string]float64](replay, "GetCommandedPositions")
+}
+
+func (replay *ReplayClient) GetFeedRate() (float64, int16) {
+	return Replay[float64](replay, "GetFeedRate")
+}
+
+func (replay *ReplayClient) GetFeedRateParam1() (map[string]float64, int16) {
+	return Replay[map[string]float64](replay, "GetFeedRateParam1")
+}
+
+func (replay *ReplayClient) GetFeedRateParam2() (map[string]float64, int16) {
+	return Replay[map[string]float64](replay, "GetFeedRateParam2")
+}
+
+func (replay *ReplayClient) GetFeedOverride() (int16, int16) {
+	return Replay[int16](replay, "GetFeedOverride")
+}
+
+func (replay *ReplayClient) GetJogOverride() (int16, int16) {
+	return Replay[int16](replay, "GetJogOverride")
+}
+
+func (replay *ReplayClient) GetJogSpeed() (map[string]float64, int16) {
+	return Replay[map[string]float64](replay, "GetJogSpeed")
+}
+
+func (replay *ReplayClient) GetServoLoad() (map[string]int64, int16) {
+	return Replay[map[string]int64](replay, "GetServoLoad")
+}
+
+func (replay *ReplayClient) GetServoCurrentLoad() (map[string]float64, int16) {
+	return Replay[map[string]float64](replay, "GetServoCurrentLoad")
+}
+
+func (replay *ReplayClient) GetServoCurrentLoadPercent() (map[string]int64, int16) {
+	return Replay[map[string]int64](replay, "GetServoCurrentLoadPercent")
+}
+
+func (replay *ReplayClient) GetSpindleSpeed() (float64, int16) {
+	return Replay[float64](replay, "GetSpindleSpeed")
+}
+
+func (replay *ReplayClient) GetSpindleSpeedParam() (map[string]int64, int16) {
+	return Replay[map[string]int64](replay, "GetSpindleSpeedParam")
+}
+
+func (replay *ReplayClient) GetSpindleMotorSpeed() (map[string]int64, int16) {
+	return Replay[map[string]int64](replay, "GetSpindleMotorSpeed")
+}
+
+func (replay *ReplayClient) GetSpindleLoad() (map[string]int64, int16) {
+	return Replay[map[string]int64](replay, "GetSpindleLoad")
+}
+
+func (replay *ReplayClient) GetSpindleAlarms() ([]int16, int16) {
+	return Replay[[]int16](replay, "GetSpindleAlarms")
+}
+
+func (replay *ReplayClient) GetSpindleOverride() (int16, int16) {
+	return Replay[int16](replay, "GetSpindleOverride")
+}
+
+func (replay *ReplayClient) GetEmergency() (int16, int16) {
+	return Replay[int16](replay, "GetEmergency")
+}
+
+func (replay *ReplayClient) GetAlarm() (int16, int16) {
+	return Replay[int16](replay, "GetAlarm")
+}
+
+func (replay *ReplayClient) GetAlarmMessages() ([]CncAlarmMessage, int16) {
+	return Replay[[]CncAlarmMessage](replay, "GetAlarmMessages")
+}
+
+func (replay *ReplayClient) GetAlarmHistoryCount() (int32, int16) {
+	return Replay[int32](replay, "GetAlarmHistoryCount")
+}
+
+func (replay *ReplayClient) GetAlarmHistory(start int32, end int32) ([]CncAlarmHistoryEntry, int16) {
+	return Replay[[]CncAlarmHistoryEntry](replay, "GetAlarmHistory", start, end)
+}
+
+func (replay *ReplayClient) GetOperatorMessages() ([]CncOperatorMessage, int16) {
+	return Replay[[]CncOperatorMessage](replay, "GetOperatorMessages")
+}
+
+func (replay *ReplayClient) GetPowerOnTime() (int64, int16) {
+	return Replay[int64](replay, "GetPowerOnTime")
+}
+
+func (replay *ReplayClient) GetOperationTime() (float64, int16) {
+	return Replay[float64](replay, "GetOperationTime")
+}
+
+func (replay *ReplayClient) GetCuttingTime() (float64, int16) {
+	return Replay[float64](replay, "GetCuttingTime")
+}
+
+func (replay *ReplayClient) GetCycleTime() (float64, int16) {
+	return Replay[float64](replay, "GetCycleTime")
+}
+
+func (replay *ReplayClient) GetTimer(timer_type int16) (CncTimer, int16) {
+	return Replay[CncTimer](replay, "GetTimer", timer_type)
+}
+
+func (replay *ReplayClient) GetSeriesNumber() (string, int16) {
+	return Replay[string](replay, "GetSeriesNumber")
+}
+
+func (replay *ReplayClient) GetVersionNumber() (string, int16) {
+	return Replay[string](replay, "GetVersionNumber")
+}
+
+func (replay *ReplayClient) GetCtrlAxesNumber() (int16, int16) {
+	return Replay[int16](replay, "GetCtrlAxesNumber")
+}
+
+func (replay *ReplayClient) GetCtrlSpindlesNumber() (int16, int16) {
+	return Replay[int16](replay, "GetCtrlSpindlesNumber")
+}
+
+func (replay *ReplayClient) GetCtrlPathsNumber() (int16, int16) {
+	return Replay[int16](replay, "GetCtrlPathsNumber")
+}
+
+func (replay *ReplayClient) GetSerialNumber() (int64, int16) {
+	return Replay[int64](replay, "GetSerialNumber")
+}
+
+func (replay *ReplayClient) GetCncId() (string, int16) {
+	return Replay[string](replay, "GetCncId")
+}
+
+func (replay *ReplayClient) ReadPmcRange(area int16, start uint16, end uint16) ([]byte, int16) {
+	return Replay[[]byte](replay, "ReadPmcRange", area, start, end)
+}
+
+func (replay *ReplayClient) ReadMacroRange(start int32, end int32) ([]CncMacroValue, int16) {
+	return Replay[[]CncMacroValue](replay, "ReadMacroRange", start, end)
+}
+
+func (replay *ReplayClient) ReadParameter(number int32, axis int16, size int16) (CncDataValue, int16) {
+	return Replay[CncDataValue](replay, "ReadParameter", number, axis, size)
+}
+
+func (replay *ReplayClient) ReadDiagnosis(number int32, axis int16, size int16) (CncDataValue, int16) {
+	return Replay[CncDataValue](replay, "ReadDiagnosis", number, axis, size)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+)
+
+// records calls of a fake backend and returns the replaying device
+func RecordTestSession(t *testing.T, name string) Device {
+	t.Helper()
+	fake := NewFakeClient()
+	fake.Script("GetStatInfo", FakeResult{Value: CncStatInfo{Aut: 1}}, FakeResult{Value: CncStatInfo{Aut: 2}}, FakeResult{Error: EW_NOOPT})
+	fake.SetValue("GetFeedRate", 1250.0)
+	fake.SetValue("GetAbsolutePositions", map[string]float64{"X": 1.5, "Z": -7.25})
+	fake.SetValue("ReadParameter", CncDataValue{Value: 42})
+	device := NewTestDevice()
+	device.Name = name
+	recorder := NewRecordingClient(fake, GetRecordPath(&device))
+	if ret := recorder.Connect("127.0.0.1", 8193, 1); ret != EW_OK {
+		t.Fatalf("Connect: %d", ret)
+	}
+	for range 3 {
+		recorder.GetStatInfo()
+	}
+	recorder.GetFeedRate()
+	recorder.GetAbsolutePositions()
+	recorder.ReadParameter(6711, 0, 4)
+	recorder.Free()
+	device.Protocol = "replay"
+	device.ReplayFile = GetRecordPath(&device)
+	device.ReplaySpeed = -1
+	t.Cleanup(func() {
+		replay_sessions_mutex.Lock()
+		delete(replay_sessions, name)
+		replay_sessions_mutex.Unlock()
+	})
+	return device
+}
+
+// one collector cycle of the recorded session
+func ReplayTestCycle(t *testing.T, replay CNCClient) (CncStatInfo, int16) {
+	t.Helper()
+	stat_info, stat_info_error := replay.GetStatInfo()
+	if feedrate, ret := replay.GetFeedRate(); ret != EW_OK || feedrate != 1250 {
+		t.Fatalf("GetFeedRate: %v, %d", feedrate, ret)
+	}
+	positions, ret := replay.GetAbsolutePositions()
+	if ret != EW_OK {
+		t.Fatalf("GetAbsolutePositions: %d", ret)
+	}
+	AssertFloatMap(t, "absolute_positions", positions, map[string]float64{"X": 1.5, "Z": -7.25})
+	if value, ret := replay.ReadParameter(6711, 0, 4); ret != EW_OK || value.Value != 42 {
+		t.Fatalf("ReadParameter: %+v, %d", value, ret)
+	}
+	return stat_info, stat_info_error
+}
+
+func TestRecordReplayRoundTrip(t *testing.T) {
+	UseTestPluginDir(t)
+	device := RecordTestSession(t, "record test")
+	replay := NewCNCClient(&device)
+	if ret := replay.Connect(device.Address, device.Port, 1); ret != EW_OK {
+		t.Fatalf("Connect: %d", ret)
+	}
+	// calls polled more often than recorded repeat the last result
+	for _, expected := range []int16{1, 2} {
+		if stat_info, ret := ReplayTestCycle(t, replay); ret != EW_OK || stat_info.Aut != expected {
+			t.Fatalf("GetStatInfo: %+v, %d, expected aut %d", stat_info, ret, expected)
+		}
+	}
+	if _, ret := replay.ReadParameter(1320, 0, 4); ret != EW_FUNC {
+		t.Fatalf("ReadParameter with not recorded arguments: %d, expected %d", ret, EW_FUNC)
+	}
+	if _, ret := replay.GetStatInfo(); ret != EW_NOOPT || !replay.(*ReplayClient).Alive() {
+		t.Fatalf("GetStatInfo: %d, expected %d", ret, EW_NOOPT)
+	}
+	// all queues of the polled calls are empty
+	if _, ret := replay.GetFeedRate(); ret != EW_SOCKET || replay.(*ReplayClient).Alive() {
+		t.Fatalf("replay is not finished: %d", ret)
+	}
+}
+
+func TestReplayLoop(t *testing.T) {
+	UseTestPluginDir(t)
+	device := RecordTestSession(t, "replay loop test")
+	device.ReplayLoop = true
+	replay := NewCNCClient(&device)
+	replay.Connect(device.Address, device.Port, 1)
+	for range 2 {
+		ReplayTestCycle(t, replay)
+	}
+	replay.GetStatInfo()
+	// rewound when all polled calls are replayed
+	if feedrate, ret := replay.GetFeedRate(); ret != EW_OK || feedrate != 1250 {
+		t.Fatalf("GetFeedRate after the rewind: %v, %d", feedrate, ret)
+	}
+	if stat_info, ret := ReplayTestCycle(t, replay); ret != EW_OK || stat_info.Aut != 1 {
+		t.Fatalf("GetStatInfo after the rewind: %+v, %d", stat_info, ret)
+	}
+}
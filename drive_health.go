@@ -0,0 +1,94 @@
+package main
+
+import "maps"
+
+// diagnosis data of every servo axis, axis of the item is ignored
+var servo_health_items = map[string]CncDataItem{
+	"motor_temperature":       {Number: 308, Type: "byte"},
+	"pulse_coder_temperature": {Number: 309, Type: "byte"},
+	"amplifier_status":        {Number: 200, Type: "byte"},
+}
+
+// diagnosis data of every spindle, alarm of cnc_rdspdlalm is added
+var spindle_health_items = map[string]CncDataItem{
+	"motor_temperature": {Number: 403, Type: "byte"},
+}
+
+// diagnosis numbers of the device, number 0 disables the default item
+type DriveHealthConfig struct {
+	Servo   map[string]CncDataItem `json:"servo" yaml:"servo"`
+	Spindle map[string]CncDataItem `json:"spindle" yaml:"spindle"`
+}
+
+func GetDriveHealthItems(defaults map[string]CncDataItem, device_items map[string]CncDataItem) map[string]CncDataItem {
+	items := MergeCncDataItems(defaults, device_items)
+	maps.DeleteFunc(items, func(name string, item CncDataItem) bool {
+		return item.Number == 0
+	})
+	return items
+}
+
+func GetServoHealthItems(device *Device) map[string]CncDataItem {
+	if device.DriveHealth == nil {
+		return GetDriveHealthItems(servo_health_items, nil)
+	}
+	return GetDriveHealthItems(servo_health_items, device.DriveHealth.Servo)
+}
+
+func GetSpindleHealthItems(device *Device) map[string]CncDataItem {
+	if device.DriveHealth == nil {
+		return GetDriveHealthItems(spindle_health_items, nil)
+	}
+	return GetDriveHealthItems(spindle_health_items, device.DriveHealth.Spindle)
+}
+
+// diagnosis data of the axis or spindle number, absent numbers are skipped
+func ReadDriveItems(client CNCClient, items map[string]CncDataItem, number int16) (map[string]any, int16) {
+	result := make(map[string]any)
+	for name, item := range items {
+		value, ret := client.ReadDiagnosis(item.Number, number, item.Size())
+		if ret == EW_NUMBER || IsMissingFunction(ret) {
+			continue
+		}
+		if ret != 0 {
+			return make(map[string]any), ret
+		}
+		result[name] = GetCncDataValue(item, value)
+	}
+	return result, 0
+}
+
+// axis or spindle name -> item -> value
+func ReadDriveHealth(client CNCClient, servo_items map[string]CncDataItem, spindle_items map[string]CncDataItem) (map[string]map[string]any, int16) {
+	result := make(map[string]map[string]any)
+	axes, ret := client.GetAxisNames()
+	if ret != 0 {
+		return result, ret
+	}
+	for index, axis := range axes {
+		values, ret := ReadDriveItems(client, servo_items, int16(index+1))
+		if ret != 0 {
+			return make(map[string]map[string]any), ret
+		}
+		result[axis] = values
+	}
+	spindles, ret := client.GetSpindleNames()
+	if ret != 0 {
+		return make(map[string]map[string]any), ret
+	}
+	alarms, ret := client.GetSpindleAlarms()
+	if ret != 0 && !IsMissingFunction(ret) {
+		return make(map[string]map[string]any), ret
+	}
+	for index, spindle := range spindles {
+		values, ret := ReadDriveItems(client, spindle_items, int16(index+1))
+		if ret != 0 {
+			return make(map[string]map[string]any), ret
+		}
+		if index < len(alarms) {
+			values["alarm"] = alarms[index]
+		}
+		result[spindle] = values
+	}
+	return result, 0
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"sync"
+)
+
+// blocks before and after the executing block by default
+const default_exec_window = 3
+
+// exec_window tag item, offset 0 is the executing block, previous blocks are negative
+type ExecBlock struct {
+	Offset int    `json:"offset"`
+	Block  string `json:"block"`
+}
+
+// executing blocks seen by the previous reads of every device scope
+type ExecHistories struct {
+	mutex     sync.Mutex
+	histories map[string][]string
+}
+
+var exec_histories = ExecHistories{
+	histories: make(map[string][]string),
+}
+
+func GetExecWindowSize(device *Device) int {
+	if device.ExecWindow > 0 {
+		return device.ExecWindow
+	}
+	return default_exec_window
+}
+
+func SplitBlocks(text string) []string {
+	blocks := make([]string, 0)
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			blocks = append(blocks, line)
+		}
+	}
+	return blocks
+}
+
+// size blocks before and after the executing block, previous blocks are taken from
+// the executed blocks of cnc_rdexecprog or from the blocks seen by the previous reads
+func (histories *ExecHistories) Window(scope_key string, exec_blocks CncExecBlocks, size int) []ExecBlock {
+	window := make([]ExecBlock, 0)
+	blocks := SplitBlocks(exec_blocks.Text)
+	if len(blocks) == 0 {
+		return window
+	}
+	current := int(exec_blocks.Executed)
+	if current < 0 || current >= len(blocks) {
+		current = 0
+	}
+	histories.mutex.Lock()
+	defer histories.mutex.Unlock()
+	history := histories.histories[scope_key]
+	if len(history) == 0 || history[len(history)-1] != blocks[current] {
+		history = append(history, blocks[current])
+	}
+	history = history[max(len(history)-size-1, 0):]
+	histories.histories[scope_key] = history
+	previous := blocks[:current]
+	if len(previous) == 0 {
+		previous = history[:len(history)-1]
+	}
+	previous = previous[max(len(previous)-size, 0):]
+	for index, block := range previous {
+		window = append(window, ExecBlock{Offset: index - len(previous), Block: block})
+	}
+	for index, block := range blocks[current:min(current+size+1, len(blocks))] {
+		window = append(window, ExecBlock{Offset: index, Block: block})
+	}
+	return window
+}
@@ -1,3 +1,5 @@
+//go:build windows || fwlib
+
 package main
 
 /*
@@ -17,7 +19,6 @@ static short get_max_spindles()
 import "C"
 
 import (
-	"fmt"
 	"math"
 	"strings"
 	"time"
@@ -63,6 +64,120 @@ func FreeHandle(handle *uint16) int16 {
 	return int16(ret)
 }
 
+// Path functions
+func GetMaxPath(handle *uint16) (int16, int16) {
+	var path_no C.short
+	var max_path_no C.short
+	ret := C.cnc_getpath(C.ushort(*handle), &path_no, &max_path_no)
+	if ret != C.EW_OK {
+		return 0, int16(ret)
+	}
+	return int16(max_path_no), 0
+}
+
+func SetPath(handle *uint16, path int16) int16 {
+	ret := C.cnc_setpath(C.ushort(*handle), C.short(path))
+	return int16(ret)
+}
+
+// Name functions
+func GetAxisNames(handle *uint16) ([]string, int16) {
+	var result []string
+	num := C.get_max_axis()
+	buf := make([]C.ODBAXISNAME, int(num))
+	ret := C.cnc_rdaxisname(C.ushort(*handle), &num, (*C.ODBAXISNAME)(unsafe.Pointer(&buf[0])))
+	if ret != C.EW_OK {
+		return result, int16(ret)
+	}
+	for _, axis := range buf[:num] {
+		name := FormatAxisName(byte(axis.name), byte(axis.suff))
+		if name != "" {
+			result = append(result, name)
+		}
+	}
+	return result, 0
+}
+
+func GetSpindleNames(handle *uint16) ([]string, int16) {
+	var result []string
+	num := C.get_max_spindles()
+	buf := make([]C.ODBSPDLNAME, int(num))
+	ret := C.cnc_rdspdlname(C.ushort(*handle), &num, (*C.ODBSPDLNAME)(unsafe.Pointer(&buf[0])))
+	if ret != C.EW_OK {
+		return result, int16(ret)
+	}
+	for _, spindle := range buf[:num] {
+		name := FormatAxisName(byte(spindle.name), byte(spindle.suff1))
+		if name != "" {
+			result = append(result, name)
+		}
+	}
+	return result, 0
+}
+
+// Read plan functions
+func GetStatInfo(handle *uint16) (CncStatInfo, int16) {
+	var buf C.ODBST
+	ret := C.cnc_statinfo(C.ushort(*handle), &buf)
+	if ret != C.EW_OK {
+		return CncStatInfo{}, int16(ret)
+	}
+	return CncStatInfo{
+		Aut:       int16(buf.aut),
+		Run:       int16(buf.run),
+		Edit:      int16(buf.edit),
+		Mstb:      int16(buf.mstb),
+		Motion:    int16(buf.motion),
+		Emergency: int16(buf.emergency),
+		Alarm:     int16(buf.alarm),
+	}, 0
+}
+
+func GetExecProgram(handle *uint16) (string, int16) {
+	var length C.ushort = 1024
+	var blknum C.short
+	var buf [1024]C.char
+	ret := C.cnc_rdexecprog(C.ushort(*handle), &length, &blknum, &buf[0])
+	if ret != C.EW_OK {
+		return "", int16(ret)
+	}
+	return C.GoString(&buf[0]), 0
+}
+
+func GetExecBlocks(handle *uint16) (CncExecBlocks, int16) {
+	var length C.ushort = 1024
+	var blknum C.short
+	var buf [1024]C.char
+	ret := C.cnc_rdexecprog(C.ushort(*handle), &length, &blknum, &buf[0])
+	if ret != C.EW_OK {
+		return CncExecBlocks{}, int16(ret)
+	}
+	return CncExecBlocks{Text: C.GoString(&buf[0]), Executed: int16(blknum)}, 0
+}
+
+func GetBlockCount(handle *uint16) (int64, int16) {
+	var count C.long
+	ret := C.cnc_rdblkcount(C.ushort(*handle), &count)
+	if ret != C.EW_OK {
+		return 0, int16(ret)
+	}
+	return int64(count), 0
+}
+
+func GetTimerParams(handle *uint16, numbers []int32) (map[int32]int64, int16) {
+	result := make(map[int32]int64)
+	for _, number := range numbers {
+		var buf C.IODBPSD
+		ret := C.cnc_rdparam(C.ushort(*handle), C.short(number), C.short(-1), C.short(unsafe.Sizeof(buf)), &buf)
+		if ret != C.EW_OK {
+			return result, int16(ret)
+		}
+		rdata := (*C.REALPRM)(unsafe.Pointer(&buf.u[0]))
+		result[number] = int64(rdata.prm_val)
+	}
+	return result, 0
+}
+
 // Mode functions
 func GetAut(handle *uint16) (int16, int16) {
 	var buf C.ODBST
@@ -122,6 +237,39 @@ func GetG00(handle *uint16) (int16, int16) {
 	return 0, 0
 }
 
+// modal G codes of the active block, one per group
+func GetGCodes(handle *uint16) ([]CncGCode, int16) {
+	result := make([]CncGCode, 0)
+	var buf [max_modal_groups]C.ODBGCD
+	num := C.short(max_modal_groups)
+	ret := C.cnc_rdgcode(C.ushort(*handle), C.short(-1), C.short(1), &num, &buf[0])
+	if ret != C.EW_OK {
+		return result, int16(ret)
+	}
+	for _, g_code := range buf[:num] {
+		code := C.GoString(&g_code.code[0])
+		if code == "" {
+			continue
+		}
+		result = append(result, CncGCode{Group: int16(g_code.group) + 1, Code: code})
+	}
+	return result, 0
+}
+
+// M, S, T, F, H, D codes of the active block
+func GetModalCodes(handle *uint16) (map[string]int32, int16) {
+	result := make(map[string]int32)
+	for name, code_type := range modal_code_types {
+		var buf C.ODBMDL
+		ret := C.cnc_modal(C.ushort(*handle), C.short(code_type), C.short(0), &buf)
+		if ret != C.EW_OK {
+			return make(map[string]int32), int16(ret)
+		}
+		result[name] = int32(*(*C.long)(unsafe.Pointer(&buf.modal[0])))
+	}
+	return result, 0
+}
+
 func GetShutdowns(handle *uint16) (int16, int16) {
 	var length C.ushort = 1024
 	var blknum C.short
@@ -130,34 +278,20 @@ func GetShutdowns(handle *uint16) (int16, int16) {
 	if ret != C.EW_OK {
 		return 0, int16(ret)
 	}
-	commands := []string{"M00", "M01", "G04"}
-	go_str := C.GoString(&buf[0])
-	splitted_str := strings.Split(go_str, "\n")
-	for index := range commands {
-		for _, part := range splitted_str {
-			if strings.Contains(part, commands[index]) {
-				return int16(index), 0
-			}
-		}
-	}
-	return 3, 0
+	return ParseShutdowns(C.GoString(&buf[0])), 0
 }
 
 func GetLoadExcess(handle *uint16) (int16, int16) {
-	result := int16(0)
 	num := C.get_max_axis()
 	buf_1 := make([]C.ODBSVLOAD, num)
 	ret := C.cnc_rdsvmeter(C.ushort(*handle), &num, (*C.ODBSVLOAD)(unsafe.Pointer(&buf_1[0])))
 	if ret != C.EW_OK {
 		return 0, int16(ret)
 	}
+	var servo_loads []float64
 	for _, data := range buf_1 {
 		dec := int16(data.svload.dec)
-		value := float64(data.svload.data) * math.Pow(10, -float64(dec))
-		if value > 100 {
-			result = 1
-			break
-		}
+		servo_loads = append(servo_loads, float64(data.svload.data)*math.Pow(10, -float64(dec)))
 	}
 	num = C.get_max_spindles()
 	buf_2 := make([]C.ODBSPLOAD, num)
@@ -165,38 +299,160 @@ func GetLoadExcess(handle *uint16) (int16, int16) {
 	if ret != C.EW_OK {
 		return 0, int16(ret)
 	}
+	var spindle_loads []float64
 	for _, data := range buf_2 {
 		dec := int16(data.spload.dec)
-		value := float64(data.spload.data) * math.Pow(10, -float64(dec))
-		if value > 100 {
-			if result == 0 {
-				return 2, 0
-			} else {
-				return 3, 0
-			}
-		}
+		spindle_loads = append(spindle_loads, float64(data.spload.data)*math.Pow(10, -float64(dec)))
 	}
-	return 0, 0
+	return GetLoadExcessState(servo_loads, spindle_loads), 0
 }
 
 // Program functions
 
-func GetMainProgNum(handle *uint16) (int16, int16) {
-	var buf C.ODBPRO
-	ret := C.cnc_rdprgnum(C.ushort(*handle), &buf)
+// running and main program numbers, O8-digit numbers with cnc_rdprgnumo8
+func GetProgramNumbers(handle *uint16) (int32, int32, int16) {
+	var buf C.ODBPROO8
+	ret := C.cnc_rdprgnumo8(C.ushort(*handle), &buf)
+	if ret == C.EW_OK {
+		return int32(buf.data), int32(buf.mdata), 0
+	}
+	if ret != C.EW_FUNC && ret != C.EW_NOOPT {
+		return 0, 0, int16(ret)
+	}
+	var buf_o4 C.ODBPRO
+	ret = C.cnc_rdprgnum(C.ushort(*handle), &buf_o4)
 	if ret != C.EW_OK {
-		return 0, int16(ret)
+		return 0, 0, int16(ret)
 	}
-	return int16(buf.mdata), 0
+	return int32(buf_o4.data), int32(buf_o4.mdata), 0
+}
+
+func GetMainProgNum(handle *uint16) (int32, int16) {
+	_, main_program, ret := GetProgramNumbers(handle)
+	return main_program, ret
+}
+
+func GetSubProgNum(handle *uint16) (int32, int16) {
+	program, _, ret := GetProgramNumbers(handle)
+	return program, ret
 }
 
-func GetSubProgNum(handle *uint16) (int16, int16) {
-	var buf C.ODBPRO
-	ret := C.cnc_rdprgnum(C.ushort(*handle), &buf)
+// full path of the running program, //CNC_MEM/USER/PATH1/O1234
+func GetProgramPath(handle *uint16) (string, int16) {
+	var buf [256]C.char
+	ret := C.cnc_exeprgname2(C.ushort(*handle), &buf[0])
 	if ret != C.EW_OK {
-		return 0, int16(ret)
+		return "", int16(ret)
+	}
+	return C.GoString(&buf[0]), 0
+}
+
+// programs of the memory with cnc_rdprogdir3, of the folder with cnc_rdpdf_alldir
+func GetProgramDirectory(handle *uint16, folder string) ([]CncProgram, int16) {
+	if folder != "" {
+		return GetProgramFolder(handle, folder)
+	}
+	result := make([]CncProgram, 0)
+	top := C.long(0)
+	for {
+		var buf [program_dir_batch]C.PRGDIR3
+		num := C.short(program_dir_batch)
+		ret := C.cnc_rdprogdir3(C.ushort(*handle), C.short(2), &top, &num, &buf[0])
+		if ret != C.EW_OK {
+			return make([]CncProgram, 0), int16(ret)
+		}
+		for _, entry := range buf[:num] {
+			date := entry.mdate
+			result = append(result, CncProgram{
+				Number:   int32(entry.number),
+				Name:     FormatProgramName(int32(entry.number)),
+				Comment:  C.GoString(&entry.comment[0]),
+				Size:     int32(entry.length),
+				Modified: FormatProgramDate(int16(date.year), int16(date.month), int16(date.day), int16(date.hour), int16(date.minute)),
+			})
+		}
+		if num < program_dir_batch {
+			return result, 0
+		}
+		top = buf[num-1].number + 1
+	}
+}
+
+// program text from % to %, path is the program name or the full file path
+func UploadProgram(handle *uint16, path string) ([]byte, int16) {
+	c_path := C.CString(path)
+	defer C.free(unsafe.Pointer(c_path))
+	ret := C.cnc_upstart4(C.ushort(*handle), C.short(0), c_path)
+	if ret != C.EW_OK {
+		return nil, int16(ret)
+	}
+	var content []byte
+	var buf [1280]C.char
+	buffer_attempts := 0
+	for {
+		length := C.long(len(buf))
+		ret = C.cnc_upload4(C.ushort(*handle), &length, &buf[0])
+		if ret == C.EW_BUFFER {
+			buffer_attempts++
+			if buffer_attempts >= upload_buffer_attempts {
+				C.cnc_upend4(C.ushort(*handle))
+				return nil, int16(ret)
+			}
+			time.Sleep(upload_buffer_delay)
+			continue
+		}
+		buffer_attempts = 0
+		if ret != C.EW_OK {
+			C.cnc_upend4(C.ushort(*handle))
+			return nil, int16(ret)
+		}
+		content = append(content, C.GoBytes(unsafe.Pointer(&buf[0]), C.int(length))...)
+		// the first % starts the program
+		if length > 0 && buf[length-1] == '%' && len(content) > 1 {
+			break
+		}
+	}
+	ret = C.cnc_upend4(C.ushort(*handle))
+	if ret != C.EW_OK {
+		return nil, int16(ret)
+	}
+	return content, 0
+}
+
+func GetProgramFolder(handle *uint16, folder string) ([]CncProgram, int16) {
+	result := make([]CncProgram, 0)
+	var request C.IDBPDFADIR
+	for index := range min(len(folder), len(request.path)-1) {
+		request.path[index] = C.char(folder[index])
+	}
+	request._type = 1
+	// entries read so far with folders, only files are listed
+	req_num := 0
+	for {
+		var buf [program_dir_batch]C.ODBPDFADIR
+		num := C.short(program_dir_batch)
+		request.req_num = C.short(req_num)
+		ret := C.cnc_rdpdf_alldir(C.ushort(*handle), &num, &request, &buf[0])
+		if ret != C.EW_OK {
+			return make([]CncProgram, 0), int16(ret)
+		}
+		req_num += int(num)
+		for _, entry := range buf[:num] {
+			// 0 - folder, 1 - file
+			if entry.data_kind != 1 {
+				continue
+			}
+			result = append(result, CncProgram{
+				Name:     C.GoString(&entry.d_f[0]),
+				Comment:  C.GoString(&entry.comment[0]),
+				Size:     int32(entry.size),
+				Modified: FormatProgramDate(int16(entry.year), int16(entry.mon), int16(entry.day), int16(entry.hour), int16(entry.min)),
+			})
+		}
+		if num < program_dir_batch {
+			return result, 0
+		}
 	}
-	return int16(buf.data), 0
 }
 
 func GetFrameNumber(handle *uint16) (int64, int16) {
@@ -216,19 +472,8 @@ func GetFrame(handle *uint16) (string, int16) {
 	if ret != C.EW_OK {
 		return "", int16(ret)
 	}
-	go_str := C.GoString(&buf[0])
-	splitted_str := strings.Split(go_str, "\n")
-	find_part := "N"
 	frame_number, frame_number_error := GetFrameNumber(handle)
-	if frame_number_error == 0 && frame_number > 0 {
-		find_part = fmt.Sprintf("N%d", frame_number)
-	}
-	for _, check_str := range splitted_str {
-		if strings.Contains(check_str, find_part) {
-			return check_str, 0
-		}
-	}
-	return splitted_str[0], 0
+	return ParseFrame(C.GoString(&buf[0]), frame_number, frame_number_error), 0
 }
 
 func GetPartsCount(handle *uint16) (int64, int16) {
@@ -250,6 +495,148 @@ func GetToolNumber(handle *uint16) (int64, int16) {
 	return int64(buf.data), 0
 }
 
+// Tool offset functions
+func GetToolOffsetInfo(handle *uint16) (CncToolOffsetInfo, int16) {
+	var sys_info C.ODBSYS
+	ret := C.cnc_sysinfo(C.ushort(*handle), &sys_info)
+	if ret != C.EW_OK {
+		return CncToolOffsetInfo{}, int16(ret)
+	}
+	var buf C.ODBTLINF
+	ret = C.cnc_rdtofsinfo(C.ushort(*handle), &buf)
+	if ret != C.EW_OK {
+		return CncToolOffsetInfo{}, int16(ret)
+	}
+	mt_type := C.GoStringN((*C.char)(unsafe.Pointer(&sys_info.mt_type[0])), 2)
+	return CncToolOffsetInfo{MtType: mt_type, Type: int16(buf.ofs_type), Count: int16(buf.use_no)}, 0
+}
+
+// one offset type of the offsets from start to end
+func GetToolOffsets(handle *uint16, offset_type int16, start int16, end int16) ([]int32, int16) {
+	count := int(end - start + 1)
+	var header C.IODBTO
+	length := unsafe.Offsetof(header.u) + uintptr(count)*unsafe.Sizeof(C.long(0))
+	buf := make([]byte, max(length, unsafe.Sizeof(header)))
+	ret := C.cnc_rdtofsr(C.ushort(*handle), C.short(start), C.short(offset_type), C.short(end), C.short(length), (*C.IODBTO)(unsafe.Pointer(&buf[0])))
+	if ret != C.EW_OK {
+		return nil, int16(ret)
+	}
+	data := unsafe.Slice((*C.long)(unsafe.Pointer(&buf[unsafe.Offsetof(header.u)])), count)
+	result := make([]int32, 0, count)
+	for _, value := range data {
+		result = append(result, int32(value))
+	}
+	return result, 0
+}
+
+// Tool life functions
+func GetToolLifeInfo(handle *uint16) (CncToolLifeInfo, int16) {
+	var info C.ODBTLINFO
+	ret := C.cnc_rdtlinfo(C.ushort(*handle), &info)
+	if ret != C.EW_OK {
+		return CncToolLifeInfo{}, int16(ret)
+	}
+	var use_group C.ODBUSEGRP
+	ret = C.cnc_rdtlusegrp(C.ushort(*handle), &use_group)
+	if ret != C.EW_OK {
+		return CncToolLifeInfo{}, int16(ret)
+	}
+	return CncToolLifeInfo{
+		MaxGroups: int32(info.max_group),
+		MaxTools:  int32(info.max_tool),
+		UseGroup:  int32(use_group.use),
+		NextGroup: int32(use_group.next),
+	}, 0
+}
+
+// count groups from start
+func GetToolLifeGroups(handle *uint16, start int32, count int16) ([]CncToolLifeGroup, int16) {
+	num := C.short(count)
+	buf := make([]C.IODBTLGRP, count)
+	ret := C.cnc_rdtlgrp(C.ushort(*handle), C.long(start), &num, &buf[0])
+	if ret != C.EW_OK {
+		return nil, int16(ret)
+	}
+	result := make([]CncToolLifeGroup, 0, num)
+	for index, group := range buf[:num] {
+		result = append(result, CncToolLifeGroup{
+			Group:      start + int32(index),
+			ToolCount:  int32(group.ntool),
+			Life:       int32(group.life),
+			Count:      int32(group.count),
+			UseTool:    int32(group.use_tool),
+			RestSignal: group.rest_sig != 0,
+			CountType:  int16(group.count_type),
+		})
+	}
+	return result, 0
+}
+
+// count tools of the group from the first one
+func GetToolLifeTools(handle *uint16, group int32, count int16) ([]CncToolLifeTool, int16) {
+	num := C.short(count)
+	buf := make([]C.IODBTLTOOL, count)
+	ret := C.cnc_rdtltool(C.ushort(*handle), C.long(group), C.long(1), &num, &buf[0])
+	if ret != C.EW_OK {
+		return nil, int16(ret)
+	}
+	result := make([]CncToolLifeTool, 0, num)
+	for _, tool := range buf[:num] {
+		result = append(result, CncToolLifeTool{Number: int32(tool.tool_num), Info: int32(tool.tool_inf)})
+	}
+	return result, 0
+}
+
+// Work offset functions
+// number of the G54.1 offsets
+func GetWorkOffsetCount(handle *uint16) (int16, int16) {
+	var count C.short
+	ret := C.cnc_rdzofsinfo(C.ushort(*handle), &count)
+	if ret != C.EW_OK {
+		return 0, int16(ret)
+	}
+	return int16(count), 0
+}
+
+// offsets from start to end of all axes, 0 - external, 1-6 - G54-G59, 7 - G54.1 P1
+func GetWorkOffsets(handle *uint16, start int16, end int16, axes int16) ([][]int32, int16) {
+	count := int(end-start+1) * int(axes)
+	var header C.IODBZOR
+	length := unsafe.Offsetof(header.data) + uintptr(count)*unsafe.Sizeof(C.long(0))
+	buf := make([]byte, max(length, unsafe.Sizeof(header)))
+	ret := C.cnc_rdzofsr(C.ushort(*handle), C.short(start), C.short(-1), C.short(end), C.short(length), (*C.IODBZOR)(unsafe.Pointer(&buf[0])))
+	if ret != C.EW_OK {
+		return nil, int16(ret)
+	}
+	data := unsafe.Slice((*C.long)(unsafe.Pointer(&buf[unsafe.Offsetof(header.data)])), count)
+	result := make([][]int32, 0, end-start+1)
+	for offset := range int(end - start + 1) {
+		values := make([]int32, 0, axes)
+		for _, value := range data[offset*int(axes) : (offset+1)*int(axes)] {
+			values = append(values, int32(value))
+		}
+		result = append(result, values)
+	}
+	return result, 0
+}
+
+// work coordinate shift of T series
+func GetWorkShift(handle *uint16, axes int16) ([]int32, int16) {
+	var buf C.IODBWCSF
+	// the buffer holds MAX_AXIS values
+	count := max(min(int(axes), len(buf.data)), 0)
+	length := unsafe.Offsetof(buf.data) + uintptr(count)*unsafe.Sizeof(C.long(0))
+	ret := C.cnc_rdwkcdshft(C.ushort(*handle), C.short(-1), C.short(length), &buf)
+	if ret != C.EW_OK {
+		return nil, int16(ret)
+	}
+	result := make([]int32, 0, count)
+	for _, value := range buf.data[:count] {
+		result = append(result, int32(value))
+	}
+	return result, 0
+}
+
 // Axis functions
 func GetAbsolutePositions(handle *uint16) (map[string]float64, int16) {
 	result := make(map[string]float64)
@@ -305,6 +692,44 @@ func GetMachinePositions(handle *uint16) (map[string]float64, int16) {
 	return result, 0
 }
 
+func GetDistanceToGo(handle *uint16) (map[string]float64, int16) {
+	result := make(map[string]float64)
+	num := C.get_max_axis()
+	buf := make([]C.ODBPOS, int(num))
+	ret := C.cnc_rdposition(C.ushort(*handle), C.short(3), &num, (*C.ODBPOS)(unsafe.Pointer(&buf[0])))
+	if ret != C.EW_OK {
+		return result, int16(ret)
+	}
+	for _, data := range buf {
+		name := string(byte(data.dist.name))
+		if name == "" || strings.ContainsRune(name, '\u0000') {
+			continue
+		}
+		result[name] = float64(data.dist.data) * math.Pow(10, -float64(data.dist.dec))
+	}
+	return result, 0
+}
+
+// end point of the block, absolute position and distance to go of one call
+func GetCommandedPositions(handle *uint16) (map[string]float64, int16) {
+	result := make(map[string]float64)
+	num := C.get_max_axis()
+	buf := make([]C.ODBPOS, int(num))
+	ret := C.cnc_rdposition(C.ushort(*handle), C.short(-1), &num, (*C.ODBPOS)(unsafe.Pointer(&buf[0])))
+	if ret != C.EW_OK {
+		return result, int16(ret)
+	}
+	for _, data := range buf {
+		name := string(byte(data.abs.name))
+		if name == "" || strings.ContainsRune(name, '\u0000') {
+			continue
+		}
+		absolute := float64(data.abs.data) * math.Pow(10, -float64(data.abs.dec))
+		result[name] = absolute + float64(data.dist.data)*math.Pow(10, -float64(data.dist.dec))
+	}
+	return result, 0
+}
+
 func GetFeedRate(handle *uint16) (float64, int16) {
 	var buf C.ODBSPEED
 	ret := C.cnc_rdspeed(C.ushort(*handle), C.short(0), &buf)
@@ -515,6 +940,20 @@ func GetSpindleLoad(handle *uint16) (map[string]int64, int16) {
 	return result, 0
 }
 
+// alarm number of every spindle, 0 - no alarm
+func GetSpindleAlarms(handle *uint16) ([]int16, int16) {
+	buf := make([]C.char, C.get_max_spindles())
+	ret := C.cnc_rdspdlalm(C.ushort(*handle), &buf[0])
+	if ret != C.EW_OK {
+		return nil, int16(ret)
+	}
+	result := make([]int16, 0, len(buf))
+	for _, alarm := range buf {
+		result = append(result, int16(uint8(alarm)))
+	}
+	return result, 0
+}
+
 // only 15i function
 func GetSpindleOverride(handle *uint16) (int16, int16) {
 	var buf C.IODBSGNL
@@ -544,6 +983,70 @@ func GetAlarm(handle *uint16) (int16, int16) {
 	return int16(buf.alarm), 0
 }
 
+func GetAlarmMessages(handle *uint16) ([]CncAlarmMessage, int16) {
+	result := make([]CncAlarmMessage, 0)
+	num := C.short(max_alarm_messages)
+	buf := make([]C.ODBALMMSG2, max_alarm_messages)
+	ret := C.cnc_rdalmmsg2(C.ushort(*handle), C.short(-1), &num, &buf[0])
+	if ret != C.EW_OK {
+		return result, int16(ret)
+	}
+	for _, alarm := range buf[:num] {
+		length := min(int(alarm.msg_len), len(alarm.alm_msg))
+		message := C.GoStringN(&alarm.alm_msg[0], C.int(length))
+		result = append(result, NewAlarmMessage(int32(alarm.alm_no), int16(alarm._type), int16(alarm.axis), message))
+	}
+	return result, 0
+}
+
+func GetAlarmHistoryCount(handle *uint16) (int32, int16) {
+	var hisno C.ushort
+	ret := C.cnc_rdalmhisno(C.ushort(*handle), &hisno)
+	if ret != C.EW_OK {
+		return 0, int16(ret)
+	}
+	return int32(hisno), 0
+}
+
+// numbers from 1 (latest alarm), at most alarm_history_batch entries
+func GetAlarmHistory(handle *uint16, start int32, end int32) ([]CncAlarmHistoryEntry, int16) {
+	result := make([]CncAlarmHistoryEntry, 0)
+	var buf C.ODBAHIS
+	ret := C.cnc_rdalmhistry(C.ushort(*handle), C.ushort(start), C.ushort(end), C.ushort(unsafe.Sizeof(buf)), &buf)
+	if ret != C.EW_OK {
+		return result, int16(ret)
+	}
+	count := min(int(buf.e_no)-int(buf.s_no)+1, len(buf.alm_his))
+	for _, alarm := range buf.alm_his[:max(count, 0)] {
+		length := min(int(alarm.len_msg), len(alarm.alm_msg))
+		message := C.GoStringN(&alarm.alm_msg[0], C.int(length))
+		result = append(result, NewAlarmHistoryEntry(
+			int(alarm.year), int(alarm.month), int(alarm.day),
+			int(alarm.hour), int(alarm.minute), int(alarm.second),
+			int32(alarm.alm_no), int16(alarm.alm_grp), int16(alarm.axis_no), message))
+	}
+	return result, 0
+}
+
+func GetOperatorMessages(handle *uint16) ([]CncOperatorMessage, int16) {
+	result := make([]CncOperatorMessage, 0)
+	var buf [max_operator_messages]C.OPMSG3
+	length := C.short(unsafe.Sizeof(buf))
+	ret := C.cnc_rdopmsg3(C.ushort(*handle), C.short(-1), &length, &buf[0])
+	if ret != C.EW_OK {
+		return result, int16(ret)
+	}
+	for _, message := range buf {
+		if message.datano == -1 {
+			continue
+		}
+		count := min(int(message.char_num), len(message.data))
+		data := C.GoBytes(unsafe.Pointer(&message.data[0]), C.int(count))
+		result = append(result, CncOperatorMessage{Number: int16(message.datano), Type: int16(message._type), Data: data})
+	}
+	return result, 0
+}
+
 // Operating functions
 func GetPowerOnTime(handle *uint16) (int64, int16) {
 	var buf C.IODBPSD
@@ -568,7 +1071,7 @@ func GetOperationTime(handle *uint16) (float64, int16) {
 		return 0, int16(ret)
 	}
 	rdata_2 := (*C.REALPRM)(unsafe.Pointer(&buf_2.u[0]))
-	return float64(rdata_2.prm_val)*60 + float64(rdata_1.prm_val)/1000.0, 0
+	return JoinTimeParams(int64(rdata_1.prm_val), int64(rdata_2.prm_val)), 0
 }
 
 func GetCuttingTime(handle *uint16) (float64, int16) {
@@ -584,7 +1087,7 @@ func GetCuttingTime(handle *uint16) (float64, int16) {
 		return 0, int16(ret)
 	}
 	rdata_2 := (*C.REALPRM)(unsafe.Pointer(&buf_2.u[0]))
-	return float64(rdata_2.prm_val)*60 + float64(rdata_1.prm_val)/1000.0, 0
+	return JoinTimeParams(int64(rdata_1.prm_val), int64(rdata_2.prm_val)), 0
 }
 
 func GetCycleTime(handle *uint16) (float64, int16) {
@@ -600,7 +1103,17 @@ func GetCycleTime(handle *uint16) (float64, int16) {
 		return 0, int16(ret)
 	}
 	rdata_2 := (*C.REALPRM)(unsafe.Pointer(&buf_2.u[0]))
-	return float64(rdata_2.prm_val)*60 + float64(rdata_1.prm_val)/1000.0, 0
+	return JoinTimeParams(int64(rdata_1.prm_val), int64(rdata_2.prm_val)), 0
+}
+
+// 0 - power on, 1 - operating, 2 - cutting, 3 - cycle, 4 - free purpose
+func GetTimer(handle *uint16, timer_type int16) (CncTimer, int16) {
+	var buf C.IODBTIME
+	ret := C.cnc_rdtimer(C.ushort(*handle), C.short(timer_type), &buf)
+	if ret != C.EW_OK {
+		return CncTimer{}, int16(ret)
+	}
+	return CncTimer{Minute: int32(buf.minute), Msec: int32(buf.msec)}, 0
 }
 
 func GetSeriesNumber(handle *uint16) (string, int16) {
@@ -664,5 +1177,102 @@ func GetCncId(handle *uint16) (string, int16) {
 	if ret != C.EW_OK {
 		return "", int16(ret)
 	}
-	return fmt.Sprintf("%08X-%08X-%08X-%08X", cnc_ids[0], cnc_ids[1], cnc_ids[2], cnc_ids[3]), 0
+	return FormatCncId(cnc_ids), 0
+}
+
+// PMC functions
+// bytes from start to end address of the area
+func ReadPmcRange(handle *uint16, area int16, start uint16, end uint16) ([]byte, int16) {
+	count := int(end) - int(start) + 1
+	// IODBPMC header and data
+	buf := make([]byte, 8+max(count, 8))
+	ret := C.pmc_rdpmcrng(C.ushort(*handle), C.short(area), C.short(0), C.ushort(start), C.ushort(end), C.ushort(8+count), (*C.IODBPMC)(unsafe.Pointer(&buf[0])))
+	if ret != C.EW_OK {
+		return nil, int16(ret)
+	}
+	return buf[8 : 8+count], 0
+}
+
+// Macro functions
+// variables from start to end number, in batches of cnc_rdmacror2
+func ReadMacroRange(handle *uint16, start int32, end int32) ([]CncMacroValue, int16) {
+	values := make([]CncMacroValue, 0, end-start+1)
+	for batch_start := start; batch_start <= end; batch_start += macro_batch {
+		batch_end := min(batch_start+macro_batch-1, end)
+		data := make([]C.double, batch_end-batch_start+1)
+		num := C.ulong(len(data))
+		ret := C.cnc_rdmacror2(C.ushort(*handle), C.ulong(batch_start), &num, &data[0])
+		if ret != C.EW_OK {
+			return nil, int16(ret)
+		}
+		if int(num) != len(data) {
+			return nil, EW_LENGTH
+		}
+		for _, value := range data {
+			values = append(values, NewMacroDouble(float64(value)))
+		}
+	}
+	return values, 0
+}
+
+// Parameter and diagnosis functions
+// union member of IODBPSD and ODBDGN by data size
+func GetUnionValue(data unsafe.Pointer, size int16) CncDataValue {
+	switch size {
+	case 1:
+		return CncDataValue{Value: int32(*(*C.char)(data))}
+	case 2:
+		return CncDataValue{Value: int32(*(*C.short)(data))}
+	case 4:
+		return CncDataValue{Value: int32(*(*C.long)(data))}
+	}
+	rdata := (*C.REALPRM)(data)
+	return CncDataValue{Value: int32(rdata.prm_val), Decimal: int32(rdata.dec_val)}
+}
+
+// IODBPRM of cnc_rdparam_ext and cnc_rddiag_ext
+func GetExtValue(buf *C.IODBPRM, axis int16) CncDataValue {
+	index := 0
+	if axis > 0 {
+		index = int(axis) - 1
+	}
+	return CncDataValue{Value: int32(buf.data[index].prm_val), Decimal: int32(buf.data[index].dec_val)}
+}
+
+// numbers out of short range are read with cnc_rdparam_ext
+func ReadParameter(handle *uint16, number int32, axis int16, size int16) (CncDataValue, int16) {
+	if number > math.MaxInt16 {
+		var buf C.IODBPRM
+		prm_number := C.long(number)
+		ret := C.cnc_rdparam_ext(C.ushort(*handle), &prm_number, 1, &buf)
+		if ret != C.EW_OK {
+			return CncDataValue{}, int16(ret)
+		}
+		return GetExtValue(&buf, axis), 0
+	}
+	var buf C.IODBPSD
+	ret := C.cnc_rdparam(C.ushort(*handle), C.short(number), C.short(axis), C.short(4+size), &buf)
+	if ret != C.EW_OK {
+		return CncDataValue{}, int16(ret)
+	}
+	return GetUnionValue(unsafe.Pointer(&buf.u[0]), size), 0
+}
+
+// numbers out of short range are read with cnc_rddiag_ext
+func ReadDiagnosis(handle *uint16, number int32, axis int16, size int16) (CncDataValue, int16) {
+	if number > math.MaxInt16 {
+		var buf C.IODBPRM
+		diag_number := C.long(number)
+		ret := C.cnc_rddiag_ext(C.ushort(*handle), &diag_number, 1, &buf)
+		if ret != C.EW_OK {
+			return CncDataValue{}, int16(ret)
+		}
+		return GetExtValue(&buf, axis), 0
+	}
+	var buf C.ODBDGN
+	ret := C.cnc_diagnoss(C.ushort(*handle), C.short(number), C.short(axis), C.short(4+size), &buf)
+	if ret != C.EW_OK {
+		return CncDataValue{}, int16(ret)
+	}
+	return GetUnionValue(unsafe.Pointer(&buf.u[0]), size), 0
 }
@@ -0,0 +1,349 @@
+//go:build windows || fwlib
+
+package main
+
+const fwlib_available = true
+
+type FwlibClient struct {
+	handle uint16
+}
+
+func NewFwlibClient() CNCClient {
+	return &FwlibClient{}
+}
+
+func (client *FwlibClient) Connect(address string, port int, timeout int) int16 {
+	handle, handle_error := GetHandleWithTimeout(address, port, timeout)
+	if handle_error != 0 {
+		return handle_error
+	}
+	client.handle = handle
+	return 0
+}
+
+func (client *FwlibClient) Free() int16 {
+	if client.handle == 0 {
+		return EW_HANDLE
+	}
+	free_handle_error := FreeHandle(&client.handle)
+	if free_handle_error == 0 || free_handle_error == EW_HANDLE {
+		client.handle = 0
+	}
+	return free_handle_error
+}
+
+func (client *FwlibClient) Handle() uint16 {
+	return client.handle
+}
+
+func (client *FwlibClient) GetMaxPath() (int16, int16) {
+	return GetMaxPath(&client.handle)
+}
+
+func (client *FwlibClient) SetPath(path int16) int16 {
+	return SetPath(&client.handle, path)
+}
+
+func (client *FwlibClient) GetAxisNames() ([]string, int16) {
+	return GetAxisNames(&client.handle)
+}
+
+func (client *FwlibClient) GetSpindleNames() ([]string, int16) {
+	return GetSpindleNames(&client.handle)
+}
+
+func (client *FwlibClient) GetStatInfo() (CncStatInfo, int16) {
+	return GetStatInfo(&client.handle)
+}
+
+func (client *FwlibClient) GetExecProgram() (string, int16) {
+	return GetExecProgram(&client.handle)
+}
+
+func (client *FwlibClient) GetExecBlocks() (CncExecBlocks, int16) {
+	return GetExecBlocks(&client.handle)
+}
+
+func (client *FwlibClient) GetBlockCount() (int64, int16) {
+	return GetBlockCount(&client.handle)
+}
+
+func (client *FwlibClient) GetTimerParams(numbers []int32) (map[int32]int64, int16) {
+	return GetTimerParams(&client.handle, numbers)
+}
+
+func (client *FwlibClient) GetAut() (int16, int16) {
+	return GetAut(&client.handle)
+}
+
+func (client *FwlibClient) GetRun() (int16, int16) {
+	return GetRun(&client.handle)
+}
+
+func (client *FwlibClient) GetEdit() (int16, int16) {
+	return GetEdit(&client.handle)
+}
+
+func (client *FwlibClient) GetMstb() (int16, int16) {
+	return GetMstb(&client.handle)
+}
+
+func (client *FwlibClient) GetMotion() (int16, int16) {
+	return GetMotion(&client.handle)
+}
+
+func (client *FwlibClient) GetG00() (int16, int16) {
+	return GetG00(&client.handle)
+}
+
+func (client *FwlibClient) GetGCodes() ([]CncGCode, int16) {
+	return GetGCodes(&client.handle)
+}
+
+func (client *FwlibClient) GetModalCodes() (map[string]int32, int16) {
+	return GetModalCodes(&client.handle)
+}
+
+func (client *FwlibClient) GetShutdowns() (int16, int16) {
+	return GetShutdowns(&client.handle)
+}
+
+func (client *FwlibClient) GetLoadExcess() (int16, int16) {
+	return GetLoadExcess(&client.handle)
+}
+
+func (client *FwlibClient) GetMainProgNum() (int32, int16) {
+	return GetMainProgNum(&client.handle)
+}
+
+func (client *FwlibClient) GetSubProgNum() (int32, int16) {
+	return GetSubProgNum(&client.handle)
+}
+
+func (client *FwlibClient) GetProgramPath() (string, int16) {
+	return GetProgramPath(&client.handle)
+}
+
+func (client *FwlibClient) GetProgramDirectory(folder string) ([]CncProgram, int16) {
+	return GetProgramDirectory(&client.handle, folder)
+}
+
+func (client *FwlibClient) UploadProgram(path string) ([]byte, int16) {
+	return UploadProgram(&client.handle, path)
+}
+
+func (client *FwlibClient) GetFrameNumber() (int64, int16) {
+	return GetFrameNumber(&client.handle)
+}
+
+func (client *FwlibClient) GetFrame() (string, int16) {
+	return GetFrame(&client.handle)
+}
+
+func (client *FwlibClient) GetPartsCount() (int64, int16) {
+	return GetPartsCount(&client.handle)
+}
+
+func (client *FwlibClient) GetToolNumber() (int64, int16) {
+	return GetToolNumber(&client.handle)
+}
+
+func (client *FwlibClient) GetToolOffsetInfo() (CncToolOffsetInfo, int16) {
+	return GetToolOffsetInfo(&client.handle)
+}
+
+func (client *FwlibClient) GetToolOffsets(offset_type int16, start int16, end int16) ([]int32, int16) {
+	return GetToolOffsets(&client.handle, offset_type, start, end)
+}
+
+func (client *FwlibClient) GetToolLifeInfo() (CncToolLifeInfo, int16) {
+	return GetToolLifeInfo(&client.handle)
+}
+
+func (client *FwlibClient) GetToolLifeGroups(start int32, count int16) ([]CncToolLifeGroup, int16) {
+	return GetToolLifeGroups(&client.handle, start, count)
+}
+
+func (client *FwlibClient) GetToolLifeTools(group int32, count int16) ([]CncToolLifeTool, int16) {
+	return GetToolLifeTools(&client.handle, group, count)
+}
+
+func (client *FwlibClient) GetWorkOffsetCount() (int16, int16) {
+	return GetWorkOffsetCount(&client.handle)
+}
+
+func (client *FwlibClient) GetWorkOffsets(start int16, end int16, axes int16) ([][]int32, int16) {
+	return GetWorkOffsets(&client.handle, start, end, axes)
+}
+
+func (client *FwlibClient) GetWorkShift(axes int16) ([]int32, int16) {
+	return GetWorkShift(&client.handle, axes)
+}
+
+func (client *FwlibClient) GetAbsolutePositions() (map[string]float64, int16) {
+	return GetAbsolutePositions(&client.handle)
+}
+
+func (client *FwlibClient) GetRelativePositions() (map[string]float64, int16) {
+	return GetRelativePositions(&client.handle)
+}
+
+func (client *FwlibClient) GetMachinePositions() (map[string]float64, int16) {
+	return GetMachinePositions(&client.handle)
+}
+
+func (client *FwlibClient) GetDistanceToGo() (map[string]float64, int16) {
+	return GetDistanceToGo(&client.handle)
+}
+
+func (client *FwlibClient) GetCommandedPositions() (map[string]float64, int16) {
+	return GetCommandedPositions(&client.handle)
+}
+
+func (client *FwlibClient) GetFeedRate() (float64, int16) {
+	return GetFeedRate(&client.handle)
+}
+
+func (client *FwlibClient) GetFeedRateParam1() (map[string]float64, int16) {
+	return GetFeedRateParam1(&client.handle)
+}
+
+func (client *FwlibClient) GetFeedRateParam2() (map[string]float64, int16) {
+	return GetFeedRateParam2(&client.handle)
+}
+
+func (client *FwlibClient) GetFeedOverride() (int16, int16) {
+	return GetFeedOverride(&client.handle)
+}
+
+func (client *FwlibClient) GetJogOverride() (int16, int16) {
+	return GetJogOverride(&client.handle)
+}
+
+func (client *FwlibClient) GetJogSpeed() (map[string]float64, int16) {
+	return GetJogSpeed(&client.handle)
+}
+
+func (client *FwlibClient) GetServoLoad() (map[string]int64, int16) {
+	return GetServoLoad(&client.handle)
+}
+
+func (client *FwlibClient) GetServoCurrentLoad() (map[string]float64, int16) {
+	return GetServoCurrentLoad(&client.handle)
+}
+
+func (client *FwlibClient) GetServoCurrentLoadPercent() (map[string]int64, int16) {
+	return GetServoCurrentLoadPercent(&client.handle)
+}
+
+func (client *FwlibClient) GetSpindleSpeed() (float64, int16) {
+	return GetSpindleSpeed(&client.handle)
+}
+
+func (client *FwlibClient) GetSpindleSpeedParam() (map[string]int64, int16) {
+	return GetSpindleSpeedParam(&client.handle)
+}
+
+func (client *FwlibClient) GetSpindleMotorSpeed() (map[string]int64, int16) {
+	return GetSpindleMotorSpeed(&client.handle)
+}
+
+func (client *FwlibClient) GetSpindleLoad() (map[string]int64, int16) {
+	return GetSpindleLoad(&client.handle)
+}
+
+func (client *FwlibClient) GetSpindleAlarms() ([]int16, int16) {
+	return GetSpindleAlarms(&client.handle)
+}
+
+func (client *FwlibClient) GetSpindleOverride() (int16, int16) {
+	return GetSpindleOverride(&client.handle)
+}
+
+func (client *FwlibClient) GetEmergency() (int16, int16) {
+	return GetEmergency(&client.handle)
+}
+
+func (client *FwlibClient) GetAlarm() (int16, int16) {
+	return GetAlarm(&client.handle)
+}
+
+func (client *FwlibClient) GetAlarmMessages() ([]CncAlarmMessage, int16) {
+	return GetAlarmMessages(&client.handle)
+}
+
+func (client *FwlibClient) GetAlarmHistoryCount() (int32, int16) {
+	return GetAlarmHistoryCount(&client.handle)
+}
+
+func (client *FwlibClient) GetAlarmHistory(start int32, end int32) ([]CncAlarmHistoryEntry, int16) {
+	return GetAlarmHistory(&client.handle, start, end)
+}
+
+func (client *FwlibClient) GetOperatorMessages() ([]CncOperatorMessage, int16) {
+	return GetOperatorMessages(&client.handle)
+}
+
+func (client *FwlibClient) GetPowerOnTime() (int64, int16) {
+	return GetPowerOnTime(&client.handle)
+}
+
+func (client *FwlibClient) GetOperationTime() (float64, int16) {
+	return GetOperationTime(&client.handle)
+}
+
+func (client *FwlibClient) GetCuttingTime() (float64, int16) {
+	return GetCuttingTime(&client.handle)
+}
+
+func (client *FwlibClient) GetCycleTime() (float64, int16) {
+	return GetCycleTime(&client.handle)
+}
+
+func (client *FwlibClient) GetTimer(timer_type int16) (CncTimer, int16) {
+	return GetTimer(&client.handle, timer_type)
+}
+
+func (client *FwlibClient) GetSeriesNumber() (string, int16) {
+	return GetSeriesNumber(&client.handle)
+}
+
+func (client *FwlibClient) GetVersionNumber() (string, int16) {
+	return GetVersionNumber(&client.handle)
+}
+
+func (client *FwlibClient) GetCtrlAxesNumber() (int16, int16) {
+	return GetCtrlAxesNumber(&client.handle)
+}
+
+func (client *FwlibClient) GetCtrlSpindlesNumber() (int16, int16) {
+	return GetCtrlSpindlesNumber(&client.handle)
+}
+
+func (client *FwlibClient) GetCtrlPathsNumber() (int16, int16) {
+	return GetCtrlPathsNumber(&client.handle)
+}
+
+func (client *FwlibClient) GetSerialNumber() (int64, int16) {
+	return GetSerialNumber(&client.handle)
+}
+
+func (client *FwlibClient) GetCncId() (string, int16) {
+	return GetCncId(&client.handle)
+}
+
+func (client *FwlibClient) ReadPmcRange(area int16, start uint16, end uint16) ([]byte, int16) {
+	return ReadPmcRange(&client.handle, area, start, end)
+}
+
+func (client *FwlibClient) ReadMacroRange(start int32, end int32) ([]CncMacroValue, int16) {
+	return ReadMacroRange(&client.handle, start, end)
+}
+
+func (client *FwlibClient) ReadParameter(number int32, axis int16, size int16) (CncDataValue, int16) {
+	return ReadParameter(&client.handle, number, axis, size)
+}
+
+func (client *FwlibClient) ReadDiagnosis(number int32, axis int16, size int16) (CncDataValue, int16) {
+	return ReadDiagnosis(&client.handle, number, axis, size)
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"slices"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -136,12 +137,14 @@ func DataCollector(device Device, timeout int, global_handle *uint16, running *b
 	var handle uint16 = 0
 	var handle_error int16 = 0
 	var json_data string
+	client := new_cnc_client(&device)
+	connected := false
 	reconnect_counter := 0
 	max_reconnect := 5
 	// free handle
 	defer func() {
-		if handle != 0 {
-			free_handle_error := FreeHandle(&handle)
+		if connected {
+			free_handle_error := client.Free()
 			if free_handle_error != 0 && free_handle_error != -8 {
 				logger.Printf("Ошибка освобождения дескриптора %s, error: %d", device.Name, free_handle_error)
 			} else {
@@ -158,7 +161,7 @@ func DataCollector(device Device, timeout int, global_handle *uint16, running *b
 	// try connect to device
 	for connect_count <= max_connect {
 		connect_count++
-		if IsConnectAlive(device.Address, device.Port, 10*time.Second, running) {
+		if IsDeviceAlive(&device, client, running) {
 			connect_count = 0
 			break
 		}
@@ -182,10 +185,11 @@ func DataCollector(device Device, timeout int, global_handle *uint16, running *b
 	// try get handle
 	for get_handle_count <= max_get_handle {
 		get_handle_count++
-		handle, handle_error = GetHandleWithTimeout(device.Address, device.Port, timeout)
+		handle_error = client.Connect(device.Address, device.Port, timeout)
 		if handle_error == 0 {
 			get_handle_count = 0
-			*global_handle = handle
+			connected = true
+			*global_handle = client.Handle()
 			break
 		}
 		if get_handle_count >= max_get_handle {
@@ -194,10 +198,30 @@ func DataCollector(device Device, timeout int, global_handle *uint16, running *b
 			return
 		}
 	}
+	// discover paths
+	if device.AutoPaths {
+		max_path, max_path_error := client.GetMaxPath()
+		if max_path_error != 0 {
+			logger.Printf("Ошибка получения числа каналов %s, error: %d", device.Name, max_path_error)
+		} else {
+			device.Paths = make([]int16, 0, max_path)
+			for path := int16(1); path <= max_path; path++ {
+				device.Paths = append(device.Paths, path)
+			}
+		}
+	}
+	// discover axes and spindles
+	if config.Server.Status && HasWildcardTags(device.TagsPackName) {
+		DiscoverAxes(&device, client)
+	}
 	// collect data
 	protocol_error := false
+	schedule := NewTagSchedule(&device)
+	backup := NewProgramBackup(&device)
 	for *running {
-		if !IsConnectAlive(device.Address, device.Port, 10*time.Second, running) {
+		if !IsDeviceAlive(&device, client, running) {
+			// the CNC may be restarted
+			schedule.Reset()
 			reconnect_counter++
 			if reconnect_counter >= max_reconnect {
 				OutputFanucData(GetPowerOffData(&device))
@@ -206,9 +230,13 @@ func DataCollector(device Device, timeout int, global_handle *uint16, running *b
 			}
 			continue
 		}
-		json_data = GetFanucJsonData(&device, &handle, &protocol_error)
+		json_data = GetFanucJsonData(&device, client, schedule, &protocol_error)
 		OutputFanucData(json_data)
+		if !protocol_error && IsProtocolError(backup.Run(client, time.Now())) {
+			protocol_error = true
+		}
 		if protocol_error {
+			schedule.Reset()
 			reconnect_counter++
 			if reconnect_counter >= max_reconnect {
 				OutputFanucData(GetPowerOffData(&device))
@@ -216,8 +244,41 @@ func DataCollector(device Device, timeout int, global_handle *uint16, running *b
 				return
 			}
 		}
-		time.Sleep(time.Duration(device.DelayMs) * time.Millisecond)
+		time.Sleep(schedule.Period(device.TagsPack))
+	}
+}
+
+func DiscoverAxes(device *Device, client CNCClient) {
+	if len(device.Paths) == 0 {
+		axes, spindles := ReadAxisNames(device, client)
+		CreateAxisNodes(device.Name, "", axes, spindles)
+		return
+	}
+	for index, path := range device.Paths {
+		set_path_error := client.SetPath(path)
+		if set_path_error != 0 {
+			logger.Printf("Ошибка выбора канала %d %s, error: %d", path, device.Name, set_path_error)
+			continue
+		}
+		axes, spindles := ReadAxisNames(device, client)
+		// first path duplicates into the device tags
+		if index == 0 {
+			CreateAxisNodes(device.Name, "", axes, spindles)
+		}
+		CreateAxisNodes(device.Name, GetPathKey(path), axes, spindles)
+	}
+}
+
+func ReadAxisNames(device *Device, client CNCClient) ([]string, []string) {
+	axes, axes_error := client.GetAxisNames()
+	if axes_error != 0 {
+		logger.Printf("Ошибка чтения имен осей %s, error: %d", device.Name, axes_error)
+	}
+	spindles, spindles_error := client.GetSpindleNames()
+	if spindles_error != 0 {
+		logger.Printf("Ошибка чтения имен шпинделей %s, error: %d", device.Name, spindles_error)
 	}
+	return axes, spindles
 }
 
 func GetPowerOffData(device *Device) string {
@@ -234,7 +295,40 @@ func GetPowerOffData(device *Device) string {
 	return string(json_data)
 }
 
-func GetFanucJsonData(device *Device, handle *uint16, protocol_error *bool) string {
+// map tags keyed by spindle name, other map tags are keyed by axis name
+var spindle_tags = []string{"spindle_param_speed", "spindle_motor_speed", "spindle_load"}
+
+func IsSpindleTag(tag string) bool {
+	return slices.Contains(spindle_tags, tag)
+}
+
+// tags read separately for every CNC path
+var path_tags = []string{
+	"aut", "run", "edit", "g00", "shutdowns", "motion", "mstb", "load_excess", "frame",
+	"main_prog_number", "sub_prog_number", "program_name", "program_path", "programs", "program_events",
+	"parts_count", "tool_number", "tool_offsets", "tool_offset_changes", "active_tool_offsets", "tool_life",
+	"work_offsets", "work_offset_events", "frame_number", "exec_window", "block_counter",
+	"feedrate", "feedrate_prg", "feedrate_note", "feed_override", "jog_override", "jog_speed",
+	"current_load", "current_load_percent", "servo_loads",
+	"absolute_positions", "machine_positions", "relative_positions", "distance_to_go", "commanded_positions",
+	"spindle_speed", "spindle_param_speed", "spindle_motor_speed", "spindle_load", "spindle_override", "drive_health",
+	"modal", "macros", "parameters", "diagnostics", "timers",
+	"emergency", "alarm", "alarm_messages", "alarm_history", "operator_messages",
+}
+
+func IsPathTag(tag string) bool {
+	return slices.Contains(path_tags, tag)
+}
+
+func IsProtocolError(error_code int16) bool {
+	return error_code == -17 || error_code == -16 || error_code == -8
+}
+
+func GetPathKey(path int16) string {
+	return strconv.Itoa(int(path))
+}
+
+func GetFanucJsonData(device *Device, client CNCClient, schedule *TagSchedule, protocol_error *bool) string {
 	tag_map := make(map[string]any)
 	// default tags
 	tag_map["name"] = device.Name
@@ -244,102 +338,66 @@ func GetFanucJsonData(device *Device, handle *uint16, protocol_error *bool) stri
 	// scan tags
 	*protocol_error = false
 	errors := make(map[string]int16)
+	plan := NewReadPlan(device, client, "")
+	schedule.Begin(time.Now())
+	var due_tags []string
 	for _, tag := range device.TagsPack {
-		switch tag {
-		case "aut":
-			tag_map[tag], errors[tag] = GetAut(handle)
-		case "run":
-			tag_map[tag], errors[tag] = GetRun(handle)
-		case "edit":
-			tag_map[tag], errors[tag] = GetEdit(handle)
-		case "g00":
-			tag_map[tag], errors[tag] = GetG00(handle)
-		case "shutdowns":
-			tag_map[tag], errors[tag] = GetShutdowns(handle)
-		case "motion":
-			tag_map[tag], errors[tag] = GetMotion(handle)
-		case "mstb":
-			tag_map[tag], errors[tag] = GetMstb(handle)
-		case "load_excess":
-			tag_map[tag], errors[tag] = GetLoadExcess(handle)
-		case "frame":
-			tag_map[tag], errors[tag] = GetFrame(handle)
-		case "main_prog_number":
-			tag_map[tag], errors[tag] = GetMainProgNum(handle)
-		case "sub_prog_number":
-			tag_map[tag], errors[tag] = GetSubProgNum(handle)
-		case "parts_count":
-			tag_map[tag], errors[tag] = GetPartsCount(handle)
-		case "tool_number":
-			tag_map[tag], errors[tag] = GetToolNumber(handle)
-		case "frame_number":
-			tag_map[tag], errors[tag] = GetFrameNumber(handle)
-		case "feedrate":
-			tag_map[tag], errors[tag] = GetFeedRate(handle)
-		case "feedrate_prg":
-			tag_map[tag], errors[tag] = GetFeedRateParam1(handle)
-		case "feedrate_note":
-			tag_map[tag], errors[tag] = GetFeedRateParam2(handle)
-		case "feed_override":
-			tag_map[tag], errors[tag] = GetFeedOverride(handle)
-		case "jog_override":
-			tag_map[tag], errors[tag] = GetJogOverride(handle)
-		case "jog_speed":
-			tag_map[tag], errors[tag] = GetJogSpeed(handle)
-		case "current_load":
-			tag_map[tag], errors[tag] = GetServoCurrentLoad(handle)
-		case "current_load_percent":
-			tag_map[tag], errors[tag] = GetServoCurrentLoadPercent(handle)
-		case "servo_loads":
-			tag_map[tag], errors[tag] = GetServoLoad(handle)
-		case "absolute_positions":
-			tag_map[tag], errors[tag] = GetAbsolutePositions(handle)
-		case "machine_positions":
-			tag_map[tag], errors[tag] = GetMachinePositions(handle)
-		case "relative_positions":
-			tag_map[tag], errors[tag] = GetRelativePositions(handle)
-		case "spindle_speed":
-			tag_map[tag], errors[tag] = GetSpindleSpeed(handle)
-		case "spindle_param_speed":
-			tag_map[tag], errors[tag] = GetSpindleSpeedParam(handle)
-		case "spindle_motor_speed":
-			tag_map[tag], errors[tag] = GetSpindleMotorSpeed(handle)
-		case "spindle_load":
-			tag_map[tag], errors[tag] = GetSpindleLoad(handle)
-		case "spindle_override":
-			tag_map[tag], errors[tag] = GetSpindleOverride(handle)
-		case "emergency":
-			tag_map[tag], errors[tag] = GetEmergency(handle)
-		case "alarm":
-			tag_map[tag], errors[tag] = GetAlarm(handle)
-		case "axes_number":
-			tag_map[tag], errors[tag] = GetCtrlAxesNumber(handle)
-		case "spindles_number":
-			tag_map[tag], errors[tag] = GetCtrlSpindlesNumber(handle)
-		case "channels_number":
-			tag_map[tag], errors[tag] = GetCtrlPathsNumber(handle)
-		case "power_on_time":
-			tag_map[tag], errors[tag] = GetPowerOnTime(handle)
-		case "operation_time":
-			tag_map[tag], errors[tag] = GetOperationTime(handle)
-		case "cutting_time":
-			tag_map[tag], errors[tag] = GetCuttingTime(handle)
-		case "cycle_time":
-			tag_map[tag], errors[tag] = GetCycleTime(handle)
-		case "series_number":
-			tag_map[tag], errors[tag] = GetSeriesNumber(handle)
-		case "version_number":
-			tag_map[tag], errors[tag] = GetVersionNumber(handle)
-		case "serial_number":
-			tag_map[tag], errors[tag] = GetSerialNumber(handle)
-		case "cnc_id":
-			tag_map[tag], errors[tag] = GetCncId(handle)
+		if tag == "errors" || (len(device.Paths) != 0 && IsPathTag(tag)) {
+			continue
+		}
+		if !schedule.IsDue(tag) {
+			schedule.Restore("", tag, tag_map, errors)
+			continue
 		}
-		if error_code, ok := errors[tag]; ok && (error_code == -16 || error_code == -8) {
+		due_tags = append(due_tags, tag)
+	}
+	// device tags are read on the main path, other paths may be left selected
+	if len(device.Paths) != 0 && len(due_tags) != 0 {
+		set_path_error := client.SetPath(device.Paths[0])
+		if set_path_error != 0 {
+			errors["path"] = set_path_error
+			due_tags = nil
+			*protocol_error = IsProtocolError(set_path_error)
+		}
+	}
+	for _, tag := range due_tags {
+		ReadTag(plan, tag, tag_map, errors)
+		TrackTagEvents(device, "", tag, tag_map, errors)
+		schedule.Store("", tag, tag_map, errors)
+		if IsProtocolError(errors[tag]) {
 			*protocol_error = true
 			break
 		}
 	}
+	// scan path tags
+	if len(device.Paths) != 0 && !*protocol_error {
+		paths_map := make(map[string]any)
+		for index, path := range device.Paths {
+			path_map, path_errors := GetPathData(device, client, schedule, path)
+			paths_map[GetPathKey(path)] = path_map
+			// first path duplicates into the device tags
+			if index == 0 {
+				for tag_name, value := range path_map {
+					tag_map[tag_name] = value
+				}
+				for tag_name, error_code := range path_errors {
+					errors[tag_name] = error_code
+				}
+			}
+			if slices.Contains(device.TagsPack, "errors") {
+				path_map["errors"] = path_errors
+			}
+			for _, error_code := range path_errors {
+				if IsProtocolError(error_code) {
+					*protocol_error = true
+				}
+			}
+			if *protocol_error {
+				break
+			}
+		}
+		tag_map["paths"] = paths_map
+	}
 	// clear error data
 	for tag_name, error_code := range errors {
 		if error_code != 0 {
@@ -357,3 +415,191 @@ func GetFanucJsonData(device *Device, handle *uint16, protocol_error *bool) stri
 	}
 	return string(json_data)
 }
+
+func GetPathData(device *Device, client CNCClient, schedule *TagSchedule, path int16) (map[string]any, map[string]int16) {
+	path_map := make(map[string]any)
+	errors := make(map[string]int16)
+	path_key := GetPathKey(path)
+	var due_tags []string
+	for _, tag := range device.TagsPack {
+		if !IsPathTag(tag) {
+			continue
+		}
+		if !schedule.IsDue(tag) {
+			schedule.Restore(path_key, tag, path_map, errors)
+			continue
+		}
+		due_tags = append(due_tags, tag)
+	}
+	if len(due_tags) != 0 {
+		set_path_error := client.SetPath(path)
+		if set_path_error != 0 {
+			errors["path"] = set_path_error
+			return path_map, errors
+		}
+	}
+	plan := NewReadPlan(device, client, path_key)
+	for _, tag := range due_tags {
+		ReadTag(plan, tag, path_map, errors)
+		TrackTagEvents(device, path_key, tag, path_map, errors)
+		schedule.Store(path_key, tag, path_map, errors)
+		if IsProtocolError(errors[tag]) {
+			break
+		}
+	}
+	for tag_name, error_code := range errors {
+		if error_code != 0 {
+			delete(path_map, tag_name)
+		}
+	}
+	return path_map, errors
+}
+
+func ReadTag(plan *ReadPlan, tag string, tag_map map[string]any, errors map[string]int16) {
+	client := plan.client
+	switch tag {
+	case "aut":
+		stat_info, stat_info_error := plan.StatInfo()
+		tag_map[tag], errors[tag] = stat_info.Aut, stat_info_error
+	case "run":
+		stat_info, stat_info_error := plan.StatInfo()
+		tag_map[tag], errors[tag] = stat_info.Run, stat_info_error
+	case "edit":
+		stat_info, stat_info_error := plan.StatInfo()
+		tag_map[tag], errors[tag] = stat_info.Edit, stat_info_error
+	case "g00":
+		tag_map[tag], errors[tag] = client.GetG00()
+	case "shutdowns":
+		tag_map[tag], errors[tag] = plan.Shutdowns()
+	case "motion":
+		stat_info, stat_info_error := plan.StatInfo()
+		tag_map[tag], errors[tag] = stat_info.Motion, stat_info_error
+	case "mstb":
+		stat_info, stat_info_error := plan.StatInfo()
+		tag_map[tag], errors[tag] = stat_info.Mstb, stat_info_error
+	case "load_excess":
+		tag_map[tag], errors[tag] = client.GetLoadExcess()
+	case "frame":
+		tag_map[tag], errors[tag] = plan.Frame()
+	case "main_prog_number":
+		tag_map[tag], errors[tag] = client.GetMainProgNum()
+	case "sub_prog_number":
+		tag_map[tag], errors[tag] = client.GetSubProgNum()
+	case "program_name":
+		tag_map[tag], errors[tag] = plan.ProgramName()
+	case "program_path":
+		tag_map[tag], errors[tag] = plan.ProgramPath()
+	case "programs":
+		tag_map[tag], errors[tag] = plan.Programs()
+	case "program_events":
+		tag_map[tag], errors[tag] = plan.ProgramEvents()
+	case "tool_offsets":
+		tag_map[tag], errors[tag] = plan.ToolOffsets()
+	case "tool_offset_changes":
+		tag_map[tag], errors[tag] = plan.ToolOffsetChanges()
+	case "active_tool_offsets":
+		tag_map[tag], errors[tag] = plan.ActiveToolOffsets()
+	case "tool_life":
+		tag_map[tag], errors[tag] = ReadToolLife(client)
+	case "work_offsets":
+		tag_map[tag], errors[tag] = plan.WorkOffsets()
+	case "work_offset_events":
+		tag_map[tag], errors[tag] = plan.WorkOffsetEvents()
+	case "parts_count":
+		tag_map[tag], errors[tag] = client.GetPartsCount()
+	case "tool_number":
+		tag_map[tag], errors[tag] = plan.ToolNumber()
+	case "frame_number":
+		tag_map[tag], errors[tag] = plan.FrameNumber()
+	case "exec_window":
+		tag_map[tag], errors[tag] = plan.ExecWindow()
+	case "block_counter":
+		tag_map[tag], errors[tag] = client.GetBlockCount()
+	case "feedrate":
+		tag_map[tag], errors[tag] = client.GetFeedRate()
+	case "feedrate_prg":
+		tag_map[tag], errors[tag] = client.GetFeedRateParam1()
+	case "feedrate_note":
+		tag_map[tag], errors[tag] = client.GetFeedRateParam2()
+	case "feed_override":
+		tag_map[tag], errors[tag] = client.GetFeedOverride()
+	case "jog_override":
+		tag_map[tag], errors[tag] = client.GetJogOverride()
+	case "jog_speed":
+		tag_map[tag], errors[tag] = client.GetJogSpeed()
+	case "current_load":
+		tag_map[tag], errors[tag] = client.GetServoCurrentLoad()
+	case "current_load_percent":
+		tag_map[tag], errors[tag] = client.GetServoCurrentLoadPercent()
+	case "servo_loads":
+		tag_map[tag], errors[tag] = client.GetServoLoad()
+	case "absolute_positions":
+		tag_map[tag], errors[tag] = client.GetAbsolutePositions()
+	case "machine_positions":
+		tag_map[tag], errors[tag] = client.GetMachinePositions()
+	case "relative_positions":
+		tag_map[tag], errors[tag] = client.GetRelativePositions()
+	case "distance_to_go":
+		tag_map[tag], errors[tag] = client.GetDistanceToGo()
+	case "commanded_positions":
+		tag_map[tag], errors[tag] = client.GetCommandedPositions()
+	case "spindle_speed":
+		tag_map[tag], errors[tag] = client.GetSpindleSpeed()
+	case "spindle_param_speed":
+		tag_map[tag], errors[tag] = client.GetSpindleSpeedParam()
+	case "spindle_motor_speed":
+		tag_map[tag], errors[tag] = client.GetSpindleMotorSpeed()
+	case "spindle_load":
+		tag_map[tag], errors[tag] = client.GetSpindleLoad()
+	case "spindle_override":
+		tag_map[tag], errors[tag] = client.GetSpindleOverride()
+	case "emergency":
+		stat_info, stat_info_error := plan.StatInfo()
+		tag_map[tag], errors[tag] = stat_info.Emergency, stat_info_error
+	case "alarm":
+		stat_info, stat_info_error := plan.StatInfo()
+		tag_map[tag], errors[tag] = stat_info.Alarm, stat_info_error
+	case "alarm_messages":
+		tag_map[tag], errors[tag] = client.GetAlarmMessages()
+	case "pmc":
+		tag_map[tag], errors[tag] = ReadPmcSignals(client, plan.pmc_signals)
+	case "macros":
+		tag_map[tag], errors[tag] = ReadMacroVariables(client, plan.macro_fields)
+	case "modal":
+		tag_map[tag], errors[tag] = ReadModal(client)
+	case "parameters":
+		tag_map[tag], errors[tag] = ReadCncData(plan.parameters, client.ReadParameter)
+	case "diagnostics":
+		tag_map[tag], errors[tag] = ReadCncData(plan.diagnostics, client.ReadDiagnosis)
+	case "drive_health":
+		tag_map[tag], errors[tag] = ReadDriveHealth(client, plan.servo_health, plan.spindle_health)
+	case "operator_messages":
+		tag_map[tag], errors[tag] = plan.OperatorMessages()
+	case "alarm_history":
+		tag_map[tag], errors[tag] = ReadAlarmHistory(client, plan.scope_key)
+	case "axes_number":
+		tag_map[tag], errors[tag] = client.GetCtrlAxesNumber()
+	case "spindles_number":
+		tag_map[tag], errors[tag] = client.GetCtrlSpindlesNumber()
+	case "channels_number":
+		tag_map[tag], errors[tag] = client.GetCtrlPathsNumber()
+	case "power_on_time":
+		tag_map[tag], errors[tag] = plan.PowerOnTime()
+	case "operation_time":
+		tag_map[tag], errors[tag] = plan.TimeParams(6751, 6752)
+	case "cutting_time":
+		tag_map[tag], errors[tag] = plan.TimeParams(6753, 6754)
+	case "cycle_time":
+		tag_map[tag], errors[tag] = plan.TimeParams(6757, 6758)
+	case "timers":
+		tag_map[tag], errors[tag] = ReadTimers(client)
+	case "series_number":
+		tag_map[tag], errors[tag] = client.GetSeriesNumber()
+	case "version_number":
+		tag_map[tag], errors[tag] = client.GetVersionNumber()
+	case "serial_number":
+		tag_map[tag], errors[tag] = client.GetSerialNumber()
+	case "cnc_id":
+		tag_map[tag], errors[tag] = client.GetCncId()
+	}
+}
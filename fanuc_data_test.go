@@ -0,0 +1,1171 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func UseFakeClient(t *testing.T, fake *FakeClient, alive bool) {
+	t.Helper()
+	saved_client, saved_alive := new_cnc_client, is_connect_alive
+	new_cnc_client = func(device *Device) CNCClient { return fake }
+	is_connect_alive = func(ip string, port int, timeout time.Duration, running *bool) bool { return alive }
+	t.Cleanup(func() {
+		new_cnc_client, is_connect_alive = saved_client, saved_alive
+	})
+}
+
+// json lines written to stdout by OutputFanucData
+func CaptureOutput(t *testing.T, run func()) []map[string]any {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved_stdout := os.Stdout
+	os.Stdout = writer
+	lines := make(chan []map[string]any)
+	go func() {
+		var result []map[string]any
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			var line map[string]any
+			if json.Unmarshal(scanner.Bytes(), &line) == nil {
+				result = append(result, line)
+			}
+		}
+		lines <- result
+	}()
+	defer func() {
+		os.Stdout = saved_stdout
+	}()
+	run()
+	writer.Close()
+	return <-lines
+}
+
+func CaptureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	saved_logger := logger
+	logger = log.New(&buf, "", 0)
+	t.Cleanup(func() { logger = saved_logger })
+	return &buf
+}
+
+func RunDataCollector(t *testing.T, device Device) []map[string]any {
+	t.Helper()
+	running := true
+	var global_handle uint16
+	return CaptureOutput(t, func() {
+		done := make(chan bool)
+		go func() {
+			DataCollector(device, 1, &global_handle, &running)
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Fatal("DataCollector did not return")
+		}
+	})
+}
+
+func ParseJsonData(t *testing.T, json_data string) map[string]any {
+	t.Helper()
+	var tag_map map[string]any
+	if err := json.Unmarshal([]byte(json_data), &tag_map); err != nil {
+		t.Fatalf("%v: %s", err, json_data)
+	}
+	return tag_map
+}
+
+func CountCalls(calls []string, method string) int {
+	count := 0
+	for _, call := range calls {
+		if call == method {
+			count++
+		}
+	}
+	return count
+}
+
+func NewTestDevice(tags ...string) Device {
+	return Device{Name: "test", Address: "127.0.0.1", Port: 8193, DelayMs: 1, TagsPack: tags}
+}
+
+func TestFanucJsonDataErrorsTag(t *testing.T) {
+	fake := NewFakeClient()
+	fake.Connect("", 0, 0)
+	fake.SetValue("GetStatInfo", CncStatInfo{Aut: 1, Run: 3})
+	fake.SetValue("GetAbsolutePositions", map[string]float64{"X": 1.5})
+	fake.SetError("GetSpindleSpeed", EW_NOOPT)
+	device := NewTestDevice("aut", "run", "feedrate", "spindle_speed", "absolute_positions", "errors")
+	protocol_error := true
+	tag_map := ParseJsonData(t, GetFanucJsonData(&device, fake, NewTagSchedule(&device), &protocol_error))
+	if protocol_error {
+		t.Fatal("protocol_error without a connection error")
+	}
+	if tag_map["aut"] != 1.0 || tag_map["run"] != 3.0 || tag_map["power_on"] != 1.0 {
+		t.Fatalf("tag values: %v", tag_map)
+	}
+	if positions, ok := tag_map["absolute_positions"].(map[string]any); !ok || positions["X"] != 1.5 {
+		t.Fatalf("absolute_positions: %v", tag_map["absolute_positions"])
+	}
+	// tags with errors are removed from the data
+	for _, tag := range []string{"feedrate", "spindle_speed"} {
+		if _, ok := tag_map[tag]; ok {
+			t.Fatalf("tag %s with an error in the data: %v", tag, tag_map)
+		}
+	}
+	expected := map[string]any{
+		"aut": 0.0, "run": 0.0, "absolute_positions": 0.0,
+		"feedrate": float64(EW_FUNC), "spindle_speed": float64(EW_NOOPT),
+	}
+	errors, ok := tag_map["errors"].(map[string]any)
+	if !ok || len(errors) != len(expected) {
+		t.Fatalf("errors: %v, expected %v", tag_map["errors"], expected)
+	}
+	for tag, error_code := range expected {
+		if errors[tag] != error_code {
+			t.Fatalf("errors: %v, expected %v", errors, expected)
+		}
+	}
+	if CountCalls(fake.Calls(), "GetStatInfo") != 1 {
+		t.Fatalf("cnc_statinfo read more than once: %v", fake.Calls())
+	}
+}
+
+func TestFanucJsonDataWithoutErrorsTag(t *testing.T) {
+	fake := NewFakeClient()
+	fake.Connect("", 0, 0)
+	device := NewTestDevice("feedrate")
+	protocol_error := false
+	tag_map := ParseJsonData(t, GetFanucJsonData(&device, fake, NewTagSchedule(&device), &protocol_error))
+	if _, ok := tag_map["errors"]; ok {
+		t.Fatalf("errors tag is not configured: %v", tag_map)
+	}
+}
+
+func TestFanucJsonDataProtocolError(t *testing.T) {
+	for _, error_code := range []int16{EW_SOCKET, EW_HANDLE, EW_PROTOCOL} {
+		fake := NewFakeClient()
+		fake.Connect("", 0, 0)
+		fake.SetValue("GetStatInfo", CncStatInfo{Aut: 1})
+		fake.SetError("GetFeedRate", error_code)
+		device := NewTestDevice("aut", "feedrate", "spindle_speed", "errors")
+		protocol_error := false
+		tag_map := ParseJsonData(t, GetFanucJsonData(&device, fake, NewTagSchedule(&device), &protocol_error))
+		if !protocol_error {
+			t.Fatalf("error %d: protocol_error is not set", error_code)
+		}
+		errors := tag_map["errors"].(map[string]any)
+		if errors["feedrate"] != float64(error_code) {
+			t.Fatalf("error %d: errors %v", error_code, errors)
+		}
+		// the cycle stops on a connection error
+		if _, ok := errors["spindle_speed"]; ok || slices.Contains(fake.Calls(), "GetSpindleSpeed") {
+			t.Fatalf("error %d: tags read after the connection error: %v", error_code, fake.Calls())
+		}
+		if tag_map["aut"] != 1.0 {
+			t.Fatalf("error %d: aut %v", error_code, tag_map["aut"])
+		}
+	}
+}
+
+func TestFanucJsonDataPathErrors(t *testing.T) {
+	fake := NewFakeClient()
+	fake.Connect("", 0, 0)
+	fake.Script("SetPath", FakeResult{}, FakeResult{Error: EW_PATH})
+	fake.SetValue("GetStatInfo", CncStatInfo{Aut: 1})
+	device := NewTestDevice("aut", "errors")
+	device.Paths = []int16{1, 2}
+	protocol_error := false
+	tag_map := ParseJsonData(t, GetFanucJsonData(&device, fake, NewTagSchedule(&device), &protocol_error))
+	if protocol_error {
+		t.Fatal("protocol_error on a path selection error")
+	}
+	paths := tag_map["paths"].(map[string]any)
+	first_path := paths["1"].(map[string]any)
+	second_path := paths["2"].(map[string]any)
+	if first_path["aut"] != 1.0 || tag_map["aut"] != 1.0 {
+		t.Fatalf("path 1: %v", tag_map)
+	}
+	if errors := second_path["errors"].(map[string]any); errors["path"] != float64(EW_PATH) {
+		t.Fatalf("path 2: %v", second_path)
+	}
+}
+
+func TestDataCollectorReconnect(t *testing.T) {
+	fake := NewFakeClient()
+	fake.Script("GetStatInfo", FakeResult{Value: CncStatInfo{Aut: 1}}, FakeResult{Error: EW_SOCKET})
+	UseFakeClient(t, fake, true)
+	lines := RunDataCollector(t, NewTestDevice("aut", "errors"))
+	calls := fake.Calls()
+	if calls[0] != "Connect" || calls[len(calls)-1] != "Free" || fake.Connected() {
+		t.Fatalf("handle is not freed: %v", calls)
+	}
+	// one good cycle and max_reconnect cycles with the connection error
+	if count := CountCalls(calls, "GetStatInfo"); count != 6 {
+		t.Fatalf("read cycles %d, expected 6: %v", count, calls)
+	}
+	if len(lines) != 8 {
+		t.Fatalf("output lines %d, expected 8: %v", len(lines), lines)
+	}
+	if lines[1]["aut"] != 1.0 || lines[1]["power_on"] != 1.0 {
+		t.Fatalf("data: %v", lines[1])
+	}
+	for _, line := range lines[2:7] {
+		if _, ok := line["aut"]; ok || line["errors"].(map[string]any)["aut"] != float64(EW_SOCKET) {
+			t.Fatalf("data on the connection error: %v", line)
+		}
+	}
+	if lines[7]["power_on"] != 0.0 {
+		t.Fatalf("no power off data before the restart: %v", lines[7])
+	}
+}
+
+func TestDataCollectorConnectRetry(t *testing.T) {
+	fake := NewFakeClient()
+	fake.ScriptConnect(EW_SOCKET, EW_BUSY)
+	fake.Script("GetStatInfo", FakeResult{Value: CncStatInfo{Aut: 1}}, FakeResult{Error: EW_HANDLE})
+	UseFakeClient(t, fake, true)
+	lines := RunDataCollector(t, NewTestDevice("aut"))
+	if count := CountCalls(fake.Calls(), "Connect"); count != 3 {
+		t.Fatalf("connect attempts %d, expected 3: %v", count, fake.Calls())
+	}
+	if len(lines) < 2 || lines[1]["aut"] != 1.0 {
+		t.Fatalf("no data after connect: %v", lines)
+	}
+}
+
+func TestDataCollectorConnectFailure(t *testing.T) {
+	fake := NewFakeClient()
+	fake.ScriptConnect(EW_SOCKET, EW_SOCKET, EW_SOCKET, EW_SOCKET, EW_SOCKET, EW_SOCKET)
+	UseFakeClient(t, fake, true)
+	lines := RunDataCollector(t, NewTestDevice("aut"))
+	calls := fake.Calls()
+	if count := CountCalls(calls, "Connect"); count != 5 || len(calls) != 5 {
+		t.Fatalf("calls after the connect errors: %v", calls)
+	}
+	if lines[len(lines)-1]["power_on"] != 0.0 {
+		t.Fatalf("no power off data: %v", lines)
+	}
+}
+
+func TestDataCollectorUnreachable(t *testing.T) {
+	fake := NewFakeClient()
+	UseFakeClient(t, fake, false)
+	lines := RunDataCollector(t, NewTestDevice("aut"))
+	if calls := fake.Calls(); len(calls) != 0 {
+		t.Fatalf("calls of the unreachable device: %v", calls)
+	}
+	if len(lines) != 2 || lines[1]["power_on"] != 0.0 {
+		t.Fatalf("output of the unreachable device: %v", lines)
+	}
+}
+
+func TestFanucJsonDataMainPath(t *testing.T) {
+	fake := NewFakeClient()
+	fake.Connect("", 0, 0)
+	fake.SetValue("GetSeriesNumber", "D4F1")
+	fake.SetValue("GetStatInfo", CncStatInfo{Aut: 1})
+	device := NewTestDevice("series_number", "aut", "errors")
+	device.Paths = []int16{1, 2}
+	protocol_error := false
+	tag_map := ParseJsonData(t, GetFanucJsonData(&device, fake, NewTagSchedule(&device), &protocol_error))
+	calls := fake.Calls()
+	if len(calls) < 3 || calls[1] != "SetPath" || calls[2] != "GetSeriesNumber" {
+		t.Fatalf("device tags are read without the main path: %v", calls)
+	}
+	if tag_map["series_number"] != "D4F1" {
+		t.Fatalf("series_number: %v", tag_map)
+	}
+	fake.SetError("SetPath", EW_PATH)
+	tag_map = ParseJsonData(t, GetFanucJsonData(&device, fake, NewTagSchedule(&device), &protocol_error))
+	if _, ok := tag_map["series_number"]; ok || tag_map["errors"].(map[string]any)["path"] != float64(EW_PATH) {
+		t.Fatalf("device tags on the path error: %v", tag_map)
+	}
+}
+
+func TestFanucJsonDataActiveToolOffsets(t *testing.T) {
+	fake := NewFakeClient()
+	fake.Connect("", 0, 0)
+	fake.SetValue("GetToolNumber", int64(3))
+	fake.SetValue("GetToolOffsetInfo", CncToolOffsetInfo{MtType: "M", Type: 1, Count: 4})
+	fake.SetValue("GetToolOffsets", []int32{1500})
+	device := NewTestDevice("tool_number", "active_tool_offsets", "errors")
+	protocol_error := false
+	tag_map := ParseJsonData(t, GetFanucJsonData(&device, fake, NewTagSchedule(&device), &protocol_error))
+	offsets := tag_map["active_tool_offsets"].(map[string]any)
+	if tag_map["tool_number"] != 3.0 || offsets["offset_number"] != 3.0 || offsets["wear"] != 1.5 || offsets["geometry"] != 1.5 {
+		t.Fatalf("active_tool_offsets: %v", tag_map)
+	}
+	calls := fake.Calls()
+	if CountCalls(calls, "GetToolNumber") != 1 || CountCalls(calls, "GetToolOffsets") != 2 {
+		t.Fatalf("tool number or offsets read more than once: %v", calls)
+	}
+	// offsets of the active tool are taken from the table read in the same cycle
+	fake = NewFakeClient()
+	fake.Connect("", 0, 0)
+	fake.SetValue("GetToolNumber", int64(3))
+	fake.SetValue("GetToolOffsetInfo", CncToolOffsetInfo{MtType: "M", Type: 1, Count: 4})
+	fake.SetValue("GetToolOffsets", []int32{1000, 2000, 3000, 4000})
+	device = NewTestDevice("tool_offsets", "active_tool_offsets", "errors")
+	tag_map = ParseJsonData(t, GetFanucJsonData(&device, fake, NewTagSchedule(&device), &protocol_error))
+	offsets = tag_map["active_tool_offsets"].(map[string]any)
+	if offsets["wear"] != 3.0 || offsets["geometry"] != 3.0 {
+		t.Fatalf("active_tool_offsets: %v", tag_map)
+	}
+	if count := CountCalls(fake.Calls(), "GetToolOffsets"); count != 2 {
+		t.Fatalf("offsets read %d times, expected 2: %v", count, fake.Calls())
+	}
+}
+
+func TestFanucJsonDataExecBlocks(t *testing.T) {
+	fake := NewFakeClient()
+	fake.Connect("", 0, 0)
+	fake.SetValue("GetExecBlocks", CncExecBlocks{Text: "N10 G01 X10.\nN20 M30\n", Executed: 0})
+	device := NewTestDevice("frame", "shutdowns", "exec_window", "errors")
+	protocol_error := false
+	tag_map := ParseJsonData(t, GetFanucJsonData(&device, fake, NewTagSchedule(&device), &protocol_error))
+	if errors := tag_map["errors"].(map[string]any); errors["frame"] != 0.0 || errors["exec_window"] != 0.0 {
+		t.Fatalf("errors: %v", errors)
+	}
+	calls := fake.Calls()
+	if CountCalls(calls, "GetExecBlocks") != 1 || CountCalls(calls, "GetExecProgram") != 0 {
+		t.Fatalf("cnc_rdexecprog read more than once: %v", calls)
+	}
+	if window, ok := tag_map["exec_window"].([]any); !ok || len(window) == 0 {
+		t.Fatalf("exec_window: %v", tag_map["exec_window"])
+	}
+}
+
+func TestReadTimer(t *testing.T) {
+	cases := map[string]struct {
+		reads    []CncTimer
+		expected float64
+		error    int16
+		count    int
+	}{
+		"one read":  {[]CncTimer{{Minute: 10, Msec: 30000}}, 630, EW_OK, 1},
+		"rollover":  {[]CncTimer{{Minute: 10, Msec: 59900}, {Minute: 11, Msec: 100}, {Minute: 11, Msec: 200}}, 660.2, EW_OK, 3},
+		"reset":     {[]CncTimer{{Minute: 0, Msec: 0}, {Minute: 0, Msec: 40}}, 0.04, EW_OK, 2},
+		"unsettled": {[]CncTimer{{Minute: 1, Msec: 59999}, {Minute: 2, Msec: 0}, {Minute: 3, Msec: 0}, {Minute: 4, Msec: 0}}, 0, EW_DATA, 4},
+	}
+	for name, test_case := range cases {
+		t.Run(name, func(t *testing.T) {
+			fake := NewFakeClient()
+			fake.Connect("", 0, 0)
+			results := make([]FakeResult, 0)
+			for _, timer := range test_case.reads {
+				results = append(results, FakeResult{Value: timer})
+			}
+			fake.Script("GetTimer", results...)
+			seconds, ret := ReadTimer(fake, 0)
+			if ret != test_case.error || seconds-test_case.expected > 1e-9 || test_case.expected-seconds > 1e-9 {
+				t.Fatalf("ReadTimer: %v, %d, expected %v, %d", seconds, ret, test_case.expected, test_case.error)
+			}
+			if count := CountCalls(fake.Calls(), "GetTimer"); count != test_case.count {
+				t.Fatalf("reads %d, expected %d", count, test_case.count)
+			}
+		})
+	}
+}
+
+func TestTagScheduleOnceReset(t *testing.T) {
+	fake := NewFakeClient()
+	fake.Connect("", 0, 0)
+	fake.SetValue("GetSeriesNumber", "D4F1")
+	device := NewTestDevice("series_number", "errors")
+	device.TagGroups = map[string]string{"series_number": once_group}
+	schedule := NewTagSchedule(&device)
+	protocol_error := false
+	read := func() map[string]any {
+		return ParseJsonData(t, GetFanucJsonData(&device, fake, schedule, &protocol_error))
+	}
+	read()
+	if tag_map := read(); tag_map["series_number"] != "D4F1" || CountCalls(fake.Calls(), "GetSeriesNumber") != 1 {
+		t.Fatalf("once tag is not repeated from the cache: %v, %v", tag_map, fake.Calls())
+	}
+	// read again after reconnect, a connection error does not drop the last value
+	schedule.Reset()
+	fake.SetError("GetSeriesNumber", EW_SOCKET)
+	read()
+	fake.SetValue("GetSeriesNumber", "D4F2")
+	if tag_map := read(); tag_map["series_number"] != "D4F2" || CountCalls(fake.Calls(), "GetSeriesNumber") != 3 {
+		t.Fatalf("once tag is not read after the connection error: %v, %v", tag_map, fake.Calls())
+	}
+	if tag_map := read(); tag_map["series_number"] != "D4F2" || CountCalls(fake.Calls(), "GetSeriesNumber") != 3 {
+		t.Fatalf("once tag is read more than once: %v, %v", tag_map, fake.Calls())
+	}
+}
+
+func TestTagScheduleKeepsValueOnProtocolError(t *testing.T) {
+	device := NewTestDevice("parts_count")
+	device.TagGroups = map[string]string{"parts_count": "slow"}
+	schedule := NewTagSchedule(&device)
+	schedule.Store("", "parts_count", map[string]any{"parts_count": 12}, map[string]int16{"parts_count": 0})
+	schedule.Store("", "parts_count", map[string]any{}, map[string]int16{"parts_count": EW_SOCKET})
+	tag_map, errors := make(map[string]any), make(map[string]int16)
+	schedule.Restore("", "parts_count", tag_map, errors)
+	if tag_map["parts_count"] != 12 || errors["parts_count"] != 0 {
+		t.Fatalf("last value is not kept: %v, %v", tag_map, errors)
+	}
+}
+
+func TestTagScheduleDefaultGroup(t *testing.T) {
+	device := NewTestDevice("series_number", "alarm_history", "tool_offsets")
+	device.TagGroups = map[string]string{"tool_offsets": "slow"}
+	schedule := NewTagSchedule(&device)
+	expected := map[string]string{"series_number": default_group, "alarm_history": default_group, "tool_offsets": "slow"}
+	for tag, group := range expected {
+		if schedule.Group(tag) != group {
+			t.Fatalf("group of %s: %s, expected %s", tag, schedule.Group(tag), group)
+		}
+	}
+	if period := schedule.Period(device.TagsPack); period != time.Millisecond {
+		t.Fatalf("period %v, expected delay_ms", period)
+	}
+}
+
+func TestReadCncDataItemErrors(t *testing.T) {
+	items := map[string]CncDataItem{
+		"parts_required": {Number: 6713, Type: "2-word"},
+		"motor_temp":     {Number: 308, Axis: 5, Type: "byte"},
+		"override_off":   {Number: 1401, Type: "bit", Bit: 4},
+	}
+	values := map[int32]CncDataValue{6713: {Value: 500}, 1401: {Value: 0x10}}
+	read := func(number int32, axis int16, size int16) (CncDataValue, int16) {
+		if value, ok := values[number]; ok {
+			return value, EW_OK
+		}
+		return CncDataValue{}, EW_ATTRIB
+	}
+	result, ret := ReadCncData(items, read)
+	if ret != EW_OK || result["parts_required"] != int32(500) || result["override_off"] != true {
+		t.Fatalf("ReadCncData: %v, %d", result, ret)
+	}
+	// unsupported items are null, the other items are kept
+	if value, ok := result["motor_temp"]; !ok || value != nil {
+		t.Fatalf("item with an error: %v", result)
+	}
+	result, ret = ReadCncData(items, func(number int32, axis int16, size int16) (CncDataValue, int16) {
+		return CncDataValue{}, EW_SOCKET
+	})
+	if ret != EW_SOCKET || len(result) != 0 {
+		t.Fatalf("ReadCncData on a connection error: %v, %d", result, ret)
+	}
+}
+
+// cnc_rdtimer results by timer type
+type TimerTestClient struct {
+	*FakeClient
+	timers map[int16]FakeResult
+}
+
+func (client TimerTestClient) GetTimer(timer_type int16) (CncTimer, int16) {
+	result, ok := client.timers[timer_type]
+	if !ok {
+		return CncTimer{}, EW_NOOPT
+	}
+	return ConvertScriptedValue[CncTimer](result.Value)
+}
+
+func TestReadTimersWithoutTimer(t *testing.T) {
+	client := TimerTestClient{FakeClient: NewFakeClient(), timers: map[int16]FakeResult{
+		timer_types["power_on"]:  {Value: CncTimer{Minute: 600}},
+		timer_types["operating"]: {Value: CncTimer{Minute: 100, Msec: 30000}},
+		timer_types["cutting"]:   {Value: CncTimer{Minute: 50, Msec: 1500}},
+		timer_types["cycle"]:     {Value: CncTimer{Minute: 2, Msec: 15000}},
+	}}
+	timers, ret := ReadTimers(client)
+	if ret != EW_OK {
+		t.Fatalf("ReadTimers: %d", ret)
+	}
+	expected := map[string]any{"power_on": 36000.0, "operating": 6030.0, "cutting": 3001.5, "cycle": 135.0, "free": nil}
+	for name, value := range expected {
+		if actual, ok := timers[name]; !ok || actual != value {
+			t.Fatalf("timers: %v, expected %v", timers, expected)
+		}
+	}
+}
+
+func TestFormatProgramName(t *testing.T) {
+	cases := map[int32]string{1: "O0001", 9999: "O9999", 10000: "O10000", 12345678: "O12345678", 99999999: "O99999999"}
+	for number, expected := range cases {
+		if name := FormatProgramName(number); name != expected {
+			t.Fatalf("FormatProgramName(%d): %s, expected %s", number, name, expected)
+		}
+	}
+	// path of the running program without a configured path
+	state := NewTestFocasState()
+	state.RunningProgram = 12345678
+	client := StartTestFocasServer(t, state)
+	if path, ret := client.GetProgramPath(); ret != EW_OK || path != "//CNC_MEM/USER/PATH1/O12345678" {
+		t.Fatalf("GetProgramPath: %q, %d", path, ret)
+	}
+}
+
+func TestGetPmcRanges(t *testing.T) {
+	// every 10th byte of R0..R200 reads as two ranges of at most pmc_max_range bytes
+	long := make(map[string]PmcSignal)
+	for address := uint16(0); address <= 200; address += 10 {
+		long[string(rune('a'+address/10))] = PmcSignal{Area: "R", Address: address, Type: "byte"}
+	}
+	cases := map[string]struct {
+		signals  map[string]PmcSignal
+		expected []PmcRange
+	}{
+		"adjacent": {map[string]PmcSignal{"a": {Area: "G", Address: 0, Type: "byte"}, "b": {Area: "G", Address: 1, Type: "byte"}},
+			[]PmcRange{{"G", 0, 1}}},
+		"max gap": {map[string]PmcSignal{"a": {Area: "G", Address: 0, Type: "byte"}, "b": {Area: "G", Address: 17, Type: "byte"}},
+			[]PmcRange{{"G", 0, 17}}},
+		"large gap": {map[string]PmcSignal{"a": {Area: "G", Address: 0, Type: "byte"}, "b": {Area: "G", Address: 18, Type: "byte"}},
+			[]PmcRange{{"G", 0, 0}, {"G", 18, 18}}},
+		"areas": {map[string]PmcSignal{"a": {Area: "F", Address: 0, Type: "byte"}, "b": {Area: "G", Address: 1, Type: "byte"}},
+			[]PmcRange{{"G", 1, 1}, {"F", 0, 0}}},
+		"overlap": {map[string]PmcSignal{"a": {Area: "D", Address: 10, Type: "dword"}, "b": {Area: "D", Address: 11, Type: "bit", Bit: 2}, "c": {Area: "D", Address: 12, Type: "word"}},
+			[]PmcRange{{"D", 10, 13}}},
+		"width": {map[string]PmcSignal{"a": {Area: "D", Address: 0, Type: "byte"}, "b": {Area: "D", Address: 5, Type: "float"}},
+			[]PmcRange{{"D", 0, 8}}},
+		"max range": {long, []PmcRange{{"R", 0, 190}, {"R", 200, 200}}},
+	}
+	for name, test_case := range cases {
+		t.Run(name, func(t *testing.T) {
+			if ranges := GetPmcRanges(test_case.signals); !slices.Equal(ranges, test_case.expected) {
+				t.Fatalf("ranges %v, expected %v", ranges, test_case.expected)
+			}
+		})
+	}
+}
+
+func TestReadPmcSignals(t *testing.T) {
+	signals := map[string]PmcSignal{
+		"bit_on":  {Area: "D", Address: 10, Type: "bit", Bit: 3},
+		"bit_off": {Area: "D", Address: 10, Type: "bit", Bit: 4},
+		"byte":    {Area: "D", Address: 10, Type: "byte"},
+		"word":    {Area: "D", Address: 11, Type: "word"},
+		"dword":   {Area: "D", Address: 13, Type: "dword"},
+		"float":   {Area: "D", Address: 17, Type: "float"},
+		"inside":  {Area: "D", Address: 14, Type: "byte"},
+	}
+	data := []byte{0x08, 0xfe, 0xff, 0x40, 0xe2, 0x01, 0x00, 0x00, 0x00, 0xc0, 0x3f}
+	fake := NewFakeClient()
+	fake.Connect("", 0, 0)
+	fake.SetValue("ReadPmcRange", data)
+	values, ret := ReadPmcSignals(fake, signals)
+	if ret != EW_OK {
+		t.Fatalf("ReadPmcSignals: %d", ret)
+	}
+	expected := map[string]any{
+		"bit_on": true, "bit_off": false, "byte": int16(8), "word": int16(-2),
+		"dword": int32(123456), "float": float64(1.5), "inside": int16(0xe2),
+	}
+	for name, value := range expected {
+		if values[name] != value {
+			t.Fatalf("%s: %v (%T), expected %v (%T)", name, values[name], values[name], value, value)
+		}
+	}
+	if count := CountCalls(fake.Calls(), "ReadPmcRange"); count != 1 {
+		t.Fatalf("reads %d, expected 1", count)
+	}
+	// short reply and read errors leave no values
+	fake.SetValue("ReadPmcRange", data[:8])
+	if values, ret := ReadPmcSignals(fake, signals); ret != EW_LENGTH || len(values) != 0 {
+		t.Fatalf("short data: %v, %d", values, ret)
+	}
+	fake.SetError("ReadPmcRange", EW_NUMBER)
+	if values, ret := ReadPmcSignals(fake, signals); ret != EW_NUMBER || len(values) != 0 {
+		t.Fatalf("read error: %v, %d", values, ret)
+	}
+}
+
+func TestGetMacroFields(t *testing.T) {
+	device := NewTestDevice("macros")
+	device.Macros = map[string]MacroVariable{
+		"feed":  {Number: 100},
+		"parts": {Number: 500, Count: 3, Type: "int"},
+		"done":  {Number: 200, Count: 1, Type: "bool"},
+	}
+	expected := map[string]MacroField{
+		"feed":      {Number: 100, Type: "float"},
+		"parts_500": {Number: 500, Type: "int"},
+		"parts_501": {Number: 501, Type: "int"},
+		"parts_502": {Number: 502, Type: "int"},
+		"done":      {Number: 200, Type: "bool"},
+	}
+	if fields := GetMacroFields(&device); !maps.Equal(fields, expected) {
+		t.Fatalf("fields %v, expected %v", fields, expected)
+	}
+}
+
+func TestGetMacroRanges(t *testing.T) {
+	cases := map[string]struct {
+		numbers  []int32
+		expected []MacroRange
+	}{
+		"duplicate": {[]int32{100, 100, 101}, []MacroRange{{100, 101}}},
+		"max gap":   {[]int32{100, 111}, []MacroRange{{100, 111}}},
+		"large gap": {[]int32{100, 112}, []MacroRange{{100, 100}, {112, 112}}},
+		"batch":     {[]int32{1, 11, 21, 31, 41, 51, 61, 71, 81, 91, 101}, []MacroRange{{1, 91}, {101, 101}}},
+	}
+	for name, test_case := range cases {
+		t.Run(name, func(t *testing.T) {
+			fields := make(map[string]MacroField)
+			for index, number := range test_case.numbers {
+				fields[string(rune('a'+index))] = MacroField{Number: number, Type: "float"}
+			}
+			if ranges := GetMacroRanges(fields); !slices.Equal(ranges, test_case.expected) {
+				t.Fatalf("ranges %v, expected %v", ranges, test_case.expected)
+			}
+		})
+	}
+}
+
+func TestGetMacroValue(t *testing.T) {
+	cases := map[string]struct {
+		value    CncMacroValue
+		kind     string
+		expected any
+	}{
+		"float":        {CncMacroValue{Mantissa: 12345, Exponent: 3}, "float", 12.345},
+		"zero":         {CncMacroValue{}, "float", float64(0)},
+		"int":          {CncMacroValue{Mantissa: 25, Exponent: 1}, "int", int32(3)},
+		"negative int": {CncMacroValue{Mantissa: -15, Exponent: 1}, "int", int32(-2)},
+		"false":        {CncMacroValue{}, "bool", false},
+		"true":         {CncMacroValue{Mantissa: 1, Exponent: 3}, "bool", true},
+		"vacant float": {CncMacroValue{Exponent: -1}, "float", nil},
+		"vacant int":   {CncMacroValue{Exponent: -1}, "int", nil},
+		"vacant bool":  {CncMacroValue{Exponent: -1}, "bool", nil},
+		"nan":          {NewMacroDouble(math.NaN()), "float", nil},
+		"double":       {NewMacroDouble(-1.25), "float", -1.25},
+	}
+	for name, test_case := range cases {
+		t.Run(name, func(t *testing.T) {
+			if value := GetMacroValue(MacroField{Type: test_case.kind}, test_case.value); value != test_case.expected {
+				t.Fatalf("value %v (%T), expected %v (%T)", value, value, test_case.expected, test_case.expected)
+			}
+		})
+	}
+	if value := NewMacroDouble(1.25); value != (CncMacroValue{Mantissa: 125, Exponent: 2}) {
+		t.Fatalf("NewMacroDouble: %v", value)
+	}
+}
+
+func TestReadMacroVariables(t *testing.T) {
+	fields := map[string]MacroField{
+		"feed":   {Number: 100, Type: "float"},
+		"count":  {Number: 101, Type: "int"},
+		"done":   {Number: 103, Type: "bool"},
+		"vacant": {Number: 104, Type: "float"},
+	}
+	fake := NewFakeClient()
+	fake.Connect("", 0, 0)
+	fake.SetValue("ReadMacroRange", []CncMacroValue{{Mantissa: 15, Exponent: 1}, {Mantissa: 7}, {}, {Mantissa: 1}, {Exponent: -1}})
+	values, ret := ReadMacroVariables(fake, fields)
+	expected := map[string]any{"feed": 1.5, "count": int32(7), "done": true, "vacant": nil}
+	if ret != EW_OK || !maps.Equal(values, expected) {
+		t.Fatalf("ReadMacroVariables: %v, %d", values, ret)
+	}
+	if count := CountCalls(fake.Calls(), "ReadMacroRange"); count != 1 {
+		t.Fatalf("reads %d, expected 1", count)
+	}
+	// short reply and read errors leave no values
+	fake.SetValue("ReadMacroRange", []CncMacroValue{{Mantissa: 15, Exponent: 1}})
+	if values, ret := ReadMacroVariables(fake, fields); ret != EW_LENGTH || len(values) != 0 {
+		t.Fatalf("short data: %v, %d", values, ret)
+	}
+	fake.SetError("ReadMacroRange", EW_NUMBER)
+	if values, ret := ReadMacroVariables(fake, fields); ret != EW_NUMBER || len(values) != 0 {
+		t.Fatalf("read error: %v, %d", values, ret)
+	}
+}
+
+func TestAlarmMessageEvents(t *testing.T) {
+	log_buf := CaptureLog(t)
+	fake := NewFakeClient()
+	fake.Connect("", 0, 0)
+	device := NewTestDevice("alarm_messages")
+	device.Name = "alarm_events"
+	t.Cleanup(func() { delete(tag_events.active_alarms, device.Name) })
+	protocol_error := false
+	servo := NewAlarmMessage(401, 6, 1, " SERVO ALARM: X AXIS VRDY OFF ")
+	program := NewAlarmMessage(10, 3, 0, "IMPROPER G-CODE")
+	cycles := []struct {
+		alarms   []CncAlarmMessage
+		expected []string
+	}{
+		{[]CncAlarmMessage{}, nil},
+		{[]CncAlarmMessage{servo}, []string{"Авария alarm_events: SV0401 SERVO ALARM: X AXIS VRDY OFF"}},
+		{[]CncAlarmMessage{servo, program}, []string{"Авария alarm_events: PS0010 IMPROPER G-CODE"}},
+		{[]CncAlarmMessage{program}, []string{"Авария снята alarm_events: SV0401 SERVO ALARM: X AXIS VRDY OFF"}},
+		{[]CncAlarmMessage{program}, nil},
+		{[]CncAlarmMessage{}, []string{"Авария снята alarm_events: PS0010 IMPROPER G-CODE"}},
+	}
+	for index, cycle := range cycles {
+		log_buf.Reset()
+		fake.SetValue("GetAlarmMessages", cycle.alarms)
+		tag_map := ParseJsonData(t, GetFanucJsonData(&device, fake, NewTagSchedule(&device), &protocol_error))
+		if alarms, ok := tag_map["alarm_messages"].([]any); !ok || len(alarms) != len(cycle.alarms) {
+			t.Fatalf("cycle %d: alarm_messages %v", index, tag_map["alarm_messages"])
+		}
+		lines := strings.Split(strings.TrimSpace(log_buf.String()), "\n")
+		if len(cycle.expected) == 0 && log_buf.Len() != 0 || len(cycle.expected) != 0 && !slices.Equal(lines, cycle.expected) {
+			t.Fatalf("cycle %d: log %q, expected %q", index, log_buf.String(), cycle.expected)
+		}
+	}
+	fake.SetValue("GetAlarmMessages", []CncAlarmMessage{program})
+	tag_map := ParseJsonData(t, GetFanucJsonData(&device, fake, NewTagSchedule(&device), &protocol_error))
+	alarm := tag_map["alarm_messages"].([]any)[0].(map[string]any)
+	if alarm["number"] != float64(10) || alarm["type"] != float64(3) || alarm["type_name"] != "PS" || alarm["message"] != "IMPROPER G-CODE" {
+		t.Fatalf("alarm %v", alarm)
+	}
+}
+
+func UseAlarmHistoryState(t *testing.T) {
+	t.Helper()
+	UseTestPluginDir(t)
+	alarm_history_state = AlarmHistoryState{last_seen: make(map[string]string)}
+	t.Cleanup(func() { alarm_history_state = AlarmHistoryState{last_seen: make(map[string]string)} })
+}
+
+func TestReadAlarmHistory(t *testing.T) {
+	UseAlarmHistoryState(t)
+	// entry 1 is the latest alarm
+	entries := make([]CncAlarmHistoryEntry, 0)
+	for second := 12; second >= 1; second-- {
+		entries = append(entries, NewAlarmHistoryEntry(26, 3, 1, 10, 0, second, int32(second), 3, 0, "ALARM"))
+	}
+	read := func(count int, batches ...[]CncAlarmHistoryEntry) ([]CncAlarmHistoryEntry, int) {
+		t.Helper()
+		fake := NewFakeClient()
+		fake.Connect("", 0, 0)
+		fake.SetValue("GetAlarmHistoryCount", int32(count))
+		for _, batch := range batches {
+			fake.Script("GetAlarmHistory", FakeResult{Value: batch})
+		}
+		history, ret := ReadAlarmHistory(fake, "test")
+		if ret != EW_OK {
+			t.Fatalf("ReadAlarmHistory: %d", ret)
+		}
+		return history, CountCalls(fake.Calls(), "GetAlarmHistory")
+	}
+	// first read returns everything, oldest first
+	history, _ := read(3, entries[9:])
+	if len(history) != 3 || history[0].Number != 1 || history[2].Number != 3 {
+		t.Fatalf("first read %v", history)
+	}
+	if history, _ := read(3, entries[9:]); len(history) != 0 {
+		t.Fatalf("repeated read %v", history)
+	}
+	file_content, err := os.ReadFile(filepath.Join(plugin_dir, alarm_history_file))
+	if err != nil || !strings.Contains(string(file_content), entries[9].Key()) {
+		t.Fatalf("state file %s, %v", file_content, err)
+	}
+	// after a restart only the new entries are returned
+	alarm_history_state = AlarmHistoryState{last_seen: make(map[string]string)}
+	history, _ = read(5, entries[7:])
+	if len(history) != 2 || history[0].Number != 4 || history[1].Number != 5 {
+		t.Fatalf("read after restart %v", history)
+	}
+	// later batches are not read once the last seen entry is found
+	history, reads := read(12, entries[:10], entries[10:])
+	if len(history) != 7 || history[0].Number != 6 || history[6].Number != 12 {
+		t.Fatalf("batched read %v", history)
+	}
+	if reads != 1 {
+		t.Fatalf("history reads %d, expected 1", reads)
+	}
+	// unknown last seen entry reads the whole history
+	alarm_history_state.SetLastSeen("test", "unknown")
+	if history, reads := read(12, entries[:10], entries[10:]); len(history) != 12 || reads != 2 {
+		t.Fatalf("full read %d entries, %d reads", len(history), reads)
+	}
+}
+
+func TestReadAlarmHistoryBadStateFile(t *testing.T) {
+	UseAlarmHistoryState(t)
+	if err := os.WriteFile(filepath.Join(plugin_dir, alarm_history_file), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	log_buf := CaptureLog(t)
+	fake := NewFakeClient()
+	fake.Connect("", 0, 0)
+	fake.SetValue("GetAlarmHistoryCount", int32(1))
+	fake.SetValue("GetAlarmHistory", []CncAlarmHistoryEntry{NewAlarmHistoryEntry(26, 3, 1, 10, 0, 0, 10, 3, 0, "ALARM")})
+	if history, ret := ReadAlarmHistory(fake, "test"); ret != EW_OK || len(history) != 1 {
+		t.Fatalf("ReadAlarmHistory: %v, %d", history, ret)
+	}
+	if !strings.Contains(log_buf.String(), alarm_history_file) {
+		t.Fatalf("log %q", log_buf.String())
+	}
+}
+
+func TestDecodeCncText(t *testing.T) {
+	cases := map[string]struct {
+		data     []byte
+		encoding string
+		expected string
+	}{
+		"cp1251":          {[]byte{0xD1, 0xF2, 0xE0, 0xED, 0xEE, 0xEA, ' ', 0xB9, '5'}, "cp1251", "Станок №5"},
+		"cp1251 yo":       {[]byte{0xA8, 0xB8}, "cp1251", "Ёё"},
+		"latin1":          {[]byte{'9', '0', 0xB0, ' ', 'C', 'A', 'F', 0xC9}, "latin1", "90° CAFÉ"},
+		"jis_x0201":       {[]byte{'N', 'G', ' ', 0xB1, 0xDD, 0xA1}, "jis_x0201", "NG ｱﾝ｡"},
+		"jis_x0201 kanji": {[]byte{0x93, 0xFA}, "jis_x0201", "\uFFFD\uFFFD"},
+		"utf-8":           {[]byte("Станок"), "utf-8", "Станок"},
+		"invalid utf-8":   {[]byte{'A', 0xFF}, "", "A\uFFFD"},
+		"nul":             {[]byte{'O', 'K', 0, 'X', 'Y'}, "cp1251", "OK"},
+		"spaces":          {[]byte("  CHECK OIL  \x00"), "latin1", "CHECK OIL"},
+	}
+	for name, test_case := range cases {
+		t.Run(name, func(t *testing.T) {
+			if text := DecodeCncText(test_case.data, test_case.encoding); text != test_case.expected {
+				t.Fatalf("text %q, expected %q", text, test_case.expected)
+			}
+		})
+	}
+}
+
+func TestOperatorMessagesEncoding(t *testing.T) {
+	fake := NewFakeClient()
+	fake.Connect("", 0, 0)
+	fake.SetValue("GetOperatorMessages", []CncOperatorMessage{{Number: 2001, Type: 0, Data: []byte{0xD1, 0xEC, 0xE0, 0xE7, 0xEA, 0xE0, 0}}})
+	device := NewTestDevice("operator_messages")
+	device.TextEncoding = "cp1251"
+	protocol_error := false
+	tag_map := ParseJsonData(t, GetFanucJsonData(&device, fake, NewTagSchedule(&device), &protocol_error))
+	messages, ok := tag_map["operator_messages"].([]any)
+	if !ok || len(messages) != 1 {
+		t.Fatalf("operator_messages %v", tag_map["operator_messages"])
+	}
+	message := messages[0].(map[string]any)
+	if message["number"] != float64(2001) || message["message"] != "Смазка" {
+		t.Fatalf("message %v", message)
+	}
+}
+
+func TestReadModal(t *testing.T) {
+	fake := NewFakeClient()
+	fake.Connect("", 0, 0)
+	fake.SetValue("GetGCodes", []CncGCode{{Group: 1, Code: "G01"}, {Group: 3, Code: "G90"}, {Group: 14, Code: "G54.1"}, {Group: 25, Code: "G13.1"}})
+	fake.SetValue("GetModalCodes", map[string]int32{"m": 3, "s": 1200, "t": 101, "f": 500, "h": 1, "d": 11})
+	modal, ret := ReadModal(fake)
+	expected := map[string]any{
+		"motion": "G01", "distance": "G90", "work_offset": "G54.1", "group_25": "G13.1",
+		"m": int32(3), "s": int32(1200), "t": int32(101), "f": int32(500), "h": int32(1), "d": int32(11),
+	}
+	if ret != EW_OK || !maps.Equal(modal, expected) {
+		t.Fatalf("ReadModal: %v, %d", modal, ret)
+	}
+	// every group read has an OPC UA field except unknown ones
+	field_types := GetModalFieldTypes()
+	for name, value := range modal {
+		if _, ok := field_types[name]; !ok && name != "group_25" {
+			t.Fatalf("no field type of %s: %v", name, value)
+		}
+	}
+	fake.SetError("GetModalCodes", EW_NOOPT)
+	if modal, ret := ReadModal(fake); ret != EW_NOOPT || len(modal) != 0 {
+		t.Fatalf("modal codes error: %v, %d", modal, ret)
+	}
+	fake.SetError("GetGCodes", EW_FUNC)
+	if modal, ret := ReadModal(fake); ret != EW_FUNC || len(modal) != 0 {
+		t.Fatalf("G codes error: %v, %d", modal, ret)
+	}
+}
+
+func TestProgramNumbersAndName(t *testing.T) {
+	fake := NewFakeClient()
+	fake.Connect("", 0, 0)
+	fake.SetValue("GetMainProgNum", int32(12345678))
+	fake.SetValue("GetSubProgNum", int32(99999999))
+	fake.SetValue("GetProgramPath", "//CNC_MEM/USER/LIBRARY/PART_A")
+	device := NewTestDevice("main_prog_number", "sub_prog_number", "program_name", "program_path", "errors")
+	protocol_error := false
+	tag_map := ParseJsonData(t, GetFanucJsonData(&device, fake, NewTagSchedule(&device), &protocol_error))
+	if tag_map["main_prog_number"] != float64(12345678) || tag_map["sub_prog_number"] != float64(99999999) {
+		t.Fatalf("program numbers %v, %v", tag_map["main_prog_number"], tag_map["sub_prog_number"])
+	}
+	if tag_map["program_name"] != "PART_A" || tag_map["program_path"] != "//CNC_MEM/USER/LIBRARY/PART_A" {
+		t.Fatalf("program %v, %v", tag_map["program_name"], tag_map["program_path"])
+	}
+	// name and path share one read
+	if count := CountCalls(fake.Calls(), "GetProgramPath"); count != 1 {
+		t.Fatalf("path reads %d, expected 1", count)
+	}
+	fake.SetError("GetProgramPath", EW_FUNC)
+	tag_map = ParseJsonData(t, GetFanucJsonData(&device, fake, NewTagSchedule(&device), &protocol_error))
+	errors := tag_map["errors"].(map[string]any)
+	if _, ok := tag_map["program_name"]; ok || errors["program_name"] != float64(EW_FUNC) || errors["program_path"] != float64(EW_FUNC) {
+		t.Fatalf("path error: %v", tag_map)
+	}
+	// native client keeps 8-digit numbers
+	state := NewTestFocasState()
+	state.MainProgram = 12345678
+	state.RunningProgram = 87654321
+	client := StartTestFocasServer(t, state)
+	if number, ret := client.GetMainProgNum(); ret != EW_OK || number != 12345678 {
+		t.Fatalf("GetMainProgNum: %d, %d", number, ret)
+	}
+	if number, ret := client.GetSubProgNum(); ret != EW_OK || number != 87654321 {
+		t.Fatalf("GetSubProgNum: %d, %d", number, ret)
+	}
+}
+
+func TestReadToolLife(t *testing.T) {
+	fake := NewFakeClient()
+	fake.Connect("", 0, 0)
+	fake.SetValue("GetToolLifeInfo", CncToolLifeInfo{MaxGroups: 20, MaxTools: 4, UseGroup: 2, NextGroup: 17})
+	fake.Script("GetToolLifeGroups",
+		FakeResult{Value: []CncToolLifeGroup{
+			{Group: 1, ToolCount: 2, Life: 100, Count: 120, UseTool: 12, RestSignal: true},
+			{Group: 2, ToolCount: 1, Life: 50, Count: 10, UseTool: 5, CountType: 1},
+			{Group: 3},
+		}},
+		FakeResult{Value: []CncToolLifeGroup{{Group: 17, ToolCount: 2, Life: 30, Count: 0}}},
+	)
+	fake.Script("GetToolLifeTools",
+		FakeResult{Value: []CncToolLifeTool{{Number: 11, Info: 2}, {Number: 12, Info: 3}}},
+		FakeResult{Value: []CncToolLifeTool{{Number: 5, Info: 1}}},
+		FakeResult{Value: []CncToolLifeTool{{Number: 20, Info: 0}, {Number: 21, Info: 2}}},
+	)
+	groups, ret := ReadToolLife(fake)
+	if ret != EW_OK || len(groups) != 3 {
+		t.Fatalf("ReadToolLife: %v, %d", groups, ret)
+	}
+	expired, in_use, available := groups[0], groups[1], groups[2]
+	if expired.Group != 1 || expired.State != "expired" || expired.Remaining != 0 || !expired.Notice || expired.CountType != "cycles" {
+		t.Fatalf("expired group %+v", expired)
+	}
+	if !slices.Equal(expired.Tools, []ToolLifeTool{{Number: 11, State: "expired"}, {Number: 12, State: "skipped"}}) {
+		t.Fatalf("expired group tools %+v", expired.Tools)
+	}
+	if in_use.Group != 2 || in_use.State != "in_use" || in_use.Remaining != 40 || in_use.CountType != "minutes" || in_use.Tool != 5 {
+		t.Fatalf("group in use %+v", in_use)
+	}
+	if available.Group != 17 || available.State != "available" || available.Remaining != 30 {
+		t.Fatalf("available group %+v", available)
+	}
+	// 20 groups are read in two batches, the empty group has no tools read
+	if count := CountCalls(fake.Calls(), "GetToolLifeGroups"); count != 2 {
+		t.Fatalf("group reads %d, expected 2", count)
+	}
+	if count := CountCalls(fake.Calls(), "GetToolLifeTools"); count != 3 {
+		t.Fatalf("tool reads %d, expected 3", count)
+	}
+	fake.SetError("GetToolLifeTools", EW_NUMBER)
+	if groups, ret := ReadToolLife(fake); ret != EW_NUMBER || len(groups) != 0 {
+		t.Fatalf("tools error: %v, %d", groups, ret)
+	}
+	fake.SetError("GetToolLifeInfo", EW_NOOPT)
+	if groups, ret := ReadToolLife(fake); ret != EW_NOOPT || len(groups) != 0 {
+		t.Fatalf("info error: %v, %d", groups, ret)
+	}
+}
+
+func TestReadWorkOffsets(t *testing.T) {
+	fake := NewFakeClient()
+	fake.Connect("", 0, 0)
+	fake.SetValue("GetAxisNames", []string{"X", "Y", "Z"})
+	fake.SetValue("GetWorkOffsetCount", int16(2))
+	standard := [][]int32{{0, 0, 0}, {1500, -250, 0}, {2000, 0, 0}, {0, 0, 0}, {0, 0, 0}, {0, 0, 0}, {0, 0, 0}}
+	fake.Script("GetWorkOffsets", FakeResult{Value: standard}, FakeResult{Value: [][]int32{{10, 20, 30}, {-1, -2, -3}}})
+	fake.SetValue("GetWorkShift", []int32{100, 0, -100})
+	offsets, ret := ReadWorkOffsets(fake)
+	if ret != EW_OK || len(offsets) != 10 {
+		t.Fatalf("ReadWorkOffsets: %v, %d", offsets, ret)
+	}
+	expected := map[string]map[string]float64{
+		"g54":      {"X": 1.5, "Y": -0.25, "Z": 0},
+		"g54_1_p1": {"X": 0.01, "Y": 0.02, "Z": 0.03},
+		"g54_1_p2": {"X": -0.001, "Y": -0.002, "Z": -0.003},
+		"shift":    {"X": 0.1, "Y": 0, "Z": -0.1},
+	}
+	for name, values := range expected {
+		if !maps.Equal(offsets[name], values) {
+			t.Fatalf("%s: %v, expected %v", name, offsets[name], values)
+		}
+	}
+	if count := CountCalls(fake.Calls(), "GetWorkOffsets"); count != 2 {
+		t.Fatalf("offset reads %d, expected 2", count)
+	}
+	// without extended offsets and shift only G54-G59 and external are read
+	fake = NewFakeClient()
+	fake.Connect("", 0, 0)
+	fake.SetValue("GetAxisNames", []string{"X", "Y", "Z"})
+	fake.SetError("GetWorkOffsetCount", EW_NOOPT)
+	fake.SetValue("GetWorkOffsets", standard)
+	fake.SetError("GetWorkShift", EW_FUNC)
+	offsets, ret = ReadWorkOffsets(fake)
+	if _, ok := offsets["shift"]; ret != EW_OK || len(offsets) != 7 || ok {
+		t.Fatalf("standard offsets: %v, %d", offsets, ret)
+	}
+	fake.SetError("GetWorkShift", EW_NUMBER)
+	if offsets, ret := ReadWorkOffsets(fake); ret != EW_NUMBER || len(offsets) != 0 {
+		t.Fatalf("shift error: %v, %d", offsets, ret)
+	}
+}
+
+func TestWorkOffsetEvents(t *testing.T) {
+	log_buf := CaptureLog(t)
+	fake := NewFakeClient()
+	fake.Connect("", 0, 0)
+	fake.SetValue("GetAxisNames", []string{"X", "Z"})
+	fake.SetValue("GetWorkOffsetCount", int16(0))
+	fake.SetError("GetWorkShift", EW_NOOPT)
+	device := NewTestDevice("work_offsets", "work_offset_events")
+	device.Name = "work_offset_events"
+	t.Cleanup(func() { delete(work_offset_tables.tables, device.Name) })
+	protocol_error := false
+	read := func(offsets [][]int32) []any {
+		t.Helper()
+		fake.SetValue("GetWorkOffsets", offsets)
+		tag_map := ParseJsonData(t, GetFanucJsonData(&device, fake, NewTagSchedule(&device), &protocol_error))
+		events, ok := tag_map["work_offset_events"].([]any)
+		if !ok {
+			t.Fatalf("work_offset_events %v", tag_map["work_offset_events"])
+		}
+		return events
+	}
+	offsets := [][]int32{{0, 0}, {1000, 2000}, {0, 0}, {0, 0}, {0, 0}, {0, 0}, {0, 0}}
+	if events := read(offsets); len(events) != 0 {
+		t.Fatalf("first read events %v", events)
+	}
+	if events := read(offsets); len(events) != 0 {
+		t.Fatalf("unchanged events %v", events)
+	}
+	offsets[1][1] = 2500
+	offsets[2][0] = -300
+	events := read(offsets)
+	if len(events) != 2 {
+		t.Fatalf("changed events %v", events)
+	}
+	g54 := events[0].(map[string]any)
+	g55 := events[1].(map[string]any)
+	if g54["offset"] != "g54" || g54["axis"] != "Z" || g54["old"] != float64(2) || g54["new"] != 2.5 {
+		t.Fatalf("g54 event %v", g54)
+	}
+	if g55["offset"] != "g55" || g55["axis"] != "X" || g55["old"] != float64(0) || g55["new"] != -0.3 {
+		t.Fatalf("g55 event %v", g55)
+	}
+	if !strings.Contains(log_buf.String(), "Изменено смещение work_offset_events g54 Z: 2 -> 2.5") {
+		t.Fatalf("log %q", log_buf.String())
+	}
+	if events := read(offsets); len(events) != 0 {
+		t.Fatalf("events after change %v", events)
+	}
+}
+
+func TestDistanceToGoAndCommandedPositions(t *testing.T) {
+	fake := NewFakeClient()
+	fake.Connect("", 0, 0)
+	fake.SetValue("SetPath", nil)
+	fake.Script("GetDistanceToGo", FakeResult{Value: map[string]float64{"X": 0.25, "Z": -1.5}}, FakeResult{Value: map[string]float64{"X2": 4}})
+	fake.Script("GetCommandedPositions", FakeResult{Value: map[string]float64{"X": 12.595, "Z": -9}}, FakeResult{Value: map[string]float64{"X2": 54}})
+	device := NewTestDevice("distance_to_go", "commanded_positions")
+	device.Paths = []int16{1, 2}
+	protocol_error := false
+	tag_map := ParseJsonData(t, GetFanucJsonData(&device, fake, NewTagSchedule(&device), &protocol_error))
+	paths := tag_map["paths"].(map[string]any)
+	first, second := paths["1"].(map[string]any), paths["2"].(map[string]any)
+	if distance := first["distance_to_go"].(map[string]any); distance["X"] != 0.25 || distance["Z"] != -1.5 {
+		t.Fatalf("path 1 distance_to_go %v", distance)
+	}
+	if commanded := second["commanded_positions"].(map[string]any); commanded["X2"] != float64(54) {
+		t.Fatalf("path 2 commanded_positions %v", commanded)
+	}
+	// first path duplicates into the device tags
+	if commanded := tag_map["commanded_positions"].(map[string]any); commanded["X"] != 12.595 {
+		t.Fatalf("commanded_positions %v", commanded)
+	}
+	// commanded position is the absolute position plus the distance to go
+	client := StartTestFocasServer(t, NewTestFocasState())
+	absolute, _ := client.GetAbsolutePositions()
+	distance, _ := client.GetDistanceToGo()
+	commanded, ret := client.GetCommandedPositions()
+	if ret != EW_OK {
+		t.Fatalf("GetCommandedPositions: %d", ret)
+	}
+	for axis, value := range absolute {
+		if diff := commanded[axis] - value - distance[axis]; diff > 1e-9 || diff < -1e-9 {
+			t.Fatalf("%s: commanded %v, absolute %v, distance %v", axis, commanded[axis], value, distance[axis])
+		}
+	}
+}
+
+func TestDriveHealthItems(t *testing.T) {
+	device := NewTestDevice("drive_health")
+	if items := GetServoHealthItems(&device); !maps.Equal(items, servo_health_items) {
+		t.Fatalf("default servo items %v", items)
+	}
+	device.DriveHealth = &DriveHealthConfig{
+		Servo:   map[string]CncDataItem{"pulse_coder_temperature": {}, "disturbance": {Number: 353}},
+		Spindle: map[string]CncDataItem{"motor_temperature": {Number: 410, Type: "word"}},
+	}
+	servo := GetServoHealthItems(&device)
+	expected := map[string]CncDataItem{
+		"motor_temperature": servo_health_items["motor_temperature"],
+		"amplifier_status":  servo_health_items["amplifier_status"],
+		"disturbance":       {Number: 353, Type: "2-word"},
+	}
+	if !maps.Equal(servo, expected) {
+		t.Fatalf("servo items %v, expected %v", servo, expected)
+	}
+	if spindle := GetSpindleHealthItems(&device); spindle["motor_temperature"] != (CncDataItem{Number: 410, Type: "word"}) {
+		t.Fatalf("spindle items %v", spindle)
+	}
+}
+
+func TestReadDriveHealth(t *testing.T) {
+	servo_items := map[string]CncDataItem{"motor_temperature": {Number: 308, Type: "byte"}}
+	spindle_items := map[string]CncDataItem{"motor_temperature": {Number: 403, Type: "byte"}}
+	fake := NewFakeClient()
+	fake.Connect("", 0, 0)
+	fake.SetValue("GetAxisNames", []string{"X", "Z"})
+	fake.SetValue("GetSpindleNames", []string{"S1", "S2"})
+	fake.SetValue("GetSpindleAlarms", []int16{0, 9001})
+	// diagnosis of X, Z, S1 and S2, Z has no sensor
+	fake.Script("ReadDiagnosis",
+		FakeResult{Value: CncDataValue{Value: 45}},
+		FakeResult{Error: EW_NUMBER},
+		FakeResult{Value: CncDataValue{Value: 60}},
+		FakeResult{Value: CncDataValue{Value: 200}},
+	)
+	health, ret := ReadDriveHealth(fake, servo_items, spindle_items)
+	if ret != EW_OK || len(health) != 4 {
+		t.Fatalf("ReadDriveHealth: %v, %d", health, ret)
+	}
+	expected := map[string]map[string]any{
+		"X":  {"motor_temperature": int16(45)},
+		"Z":  {},
+		"S1": {"motor_temperature": int16(60), "alarm": int16(0)},
+		"S2": {"motor_temperature": int16(200), "alarm": int16(9001)},
+	}
+	for name, values := range expected {
+		if !maps.Equal(health[name], values) {
+			t.Fatalf("%s: %v, expected %v", name, health[name], values)
+		}
+	}
+	// spindles without the alarm function have no alarm
+	fake.SetValue("ReadDiagnosis", CncDataValue{Value: 50})
+	fake.SetError("GetSpindleAlarms", EW_NOOPT)
+	health, ret = ReadDriveHealth(fake, servo_items, spindle_items)
+	if _, ok := health["S1"]["alarm"]; ret != EW_OK || ok || health["S1"]["motor_temperature"] != int16(50) {
+		t.Fatalf("without spindle alarms: %v, %d", health, ret)
+	}
+	fake.SetError("ReadDiagnosis", EW_SOCKET)
+	if health, ret := ReadDriveHealth(fake, servo_items, spindle_items); ret != EW_SOCKET || len(health) != 0 {
+		t.Fatalf("diagnosis error: %v, %d", health, ret)
+	}
+}
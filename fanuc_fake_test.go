@@ -0,0 +1,444 @@
+package main
+
+import "sync"
+
+type FakeResult struct {
+	Value any
+	Error int16
+}
+
+// in-memory backend returning scripted values and error codes
+type FakeClient struct {
+	mutex          sync.Mutex
+	connect_errors []int16
+	free_error     int16
+	connected      bool
+	results        map[string][]FakeResult
+	calls          []string
+}
+
+func NewFakeClient() *FakeClient {
+	return &FakeClient{results: make(map[string][]FakeResult)}
+}
+
+// results are returned in order, the last one is repeated
+func (fake *FakeClient) Script(method string, results ...FakeResult) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	fake.results[method] = append(fake.results[method], results...)
+}
+
+func (fake *FakeClient) SetValue(method string, value any) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	fake.results[method] = []FakeResult{{Value: value}}
+}
+
+func (fake *FakeClient) SetError(method string, error_code int16) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	fake.results[method] = []FakeResult{{Error: error_code}}
+}
+
+func (fake *FakeClient) ScriptConnect(error_codes ...int16) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	fake.connect_errors = append(fake.connect_errors, error_codes...)
+}
+
+func (fake *FakeClient) ScriptFree(error_code int16) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	fake.free_error = error_code
+}
+
+func (fake *FakeClient) Calls() []string {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	return append([]string(nil), fake.calls...)
+}
+
+func (fake *FakeClient) Connected() bool {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	return fake.connected
+}
+
+func (fake *FakeClient) next(method string) (FakeResult, bool) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	fake.calls = append(fake.calls, method)
+	if !fake.connected {
+		return FakeResult{Error: EW_HANDLE}, true
+	}
+	queue, ok := fake.results[method]
+	if !ok || len(queue) == 0 {
+		return FakeResult{}, false
+	}
+	result := queue[0]
+	if len(queue) > 1 {
+		fake.results[method] = queue[1:]
+	}
+	return result, true
+}
+
+func FakeCall[T any](fake *FakeClient, method string) (T, int16) {
+	var result T
+	scripted, ok := fake.next(method)
+	if !ok {
+		return result, EW_FUNC
+	}
+	if scripted.Error != EW_OK {
+		return result, scripted.Error
+	}
+	return ConvertScriptedValue[T](scripted.Value)
+}
+
+func (fake *FakeClient) Connect(address string, port int, timeout int) int16 {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	fake.calls = append(fake.calls, "Connect")
+	if len(fake.connect_errors) > 0 {
+		connect_error := fake.connect_errors[0]
+		fake.connect_errors = fake.connect_errors[1:]
+		if connect_error != EW_OK {
+			return connect_error
+		}
+	}
+	fake.connected = true
+	return EW_OK
+}
+
+func (fake *FakeClient) Free() int16 {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	fake.calls = append(fake.calls, "Free")
+	if !fake.connected {
+		return EW_HANDLE
+	}
+	if fake.free_error != EW_OK {
+		return fake.free_error
+	}
+	fake.connected = false
+	return EW_OK
+}
+
+func (fake *FakeClient) Handle() uint16 {
+	return 0
+}
+
+func (fake *FakeClient) GetMaxPath() (int16, int16) {
+	return FakeCall[int16](fake, "GetMaxPath")
+}
+
+func (fake *FakeClient) SetPath(path int16) int16 {
+	_, ret := FakeCall[any](fake, "SetPath")
+	if ret == EW_FUNC {
+		return EW_OK
+	}
+	return ret
+}
+
+func (fake *FakeClient) GetAxisNames() ([]string, int16) {
+	return FakeCall[[]string](fake, "GetAxisNames")
+}
+
+func (fake *FakeClient) GetSpindleNames() ([]string, int16) {
+	return FakeCall[[]string](fake, "GetSpindleNames")
+}
+
+func (fake *FakeClient) GetStatInfo() (CncStatInfo, int16) {
+	return FakeCall[CncStatInfo](fake, "GetStatInfo")
+}
+
+func (fake *FakeClient) GetExecProgram() (string, int16) {
+	return FakeCall[string](fake, "GetExecProgram")
+}
+
+func (fake *FakeClient) GetExecBlocks() (CncExecBlocks, int16) {
+	return FakeCall[CncExecBlocks](fake, "GetExecBlocks")
+}
+
+func (fake *FakeClient) GetBlockCount() (int64, int16) {
+	return FakeCall[int64](fake, "GetBlockCount")
+}
+
+func (fake *FakeClient) GetTimerParams(numbers []int32) (map[int32]int64, int16) {
+	return FakeCall[map[int32]int64](fake, "GetTimerParams")
+}
+
+func (fake *FakeClient) GetAut() (int16, int16) {
+	return FakeCall[int16](fake, "GetAut")
+}
+
+func (fake *FakeClient) GetRun() (int16, int16) {
+	return FakeCall[int16](fake, "GetRun")
+}
+
+func (fake *FakeClient) GetEdit() (int16, int16) {
+	return FakeCall[int16](fake, "GetEdit")
+}
+
+func (fake *FakeClient) GetMstb() (int16, int16) {
+	return FakeCall[int16](fake, "GetMstb")
+}
+
+func (fake *FakeClient) GetMotion() (int16, int16) {
+	return FakeCall[int16](fake, "GetMotion")
+}
+
+func (fake *FakeClient) GetG00() (int16, int16) {
+	return FakeCall[int16](fake, "GetG00")
+}
+
+func (fake *FakeClient) GetGCodes() ([]CncGCode, int16) {
+	return FakeCall[[]CncGCode](fake, "GetGCodes")
+}
+
+func (fake *FakeClient) GetModalCodes() (map[string]int32, int16) {
+	return FakeCall[map[string]int32](fake, "GetModalCodes")
+}
+
+func (fake *FakeClient) GetShutdowns() (int16, int16) {
+	return FakeCall[int16](fake, "GetShutdowns")
+}
+
+func (fake *FakeClient) GetLoadExcess() (int16, int16) {
+	return FakeCall[int16](fake, "GetLoadExcess")
+}
+
+func (fake *FakeClient) GetMainProgNum() (int32, int16) {
+	return FakeCall[int32](fake, "GetMainProgNum")
+}
+
+func (fake *FakeClient) GetSubProgNum() (int32, int16) {
+	return FakeCall[int32](fake, "GetSubProgNum")
+}
+
+func (fake *FakeClient) GetProgramPath() (string, int16) {
+	return FakeCall[string](fake, "GetProgramPath")
+}
+
+func (fake *FakeClient) GetProgramDirectory(folder string) ([]CncProgram, int16) {
+	return FakeCall[[]CncProgram](fake, "GetProgramDirectory")
+}
+
+func (fake *FakeClient) UploadProgram(path string) ([]byte, int16) {
+	return FakeCall[[]byte](fake, "UploadProgram")
+}
+
+func (fake *FakeClient) GetFrameNumber() (int64, int16) {
+	return FakeCall[int64](fake, "GetFrameNumber")
+}
+
+func (fake *FakeClient) GetFrame() (string, int16) {
+	return FakeCall[string](fake, "GetFrame")
+}
+
+func (fake *FakeClient) GetPartsCount() (int64, int16) {
+	return FakeCall[int64](fake, "GetPartsCount")
+}
+
+func (fake *FakeClient) GetToolNumber() (int64, int16) {
+	return FakeCall[int64](fake, "GetToolNumber")
+}
+
+func (fake *FakeClient) GetToolOffsetInfo() (CncToolOffsetInfo, int16) {
+	return FakeCall[CncToolOffsetInfo](fake, "GetToolOffsetInfo")
+}
+
+func (fake *FakeClient) GetToolOffsets(offset_type int16, start int16, end int16) ([]int32, int16) {
+	return FakeCall[[]int32](fake, "GetToolOffsets")
+}
+
+func (fake *FakeClient) GetToolLifeInfo() (CncToolLifeInfo, int16) {
+	return FakeCall[CncToolLifeInfo](fake, "GetToolLifeInfo")
+}
+
+func (fake *FakeClient) GetToolLifeGroups(start int32, count int16) ([]CncToolLifeGroup, int16) {
+	return FakeCall[[]CncToolLifeGroup](fake, "GetToolLifeGroups")
+}
+
+func (fake *FakeClient) GetToolLifeTools(group int32, count int16) ([]CncToolLifeTool, int16) {
+	return FakeCall[[]CncToolLifeTool](fake, "GetToolLifeTools")
+}
+
+func (fake *FakeClient) GetWorkOffsetCount() (int16, int16) {
+	return FakeCall[int16](fake, "GetWorkOffsetCount")
+}
+
+func (fake *FakeClient) GetWorkOffsets(start int16, end int16, axes int16) ([][]int32, int16) {
+	return FakeCall[[][]int32](fake, "GetWorkOffsets")
+}
+
+func (fake *FakeClient) GetWorkShift(axes int16) ([]int32, int16) {
+	return FakeCall[[]int32](fake, "GetWorkShift")
+}
+
+func (fake *FakeClient) GetAbsolutePositions() (map[string]float64, int16) {
+	return FakeCall[map[string]float64](fake, "GetAbsolutePositions")
+}
+
+func (fake *FakeClient) GetRelativePositions() (map[string]float64, int16) {
+	return FakeCall[map[string]float64](fake, "GetRelativePositions")
+}
+
+func (fake *FakeClient) GetMachinePositions() (map[string]float64, int16) {
+	return FakeCall[map[string]float64](fake, "GetMachinePositions")
+}
+
+func (fake *FakeClient) GetDistanceToGo() (map[string]float64, int16) {
+	return FakeCall[map[string]float64](fake, "GetDistanceToGo")
+}
+
+func (fake *FakeClient) GetCommandedPositions() (map[string]float64, int16) {
+	return FakeCall[map[string]float64](fake, "GetCommandedPositions")
+}
+
+func (fake *FakeClient) GetFeedRate() (float64, int16) {
+	return FakeCall[float64](fake, "GetFeedRate")
+}
+
+func (fake *FakeClient) GetFeedRateParam1() (map[string]float64, int16) {
+	return FakeCall[map[string]float64](fake, "GetFeedRateParam1")
+}
+
+func (fake *FakeClient) GetFeedRateParam2() (map[string]float64, int16) {
+	return FakeCall[map[string]float64](fake, "GetFeedRateParam2")
+}
+
+func (fake *FakeClient) GetFeedOverride() (int16, int16) {
+	return FakeCall[int16](fake, "GetFeedOverride")
+}
+
+func (fake *FakeClient) GetJogOverride() (int16, int16) {
+	return FakeCall[int16](fake, "GetJogOverride")
+}
+
+func (fake *FakeClient) GetJogSpeed() (map[string]float64, int16) {
+	return FakeCall[map[string]float64](fake, "GetJogSpeed")
+}
+
+func (fake *FakeClient) GetServoLoad() (map[string]int64, int16) {
+	return FakeCall[map[string]int64](fake, "GetServoLoad")
+}
+
+func (fake *FakeClient) GetServoCurrentLoad() (map[string]float64, int16) {
+	return FakeCall[map[string]float64](fake, "GetServoCurrentLoad")
+}
+
+func (fake *FakeClient) GetServoCurrentLoadPercent() (map[string]int64, int16) {
+	return FakeCall[map[string]int64](fake, "GetServoCurrentLoadPercent")
+}
+
+func (fake *FakeClient) GetSpindleSpeed() (float64, int16) {
+	return FakeCall[float64](fake, "GetSpindleSpeed")
+}
+
+func (fake *FakeClient) GetSpindleSpeedParam() (map[string]int64, int16) {
+	return FakeCall[map[string]int64](fake, "GetSpindleSpeedParam")
+}
+
+func (fake *FakeClient) GetSpindleMotorSpeed() (map[string]int64, int16) {
+	return FakeCall[map[string]int64](fake, "GetSpindleMotorSpeed")
+}
+
+func (fake *FakeClient) GetSpindleLoad() (map[string]int64, int16) {
+	return FakeCall[map[string]int64](fake, "GetSpindleLoad")
+}
+
+func (fake *FakeClient) GetSpindleAlarms() ([]int16, int16) {
+	return FakeCall[[]int16](fake, "GetSpindleAlarms")
+}
+
+func (fake *FakeClient) GetSpindleOverride() (int16, int16) {
+	return FakeCall[int16](fake, "GetSpindleOverride")
+}
+
+func (fake *FakeClient) GetEmergency() (int16, int16) {
+	return FakeCall[int16](fake, "GetEmergency")
+}
+
+func (fake *FakeClient) GetAlarm() (int16, int16) {
+	return FakeCall[int16](fake, "GetAlarm")
+}
+
+func (fake *FakeClient) GetAlarmMessages() ([]CncAlarmMessage, int16) {
+	return FakeCall[[]CncAlarmMessage](fake, "GetAlarmMessages")
+}
+
+func (fake *FakeClient) GetAlarmHistoryCount() (int32, int16) {
+	return FakeCall[int32](fake, "GetAlarmHistoryCount")
+}
+
+func (fake *FakeClient) GetAlarmHistory(start int32, end int32) ([]CncAlarmHistoryEntry, int16) {
+	return FakeCall[[]CncAlarmHistoryEntry](fake, "GetAlarmHistory")
+}
+
+func (fake *FakeClient) GetOperatorMessages() ([]CncOperatorMessage, int16) {
+	return FakeCall[[]CncOperatorMessage](fake, "GetOperatorMessages")
+}
+
+func (fake *FakeClient) GetPowerOnTime() (int64, int16) {
+	return FakeCall[int64](fake, "GetPowerOnTime")
+}
+
+func (fake *FakeClient) GetOperationTime() (float64, int16) {
+	return FakeCall[float64](fake, "GetOperationTime")
+}
+
+func (fake *FakeClient) GetCuttingTime() (float64, int16) {
+	return FakeCall[float64](fake, "GetCuttingTime")
+}
+
+func (fake *FakeClient) GetCycleTime() (float64, int16) {
+	return FakeCall[float64](fake, "GetCycleTime")
+}
+
+func (fake *FakeClient) GetTimer(timer_type int16) (CncTimer, int16) {
+	return FakeCall[CncTimer](fake, "GetTimer")
+}
+
+func (fake *FakeClient) GetSeriesNumber() (string, int16) {
+	return FakeCall[string](fake, "GetSeriesNumber")
+}
+
+func (fake *FakeClient) GetVersionNumber() (string, int16) {
+	return FakeCall[string](fake, "GetVersionNumber")
+}
+
+func (fake *FakeClient) GetCtrlAxesNumber() (int16, int16) {
+	return FakeCall[int16](fake, "GetCtrlAxesNumber")
+}
+
+func (fake *FakeClient) GetCtrlSpindlesNumber() (int16, int16) {
+	return FakeCall[int16](fake, "GetCtrlSpindlesNumber")
+}
+
+func (fake *FakeClient) GetCtrlPathsNumber() (int16, int16) {
+	return FakeCall[int16](fake, "GetCtrlPathsNumber")
+}
+
+func (fake *FakeClient) GetSerialNumber() (int64, int16) {
+	return FakeCall[int64](fake, "GetSerialNumber")
+}
+
+func (fake *FakeClient) GetCncId() (string, int16) {
+	return FakeCall[string](fake, "GetCncId")
+}
+
+func (fake *FakeClient) ReadPmcRange(area int16, start uint16, end uint16) ([]byte, int16) {
+	return FakeCall[[]byte](fake, "ReadPmcRange")
+}
+
+func (fake *FakeClient) ReadMacroRange(start int32, end int32) ([]CncMacroValue, int16) {
+	return FakeCall[[]CncMacroValue](fake, "ReadMacroRange")
+}
+
+func (fake *FakeClient) ReadParameter(number int32, axis int16, size int16) (CncDataValue, int16) {
+	return FakeCall[CncDataValue](fake, "ReadParameter")
+}
+
+func (fake *FakeClient) ReadDiagnosis(number int32, axis int16, size int16) (CncDataValue, int16) {
+	return FakeCall[CncDataValue](fake, "ReadDiagnosis")
+}
@@ -0,0 +1,14 @@
+//go:build !windows && !fwlib
+
+package main
+
+// fwlib32 is not linked into this build, only the native protocol is available
+const fwlib_available = false
+
+func NewFwlibClient() CNCClient {
+	return nil
+}
+
+func FreeHandle(handle *uint16) int16 {
+	return EW_HANDLE
+}
@@ -0,0 +1,1039 @@
+package main
+
+import (
+	"encoding/binary"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const native_max_axis = 32
+const native_max_spindles = 8
+
+type FocasConn struct {
+	conn    net.Conn
+	timeout time.Duration
+	path    uint16
+	// unchecked function codes are sent, the peer is the built-in simulator
+	unchecked bool
+}
+
+func DialFocas(address string, port int, timeout int) (*FocasConn, int16) {
+	conn_timeout := time.Duration(timeout) * time.Second
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(address, strconv.Itoa(port)), conn_timeout)
+	if err != nil {
+		return nil, EW_SOCKET
+	}
+	focas := &FocasConn{conn: conn, timeout: conn_timeout}
+	body, ret := focas.exchange(focas_open_request, EncodeNative(uint16(2)), focas_open_response)
+	if ret != EW_OK {
+		conn.Close()
+		return nil, ret
+	}
+	var open_error int16
+	if len(body) >= 2 {
+		open_error = int16(binary.BigEndian.Uint16(body[0:2]))
+	}
+	if open_error != EW_OK {
+		conn.Close()
+		return nil, open_error
+	}
+	return focas, EW_OK
+}
+
+func (focas *FocasConn) Close() int16 {
+	_, ret := focas.exchange(focas_close_request, nil, focas_close_response)
+	focas.conn.Close()
+	return ret
+}
+
+func (focas *FocasConn) exchange(packet_type uint16, body []byte, response_type uint16) ([]byte, int16) {
+	focas.conn.SetDeadline(time.Now().Add(focas.timeout))
+	if err := WriteFocasPacket(focas.conn, packet_type, body); err != nil {
+		return nil, EW_SOCKET
+	}
+	read_type, read_body, err := ReadFocasPacket(focas.conn)
+	if err != nil {
+		if err == ErrFocasMagic {
+			return nil, EW_PROTOCOL
+		}
+		return nil, EW_SOCKET
+	}
+	if read_type != response_type {
+		return nil, EW_PROTOCOL
+	}
+	return read_body, EW_OK
+}
+
+func (focas *FocasConn) Exchange(requests []FocasRequest) ([]FocasResponse, int16) {
+	for _, request := range requests {
+		if focas_unchecked_functions[request.Function] && !focas.unchecked {
+			return nil, EW_FUNC
+		}
+	}
+	body, ret := focas.exchange(focas_data_request, EncodeFocasRequests(requests), focas_data_response)
+	if ret != EW_OK {
+		return nil, ret
+	}
+	responses, err := DecodeFocasResponses(body)
+	if err != nil || len(responses) != len(requests) {
+		return nil, EW_PROTOCOL
+	}
+	return responses, EW_OK
+}
+
+func (focas *FocasConn) Call(class uint16, function uint16, args ...int32) ([]byte, int16) {
+	request := FocasRequest{Class: class, Path: focas.path, Function: function}
+	copy(request.Args[:], args)
+	responses, ret := focas.Exchange([]FocasRequest{request})
+	if ret != EW_OK {
+		return nil, ret
+	}
+	if responses[0].Error != EW_OK {
+		return nil, responses[0].Error
+	}
+	return responses[0].Data, EW_OK
+}
+
+func (focas *FocasConn) Read(function uint16, out any, args ...int32) int16 {
+	data, ret := focas.Call(focas_class_cnc, function, args...)
+	if ret != EW_OK {
+		return ret
+	}
+	return DecodeNative(data, out)
+}
+
+func (focas *FocasConn) ReadElements(function uint16, args ...int32) ([]NativeAxisElement, int16) {
+	data, ret := focas.Call(focas_class_cnc, function, args...)
+	if ret != EW_OK {
+		return nil, ret
+	}
+	return DecodeNativeElements(data)
+}
+
+type NativeClient struct {
+	focas     *FocasConn
+	unchecked bool
+}
+
+func NewNativeClient() CNCClient {
+	return &NativeClient{}
+}
+
+// client of the built-in simulator, all function codes of the simulator are sent
+func NewSimulatorClient() CNCClient {
+	return &NativeClient{unchecked: true}
+}
+
+func (client *NativeClient) Connect(address string, port int, timeout int) int16 {
+	focas, ret := DialFocas(address, port, timeout)
+	if ret != EW_OK {
+		return ret
+	}
+	focas.unchecked = client.unchecked
+	client.focas = focas
+	return EW_OK
+}
+
+func (client *NativeClient) Free() int16 {
+	if client.focas == nil {
+		return EW_HANDLE
+	}
+	ret := client.focas.Close()
+	client.focas = nil
+	if ret == EW_SOCKET {
+		return EW_OK
+	}
+	return ret
+}
+
+func (client *NativeClient) Handle() uint16 {
+	return 0
+}
+
+func (client *NativeClient) read(function uint16, out any, args ...int32) int16 {
+	if client.focas == nil {
+		return EW_HANDLE
+	}
+	return client.focas.Read(function, out, args...)
+}
+
+func (client *NativeClient) readElements(function uint16, args ...int32) ([]NativeAxisElement, int16) {
+	if client.focas == nil {
+		return nil, EW_HANDLE
+	}
+	return client.focas.ReadElements(function, args...)
+}
+
+func (client *NativeClient) readElementsMap(function uint16, args ...int32) (map[string]float64, int16) {
+	result := make(map[string]float64)
+	elements, ret := client.readElements(function, args...)
+	if ret != EW_OK {
+		return result, ret
+	}
+	for _, element := range elements {
+		name := NativeString(element.Name[:])
+		if name == "" {
+			continue
+		}
+		result[name] = element.Value()
+	}
+	return result, EW_OK
+}
+
+func (client *NativeClient) readElementsIntMap(function uint16, args ...int32) (map[string]int64, int16) {
+	result := make(map[string]int64)
+	elements, ret := client.readElements(function, args...)
+	if ret != EW_OK {
+		return result, ret
+	}
+	for _, element := range elements {
+		name := NativeString(element.Name[:])
+		if name == "" {
+			continue
+		}
+		result[name] = int64(element.Data)
+	}
+	return result, EW_OK
+}
+
+func (client *NativeClient) statInfo() (NativeODBST, int16) {
+	var buf NativeODBST
+	return buf, client.read(fn_statinfo, &buf)
+}
+
+func (client *NativeClient) execProgram() (string, int16) {
+	blocks, ret := client.execBlocks()
+	return blocks.Text, ret
+}
+
+// length and executed blocks precede the text
+func (client *NativeClient) execBlocks() (CncExecBlocks, int16) {
+	if client.focas == nil {
+		return CncExecBlocks{}, EW_HANDLE
+	}
+	data, ret := client.focas.Call(focas_class_cnc, fn_rdexecprog, 1024)
+	if ret != EW_OK {
+		return CncExecBlocks{}, ret
+	}
+	if len(data) < 4 {
+		return CncExecBlocks{}, EW_PROTOCOL
+	}
+	length := int(binary.BigEndian.Uint16(data[0:2]))
+	text := data[4:]
+	if length < len(text) {
+		text = text[:length]
+	}
+	return CncExecBlocks{Text: NativeString(text), Executed: int16(binary.BigEndian.Uint16(data[2:4]))}, EW_OK
+}
+
+func (client *NativeClient) readParam(number int32) (int64, int16) {
+	var buf NativeIODBPSD
+	ret := client.read(fn_rdparam, &buf, number, -1)
+	if ret != EW_OK {
+		return 0, ret
+	}
+	return int64(buf.Value), EW_OK
+}
+
+// all parameters in one packet
+func (client *NativeClient) readParams(numbers []int32) (map[int32]int64, int16) {
+	result := make(map[int32]int64)
+	if client.focas == nil {
+		return result, EW_HANDLE
+	}
+	requests := make([]FocasRequest, 0, len(numbers))
+	for _, number := range numbers {
+		request := FocasRequest{Class: focas_class_cnc, Path: client.focas.path, Function: fn_rdparam}
+		request.Args[0] = number
+		request.Args[1] = -1
+		requests = append(requests, request)
+	}
+	responses, ret := client.focas.Exchange(requests)
+	if ret != EW_OK {
+		return result, ret
+	}
+	for index, response := range responses {
+		if response.Error != EW_OK {
+			return result, response.Error
+		}
+		var buf NativeIODBPSD
+		if ret := DecodeNative(response.Data, &buf); ret != EW_OK {
+			return result, ret
+		}
+		result[numbers[index]] = int64(buf.Value)
+	}
+	return result, EW_OK
+}
+
+func (client *NativeClient) readTimeParams(ms_number int32, min_number int32) (float64, int16) {
+	ms_value, ret := client.readParam(ms_number)
+	if ret != EW_OK {
+		return 0, ret
+	}
+	min_value, ret := client.readParam(min_number)
+	if ret != EW_OK {
+		return 0, ret
+	}
+	return JoinTimeParams(ms_value, min_value), EW_OK
+}
+
+func (client *NativeClient) panelSignals(slct int32) (NativeIODBSGNL, int16) {
+	var buf NativeIODBSGNL
+	return buf, client.read(fn_rdopnlsgnl, &buf, slct)
+}
+
+func (client *NativeClient) sysInfo() (NativeODBSYS, int16) {
+	var buf NativeODBSYS
+	return buf, client.read(fn_sysinfo, &buf)
+}
+
+func (client *NativeClient) sysInfoEx() (NativeODBSYSEX, int16) {
+	var buf NativeODBSYSEX
+	return buf, client.read(fn_sysinfo_ex, &buf)
+}
+
+func (client *NativeClient) programNumbers() (NativeODBPRO, int16) {
+	var buf NativeODBPRO
+	return buf, client.read(fn_rdprgnum, &buf)
+}
+
+func (client *NativeClient) speed(speed_type int32) (float64, int16) {
+	elements, ret := client.readElements(fn_rdspeed, speed_type)
+	if ret != EW_OK {
+		return 0, ret
+	}
+	if len(elements) == 0 {
+		return 0, EW_PROTOCOL
+	}
+	return elements[0].Value(), EW_OK
+}
+
+// Path functions
+func (client *NativeClient) GetMaxPath() (int16, int16) {
+	var buf [2]int16
+	ret := client.read(fn_getpath, &buf)
+	return buf[1], ret
+}
+
+func (client *NativeClient) SetPath(path int16) int16 {
+	if client.focas == nil {
+		return EW_HANDLE
+	}
+	_, ret := client.focas.Call(focas_class_cnc, fn_setpath, int32(path))
+	if ret == EW_OK {
+		client.focas.path = uint16(path)
+	}
+	return ret
+}
+
+// Name functions
+func (client *NativeClient) GetAxisNames() ([]string, int16) {
+	var result []string
+	if client.focas == nil {
+		return result, EW_HANDLE
+	}
+	data, ret := client.focas.Call(focas_class_cnc, fn_rdaxisname, native_max_axis)
+	if ret != EW_OK {
+		return result, ret
+	}
+	buf := make([]NativeODBAXISNAME, len(data)/binary.Size(NativeODBAXISNAME{}))
+	if ret := DecodeNative(data, buf); ret != EW_OK {
+		return result, ret
+	}
+	for _, axis := range buf {
+		if name := FormatAxisName(axis.Name, axis.Suff); name != "" {
+			result = append(result, name)
+		}
+	}
+	return result, EW_OK
+}
+
+func (client *NativeClient) GetSpindleNames() ([]string, int16) {
+	var result []string
+	if client.focas == nil {
+		return result, EW_HANDLE
+	}
+	data, ret := client.focas.Call(focas_class_cnc, fn_rdspdlname, native_max_spindles)
+	if ret != EW_OK {
+		return result, ret
+	}
+	buf := make([]NativeODBSPDLNAME, len(data)/binary.Size(NativeODBSPDLNAME{}))
+	if ret := DecodeNative(data, buf); ret != EW_OK {
+		return result, ret
+	}
+	for _, spindle := range buf {
+		if name := FormatAxisName(spindle.Name, spindle.Suff1); name != "" {
+			result = append(result, name)
+		}
+	}
+	return result, EW_OK
+}
+
+// Read plan functions
+func (client *NativeClient) GetStatInfo() (CncStatInfo, int16) {
+	buf, ret := client.statInfo()
+	if ret != EW_OK {
+		return CncStatInfo{}, ret
+	}
+	return CncStatInfo{
+		Aut:       buf.Aut,
+		Run:       buf.Run,
+		Edit:      buf.Edit,
+		Mstb:      buf.Mstb,
+		Motion:    buf.Motion,
+		Emergency: buf.Emergency,
+		Alarm:     buf.Alarm,
+	}, EW_OK
+}
+
+func (client *NativeClient) GetExecProgram() (string, int16) {
+	return client.execProgram()
+}
+
+func (client *NativeClient) GetExecBlocks() (CncExecBlocks, int16) {
+	return client.execBlocks()
+}
+
+func (client *NativeClient) GetBlockCount() (int64, int16) {
+	var count int32
+	ret := client.read(fn_rdblkcount, &count)
+	return int64(count), ret
+}
+
+func (client *NativeClient) GetTimerParams(numbers []int32) (map[int32]int64, int16) {
+	return client.readParams(numbers)
+}
+
+// Mode functions
+func (client *NativeClient) GetAut() (int16, int16) {
+	buf, ret := client.statInfo()
+	return buf.Aut, ret
+}
+
+func (client *NativeClient) GetRun() (int16, int16) {
+	buf, ret := client.statInfo()
+	return buf.Run, ret
+}
+
+func (client *NativeClient) GetEdit() (int16, int16) {
+	buf, ret := client.statInfo()
+	return buf.Edit, ret
+}
+
+func (client *NativeClient) GetMstb() (int16, int16) {
+	buf, ret := client.statInfo()
+	return buf.Mstb, ret
+}
+
+func (client *NativeClient) GetMotion() (int16, int16) {
+	buf, ret := client.statInfo()
+	return buf.Motion, ret
+}
+
+func (client *NativeClient) GetG00() (int16, int16) {
+	var buf NativeODBMDL
+	ret := client.read(fn_modal, &buf, 0, 0)
+	if ret != EW_OK {
+		return 0, ret
+	}
+	if buf.GData == 0 {
+		return 1, EW_OK
+	}
+	return 0, EW_OK
+}
+
+func (client *NativeClient) GetGCodes() ([]CncGCode, int16) {
+	result := make([]CncGCode, 0)
+	if client.focas == nil {
+		return result, EW_HANDLE
+	}
+	data, ret := client.focas.Call(focas_class_cnc, fn_rdgcode, -1, 1, max_modal_groups)
+	if ret != EW_OK {
+		return result, ret
+	}
+	buf := make([]NativeODBGCD, len(data)/binary.Size(NativeODBGCD{}))
+	if ret := DecodeNative(data, buf); ret != EW_OK {
+		return result, ret
+	}
+	for _, g_code := range buf {
+		code := NativeString(g_code.Code[:])
+		if code == "" {
+			continue
+		}
+		result = append(result, CncGCode{Group: g_code.Group + 1, Code: code})
+	}
+	return result, EW_OK
+}
+
+// all codes in one packet
+func (client *NativeClient) GetModalCodes() (map[string]int32, int16) {
+	result := make(map[string]int32)
+	if client.focas == nil {
+		return result, EW_HANDLE
+	}
+	names := slices.Sorted(maps.Keys(modal_code_types))
+	requests := make([]FocasRequest, 0, len(names))
+	for _, name := range names {
+		request := FocasRequest{Class: focas_class_cnc, Path: client.focas.path, Function: fn_modal}
+		request.Args[0] = int32(modal_code_types[name])
+		requests = append(requests, request)
+	}
+	responses, ret := client.focas.Exchange(requests)
+	if ret != EW_OK {
+		return result, ret
+	}
+	for index, response := range responses {
+		if response.Error != EW_OK {
+			return make(map[string]int32), response.Error
+		}
+		var buf NativeODBMDLAUX
+		if ret := DecodeNative(response.Data, &buf); ret != EW_OK {
+			return make(map[string]int32), ret
+		}
+		result[names[index]] = buf.AuxData
+	}
+	return result, EW_OK
+}
+
+func (client *NativeClient) GetShutdowns() (int16, int16) {
+	program, ret := client.execProgram()
+	if ret != EW_OK {
+		return 0, ret
+	}
+	return ParseShutdowns(program), EW_OK
+}
+
+func (client *NativeClient) GetLoadExcess() (int16, int16) {
+	servo_elements, ret := client.readElements(fn_rdsvmeter, native_max_axis)
+	if ret != EW_OK {
+		return 0, ret
+	}
+	spindle_elements, ret := client.readElements(fn_rdspmeter, 0, native_max_spindles)
+	if ret != EW_OK {
+		return 0, ret
+	}
+	var servo_loads []float64
+	for _, element := range servo_elements {
+		servo_loads = append(servo_loads, element.Value())
+	}
+	var spindle_loads []float64
+	for _, element := range spindle_elements {
+		spindle_loads = append(spindle_loads, element.Value())
+	}
+	return GetLoadExcessState(servo_loads, spindle_loads), EW_OK
+}
+
+// Program functions
+func (client *NativeClient) GetMainProgNum() (int32, int16) {
+	buf, ret := client.programNumbers()
+	return buf.Mdata, ret
+}
+
+func (client *NativeClient) GetSubProgNum() (int32, int16) {
+	buf, ret := client.programNumbers()
+	return buf.Data, ret
+}
+
+func (client *NativeClient) GetProgramPath() (string, int16) {
+	if client.focas == nil {
+		return "", EW_HANDLE
+	}
+	data, ret := client.focas.Call(focas_class_cnc, fn_exeprgname2)
+	if ret != EW_OK {
+		return "", ret
+	}
+	return NativeString(data), EW_OK
+}
+
+func (client *NativeClient) GetProgramDirectory(folder string) ([]CncProgram, int16) {
+	result := make([]CncProgram, 0)
+	if client.focas == nil {
+		return result, EW_HANDLE
+	}
+	function := fn_rdprogdir3
+	if folder != "" {
+		function = fn_rdpdf_alldir
+	}
+	for {
+		request := FocasRequest{Class: focas_class_cnc, Path: client.focas.path, Function: function, Extra: []byte(folder)}
+		request.Args[0] = 2
+		request.Args[1] = int32(len(result))
+		request.Args[2] = program_dir_batch
+		responses, ret := client.focas.Exchange([]FocasRequest{request})
+		if ret != EW_OK {
+			return make([]CncProgram, 0), ret
+		}
+		if responses[0].Error != EW_OK {
+			return make([]CncProgram, 0), responses[0].Error
+		}
+		data := responses[0].Data
+		buf := make([]NativePRGDIR, len(data)/binary.Size(NativePRGDIR{}))
+		if ret := DecodeNative(data, buf); ret != EW_OK {
+			return make([]CncProgram, 0), ret
+		}
+		for _, entry := range buf {
+			result = append(result, CncProgram{
+				Number:   entry.Number,
+				Name:     NativeString(entry.Name[:]),
+				Comment:  NativeString(entry.Comment[:]),
+				Size:     entry.Length,
+				Modified: FormatProgramDate(entry.Year, entry.Month, entry.Day, entry.Hour, entry.Minute),
+			})
+		}
+		if len(buf) < program_dir_batch {
+			return result, EW_OK
+		}
+	}
+}
+
+func (client *NativeClient) UploadProgram(path string) ([]byte, int16) {
+	if client.focas == nil {
+		return nil, EW_HANDLE
+	}
+	request := FocasRequest{Class: focas_class_cnc, Path: client.focas.path, Function: fn_upload4, Extra: []byte(path)}
+	responses, ret := client.focas.Exchange([]FocasRequest{request})
+	if ret != EW_OK {
+		return nil, ret
+	}
+	if responses[0].Error != EW_OK {
+		return nil, responses[0].Error
+	}
+	return responses[0].Data, EW_OK
+}
+
+func (client *NativeClient) GetFrameNumber() (int64, int16) {
+	var buf NativeODBSEQ
+	ret := client.read(fn_rdseqnum, &buf)
+	return int64(buf.Data), ret
+}
+
+func (client *NativeClient) GetFrame() (string, int16) {
+	program, ret := client.execProgram()
+	if ret != EW_OK {
+		return "", ret
+	}
+	frame_number, frame_number_error := client.GetFrameNumber()
+	return ParseFrame(program, frame_number, frame_number_error), EW_OK
+}
+
+func (client *NativeClient) GetPartsCount() (int64, int16) {
+	return client.readParam(6711)
+}
+
+func (client *NativeClient) GetToolNumber() (int64, int16) {
+	var buf NativeODBTLIFE4
+	ret := client.read(fn_toolnum, &buf, 0, 0)
+	return int64(buf.Data), ret
+}
+
+// Tool offset functions
+func (client *NativeClient) GetToolOffsetInfo() (CncToolOffsetInfo, int16) {
+	sys_info, ret := client.sysInfo()
+	if ret != EW_OK {
+		return CncToolOffsetInfo{}, ret
+	}
+	var buf NativeODBTLINF
+	ret = client.read(fn_rdtofsinfo, &buf)
+	if ret != EW_OK {
+		return CncToolOffsetInfo{}, ret
+	}
+	return CncToolOffsetInfo{MtType: string(sys_info.MtType[:]), Type: buf.OfsType, Count: buf.UseNo}, EW_OK
+}
+
+func (client *NativeClient) GetToolOffsets(offset_type int16, start int16, end int16) ([]int32, int16) {
+	result := make([]int32, end-start+1)
+	return result, client.read(fn_rdtofsr, result, int32(start), int32(offset_type), int32(end))
+}
+
+// Tool life functions
+func (client *NativeClient) GetToolLifeInfo() (CncToolLifeInfo, int16) {
+	var info NativeODBTLINFO
+	ret := client.read(fn_rdtlinfo, &info)
+	if ret != EW_OK {
+		return CncToolLifeInfo{}, ret
+	}
+	var use_group NativeODBUSEGRP
+	ret = client.read(fn_rdtlusegrp, &use_group)
+	if ret != EW_OK {
+		return CncToolLifeInfo{}, ret
+	}
+	return CncToolLifeInfo{MaxGroups: info.MaxGroup, MaxTools: info.MaxTool, UseGroup: use_group.Use, NextGroup: use_group.Next}, EW_OK
+}
+
+func (client *NativeClient) GetToolLifeGroups(start int32, count int16) ([]CncToolLifeGroup, int16) {
+	buf := make([]NativeIODBTLGRP, count)
+	ret := client.read(fn_rdtlgrp, buf, start, int32(count))
+	if ret != EW_OK {
+		return nil, ret
+	}
+	result := make([]CncToolLifeGroup, 0, count)
+	for index, group := range buf {
+		result = append(result, CncToolLifeGroup{
+			Group:      start + int32(index),
+			ToolCount:  group.Ntool,
+			Life:       group.Life,
+			Count:      group.Count,
+			UseTool:    group.UseTool,
+			RestSignal: group.RestSig != 0,
+			CountType:  group.CountType,
+		})
+	}
+	return result, EW_OK
+}
+
+func (client *NativeClient) GetToolLifeTools(group int32, count int16) ([]CncToolLifeTool, int16) {
+	buf := make([]NativeIODBTLTOOL, count)
+	ret := client.read(fn_rdtltool, buf, group, 1, int32(count))
+	if ret != EW_OK {
+		return nil, ret
+	}
+	result := make([]CncToolLifeTool, 0, count)
+	for _, tool := range buf {
+		result = append(result, CncToolLifeTool{Number: tool.ToolNum, Info: tool.ToolInf})
+	}
+	return result, EW_OK
+}
+
+// Work offset functions
+func (client *NativeClient) GetWorkOffsetCount() (int16, int16) {
+	var count int16
+	return count, client.read(fn_rdzofsinfo, &count)
+}
+
+func (client *NativeClient) GetWorkOffsets(start int16, end int16, axes int16) ([][]int32, int16) {
+	data := make([]int32, int(end-start+1)*int(axes))
+	ret := client.read(fn_rdzofsr, data, int32(start), -1, int32(end))
+	if ret != EW_OK {
+		return nil, ret
+	}
+	result := make([][]int32, 0, end-start+1)
+	for offset := range int(end - start + 1) {
+		result = append(result, data[offset*int(axes):(offset+1)*int(axes)])
+	}
+	return result, EW_OK
+}
+
+func (client *NativeClient) GetWorkShift(axes int16) ([]int32, int16) {
+	result := make([]int32, axes)
+	return result, client.read(fn_rdwkcdshft, result, -1)
+}
+
+// Axis functions
+func (client *NativeClient) GetAbsolutePositions() (map[string]float64, int16) {
+	return client.readElementsMap(fn_rdposition, 0, native_max_axis)
+}
+
+func (client *NativeClient) GetRelativePositions() (map[string]float64, int16) {
+	return client.readElementsMap(fn_rdposition, 2, native_max_axis)
+}
+
+func (client *NativeClient) GetMachinePositions() (map[string]float64, int16) {
+	return client.readElementsMap(fn_rdposition, 1, native_max_axis)
+}
+
+func (client *NativeClient) GetDistanceToGo() (map[string]float64, int16) {
+	return client.readElementsMap(fn_rdposition, 3, native_max_axis)
+}
+
+// absolute, machine, relative positions and distance to go of every axis
+func (client *NativeClient) GetCommandedPositions() (map[string]float64, int16) {
+	result := make(map[string]float64)
+	elements, ret := client.readElements(fn_rdposition, -1, native_max_axis)
+	if ret != EW_OK {
+		return result, ret
+	}
+	for index := 0; index+3 < len(elements); index += 4 {
+		name := NativeString(elements[index].Name[:])
+		if name == "" {
+			continue
+		}
+		result[name] = elements[index].Value() + elements[index+3].Value()
+	}
+	return result, EW_OK
+}
+
+func (client *NativeClient) GetFeedRate() (float64, int16) {
+	return client.speed(0)
+}
+
+func (client *NativeClient) GetFeedRateParam1() (map[string]float64, int16) {
+	return client.readElementsMap(fn_rdaxisdata, 5, 0, native_max_axis)
+}
+
+func (client *NativeClient) GetFeedRateParam2() (map[string]float64, int16) {
+	return client.readElementsMap(fn_rdaxisdata, 5, 5, native_max_axis)
+}
+
+func (client *NativeClient) GetFeedOverride() (int16, int16) {
+	buf, ret := client.panelSignals(0x20)
+	return buf.FeedOvrd, ret
+}
+
+func (client *NativeClient) GetJogOverride() (int16, int16) {
+	buf, ret := client.panelSignals(0x20)
+	return buf.JogOvrd, ret
+}
+
+func (client *NativeClient) GetJogSpeed() (map[string]float64, int16) {
+	result := make(map[string]float64)
+	elements, ret := client.readElements(fn_rdaxisdata, 5, 2, native_max_axis)
+	if ret != EW_OK {
+		return result, ret
+	}
+	for _, element := range elements {
+		name := NativeString(element.Name[:])
+		if name == "" || (element.Flag>>1)&1 != 0 {
+			continue
+		}
+		result[name] = element.Value()
+	}
+	return result, EW_OK
+}
+
+func (client *NativeClient) GetServoLoad() (map[string]int64, int16) {
+	return client.readElementsIntMap(fn_rdsvmeter, native_max_axis)
+}
+
+func (client *NativeClient) GetServoCurrentLoad() (map[string]float64, int16) {
+	return client.readElementsMap(fn_rdaxisdata, 2, 2, native_max_axis)
+}
+
+func (client *NativeClient) GetServoCurrentLoadPercent() (map[string]int64, int16) {
+	return client.readElementsIntMap(fn_rdaxisdata, 2, 1, native_max_axis)
+}
+
+// Spindle functions
+func (client *NativeClient) GetSpindleSpeed() (float64, int16) {
+	return client.speed(1)
+}
+
+func (client *NativeClient) GetSpindleSpeedParam() (map[string]int64, int16) {
+	return client.readElementsIntMap(fn_rdaxisdata, 3, 2, native_max_spindles)
+}
+
+func (client *NativeClient) GetSpindleMotorSpeed() (map[string]int64, int16) {
+	return client.readElementsIntMap(fn_rdspmeter, 1, native_max_spindles)
+}
+
+func (client *NativeClient) GetSpindleLoad() (map[string]int64, int16) {
+	return client.readElementsIntMap(fn_rdspmeter, 0, native_max_spindles)
+}
+
+func (client *NativeClient) GetSpindleAlarms() ([]int16, int16) {
+	buf := make([]uint8, native_max_spindles)
+	ret := client.read(fn_rdspdlalm, buf)
+	if ret != EW_OK {
+		return nil, ret
+	}
+	result := make([]int16, 0, len(buf))
+	for _, alarm := range buf {
+		result = append(result, int16(alarm))
+	}
+	return result, EW_OK
+}
+
+// only 15i function
+func (client *NativeClient) GetSpindleOverride() (int16, int16) {
+	buf, ret := client.panelSignals(0x40)
+	return buf.SpdlOvrd, ret
+}
+
+// Alarm functions
+func (client *NativeClient) GetEmergency() (int16, int16) {
+	buf, ret := client.statInfo()
+	return buf.Emergency, ret
+}
+
+func (client *NativeClient) GetAlarm() (int16, int16) {
+	buf, ret := client.statInfo()
+	return buf.Alarm, ret
+}
+
+func (client *NativeClient) GetAlarmMessages() ([]CncAlarmMessage, int16) {
+	result := make([]CncAlarmMessage, 0)
+	if client.focas == nil {
+		return result, EW_HANDLE
+	}
+	data, ret := client.focas.Call(focas_class_cnc, fn_rdalmmsg2, -1, max_alarm_messages)
+	if ret != EW_OK {
+		return result, ret
+	}
+	buf := make([]NativeODBALMMSG2, len(data)/binary.Size(NativeODBALMMSG2{}))
+	if ret := DecodeNative(data, buf); ret != EW_OK {
+		return result, ret
+	}
+	for _, alarm := range buf {
+		length := min(int(alarm.MsgLen), len(alarm.AlmMsg))
+		message := NativeString(alarm.AlmMsg[:length])
+		result = append(result, NewAlarmMessage(alarm.AlmNo, alarm.Type, alarm.Axis, message))
+	}
+	return result, EW_OK
+}
+
+func (client *NativeClient) GetAlarmHistoryCount() (int32, int16) {
+	var buf uint16
+	ret := client.read(fn_rdalmhisno, &buf)
+	return int32(buf), ret
+}
+
+func (client *NativeClient) GetAlarmHistory(start int32, end int32) ([]CncAlarmHistoryEntry, int16) {
+	result := make([]CncAlarmHistoryEntry, 0)
+	if client.focas == nil {
+		return result, EW_HANDLE
+	}
+	data, ret := client.focas.Call(focas_class_cnc, fn_rdalmhistry, start, end)
+	if ret != EW_OK {
+		return result, ret
+	}
+	buf := make([]NativeODBAHISEntry, len(data)/binary.Size(NativeODBAHISEntry{}))
+	if ret := DecodeNative(data, buf); ret != EW_OK {
+		return result, ret
+	}
+	for _, alarm := range buf {
+		length := min(int(alarm.LenMsg), len(alarm.AlmMsg))
+		result = append(result, NewAlarmHistoryEntry(
+			int(alarm.Year), int(alarm.Month), int(alarm.Day),
+			int(alarm.Hour), int(alarm.Minute), int(alarm.Second),
+			int32(alarm.AlmNo), alarm.AlmGrp, int16(alarm.AxisNo), NativeString(alarm.AlmMsg[:length])))
+	}
+	return result, EW_OK
+}
+
+func (client *NativeClient) GetOperatorMessages() ([]CncOperatorMessage, int16) {
+	result := make([]CncOperatorMessage, 0)
+	if client.focas == nil {
+		return result, EW_HANDLE
+	}
+	data, ret := client.focas.Call(focas_class_cnc, fn_rdopmsg3, -1, max_operator_messages)
+	if ret != EW_OK {
+		return result, ret
+	}
+	buf := make([]NativeOPMSG3, len(data)/binary.Size(NativeOPMSG3{}))
+	if ret := DecodeNative(data, buf); ret != EW_OK {
+		return result, ret
+	}
+	for _, message := range buf {
+		if message.Datano == -1 {
+			continue
+		}
+		count := min(int(message.CharNum), len(message.Data))
+		result = append(result, CncOperatorMessage{Number: message.Datano, Type: message.Type, Data: message.Data[:count]})
+	}
+	return result, EW_OK
+}
+
+// Operating functions
+func (client *NativeClient) GetPowerOnTime() (int64, int16) {
+	return client.readParam(6750)
+}
+
+func (client *NativeClient) GetOperationTime() (float64, int16) {
+	return client.readTimeParams(6751, 6752)
+}
+
+func (client *NativeClient) GetCuttingTime() (float64, int16) {
+	return client.readTimeParams(6753, 6754)
+}
+
+func (client *NativeClient) GetCycleTime() (float64, int16) {
+	return client.readTimeParams(6757, 6758)
+}
+
+func (client *NativeClient) GetTimer(timer_type int16) (CncTimer, int16) {
+	var buf NativeIODBTIME
+	ret := client.read(fn_rdtimer, &buf, int32(timer_type))
+	return CncTimer{Minute: buf.Minute, Msec: buf.Msec}, ret
+}
+
+func (client *NativeClient) GetSeriesNumber() (string, int16) {
+	buf, ret := client.sysInfo()
+	if ret != EW_OK {
+		return "", ret
+	}
+	return NativeString(buf.Series[:]), EW_OK
+}
+
+func (client *NativeClient) GetVersionNumber() (string, int16) {
+	buf, ret := client.sysInfo()
+	if ret != EW_OK {
+		return "", ret
+	}
+	return NativeString(buf.Version[:]), EW_OK
+}
+
+func (client *NativeClient) GetCtrlAxesNumber() (int16, int16) {
+	buf, ret := client.sysInfoEx()
+	return buf.CtrlAxis, ret
+}
+
+func (client *NativeClient) GetCtrlSpindlesNumber() (int16, int16) {
+	buf, ret := client.sysInfoEx()
+	return buf.CtrlSpdl, ret
+}
+
+func (client *NativeClient) GetCtrlPathsNumber() (int16, int16) {
+	buf, ret := client.sysInfoEx()
+	return buf.CtrlPath, ret
+}
+
+func (client *NativeClient) GetSerialNumber() (int64, int16) {
+	return client.readParam(13151)
+}
+
+func (client *NativeClient) GetCncId() (string, int16) {
+	var cnc_ids [4]uint32
+	ret := client.read(fn_rdcncid, &cnc_ids)
+	if ret != EW_OK {
+		return "", ret
+	}
+	return FormatCncId(cnc_ids), EW_OK
+}
+
+// PMC functions
+func (client *NativeClient) ReadPmcRange(area int16, start uint16, end uint16) ([]byte, int16) {
+	if client.focas == nil {
+		return nil, EW_HANDLE
+	}
+	data, ret := client.focas.Call(focas_class_pmc, fn_pmc_rdpmcrng, int32(start), int32(end), int32(area), 0)
+	if ret != EW_OK {
+		return nil, ret
+	}
+	count := int(end) - int(start) + 1
+	if len(data) < count {
+		return nil, EW_PROTOCOL
+	}
+	return data[:count], EW_OK
+}
+
+// Macro functions
+func (client *NativeClient) ReadMacroRange(start int32, end int32) ([]CncMacroValue, int16) {
+	values := make([]CncMacroValue, 0, end-start+1)
+	for batch_start := start; batch_start <= end; batch_start += macro_batch {
+		batch_end := min(batch_start+macro_batch-1, end)
+		data := make([]NativeIODBMR, batch_end-batch_start+1)
+		ret := client.read(fn_rdmacror, data, batch_start, batch_end)
+		if ret != EW_OK {
+			return nil, ret
+		}
+		for _, item := range data {
+			values = append(values, CncMacroValue{Mantissa: item.McrVal, Exponent: item.DecVal})
+		}
+	}
+	return values, EW_OK
+}
+
+// Parameter and diagnosis functions
+func (client *NativeClient) ReadParameter(number int32, axis int16, size int16) (CncDataValue, int16) {
+	var buf NativeIODBPSD
+	ret := client.read(fn_rdparam, &buf, number, int32(axis), int32(size))
+	return CncDataValue{Value: buf.Value, Decimal: buf.Dec}, ret
+}
+
+func (client *NativeClient) ReadDiagnosis(number int32, axis int16, size int16) (CncDataValue, int16) {
+	var buf NativeIODBPSD
+	ret := client.read(fn_diagnoss, &buf, number, int32(axis), int32(size))
+	return CncDataValue{Value: buf.Value, Decimal: buf.Dec}, ret
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"math"
+	"net"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	logger = log.New(io.Discard, "", 0)
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func StartTestFocasServer(t *testing.T, state *FocasState) *NativeClient {
+	t.Helper()
+	return ConnectTestFocasServer(t, state, NewSimulatorClient().(*NativeClient))
+}
+
+func ConnectTestFocasServer(t *testing.T, state *FocasState, client *NativeClient) *NativeClient {
+	t.Helper()
+	focas_server, err := StartFocasServer("127.0.0.1:0", state.HandleRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(focas_server.Close)
+	if ret := client.Connect("127.0.0.1", focas_server.Addr().Port, 2); ret != EW_OK {
+		t.Fatalf("Connect: %d", ret)
+	}
+	t.Cleanup(func() { client.Free() })
+	return client
+}
+
+func NewTestFocasState() *FocasState {
+	state := NewFocasState()
+	state.Aut, state.Run, state.Edit, state.Motion, state.Mstb = 1, 3, 0, 1, 0
+	state.Emergency, state.Alarm = 0, 1
+	state.Feedrate, state.SpindleSpeed = 1250, 3200
+	state.Axes = []FocasAxisState{
+		{Name: "X", Absolute: 12.345, Machine: -100.5, Relative: 1.001, DistanceToGo: 0.25,
+			CurrentLoad: 4.5, CurrentLoadPercent: 37, FeedPrg: 500, FeedNote: 450},
+		{Name: "Z", Absolute: -7.5, Machine: -20, Relative: 2, DistanceToGo: -1.5,
+			CurrentLoad: 1.2, CurrentLoadPercent: 12, FeedPrg: 600, FeedNote: 550},
+	}
+	state.Spindles = []FocasSpindleState{{Name: "S1", Speed: 3150}}
+	state.Parameters = map[int32]int32{6711: 42, 1320: -999}
+	return state
+}
+
+func TestFocasPacketHeader(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteFocasPacket(&buf, focas_open_request, EncodeNative(uint16(2))); err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{0xA0, 0xA0, 0xA0, 0xA0, 0x00, 0x01, 0x01, 0x01, 0x00, 0x02, 0x00, 0x02}
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Fatalf("open request % X, expected % X", buf.Bytes(), expected)
+	}
+}
+
+func TestNativeStatInfo(t *testing.T) {
+	client := StartTestFocasServer(t, NewTestFocasState())
+	stat_info, ret := client.GetStatInfo()
+	if ret != EW_OK {
+		t.Fatalf("GetStatInfo: %d", ret)
+	}
+	expected := CncStatInfo{Aut: 1, Run: 3, Edit: 0, Mstb: 0, Motion: 1, Emergency: 0, Alarm: 1}
+	if stat_info != expected {
+		t.Fatalf("GetStatInfo: %+v, expected %+v", stat_info, expected)
+	}
+}
+
+func TestNativePositions(t *testing.T) {
+	client := StartTestFocasServer(t, NewTestFocasState())
+	reads := map[string]func() (map[string]float64, int16){
+		"absolute":  client.GetAbsolutePositions,
+		"machine":   client.GetMachinePositions,
+		"relative":  client.GetRelativePositions,
+		"distance":  client.GetDistanceToGo,
+		"commanded": client.GetCommandedPositions,
+	}
+	expected := map[string]map[string]float64{
+		"absolute":  {"X": 12.345, "Z": -7.5},
+		"machine":   {"X": -100.5, "Z": -20},
+		"relative":  {"X": 1.001, "Z": 2},
+		"distance":  {"X": 0.25, "Z": -1.5},
+		"commanded": {"X": 12.595, "Z": -9},
+	}
+	for name, read := range reads {
+		positions, ret := read()
+		if ret != EW_OK {
+			t.Fatalf("%s: %d", name, ret)
+		}
+		AssertFloatMap(t, name, positions, expected[name])
+	}
+}
+
+func TestNativeAxisData(t *testing.T) {
+	client := StartTestFocasServer(t, NewTestFocasState())
+	current_load, ret := client.GetServoCurrentLoad()
+	if ret != EW_OK {
+		t.Fatalf("GetServoCurrentLoad: %d", ret)
+	}
+	AssertFloatMap(t, "current_load", current_load, map[string]float64{"X": 4.5, "Z": 1.2})
+	feed_prg, ret := client.GetFeedRateParam1()
+	if ret != EW_OK {
+		t.Fatalf("GetFeedRateParam1: %d", ret)
+	}
+	AssertFloatMap(t, "feedrate_prg", feed_prg, map[string]float64{"X": 500, "Z": 600})
+	load_percent, ret := client.GetServoCurrentLoadPercent()
+	if ret != EW_OK {
+		t.Fatalf("GetServoCurrentLoadPercent: %d", ret)
+	}
+	if load_percent["X"] != 37 || load_percent["Z"] != 12 || len(load_percent) != 2 {
+		t.Fatalf("current_load_percent: %v", load_percent)
+	}
+	spindle_speed, ret := client.GetSpindleSpeedParam()
+	if ret != EW_OK {
+		t.Fatalf("GetSpindleSpeedParam: %d", ret)
+	}
+	if spindle_speed["S1"] != 3150 || len(spindle_speed) != 1 {
+		t.Fatalf("spindle_param_speed: %v", spindle_speed)
+	}
+}
+
+func TestNativeSpeed(t *testing.T) {
+	client := StartTestFocasServer(t, NewTestFocasState())
+	feedrate, ret := client.GetFeedRate()
+	if ret != EW_OK || feedrate != 1250 {
+		t.Fatalf("GetFeedRate: %v, %d", feedrate, ret)
+	}
+	spindle_speed, ret := client.GetSpindleSpeed()
+	if ret != EW_OK || spindle_speed != 3200 {
+		t.Fatalf("GetSpindleSpeed: %v, %d", spindle_speed, ret)
+	}
+}
+
+func TestNativeParameters(t *testing.T) {
+	client := StartTestFocasServer(t, NewTestFocasState())
+	parts_count, ret := client.GetPartsCount()
+	if ret != EW_OK || parts_count != 42 {
+		t.Fatalf("GetPartsCount: %v, %d", parts_count, ret)
+	}
+	value, ret := client.ReadParameter(1320, 0, 4)
+	if ret != EW_OK || value.Value != -999 {
+		t.Fatalf("ReadParameter: %+v, %d", value, ret)
+	}
+	if _, ret = client.ReadParameter(9999, 0, 4); ret != EW_NUMBER {
+		t.Fatalf("ReadParameter of absent number: %d, expected %d", ret, EW_NUMBER)
+	}
+	params, ret := client.readParams([]int32{6711, 1320})
+	if ret != EW_OK || params[6711] != 42 || params[1320] != -999 {
+		t.Fatalf("readParams: %v, %d", params, ret)
+	}
+}
+
+func TestNativeSysInfo(t *testing.T) {
+	client := StartTestFocasServer(t, NewTestFocasState())
+	series, ret := client.GetSeriesNumber()
+	if ret != EW_OK || series != "D4F1" {
+		t.Fatalf("GetSeriesNumber: %q, %d", series, ret)
+	}
+	version, ret := client.GetVersionNumber()
+	if ret != EW_OK || version != "40.0" {
+		t.Fatalf("GetVersionNumber: %q, %d", version, ret)
+	}
+	axes, ret := client.GetCtrlAxesNumber()
+	if ret != EW_OK || axes != 2 {
+		t.Fatalf("GetCtrlAxesNumber: %d, %d", axes, ret)
+	}
+	cnc_id, ret := client.GetCncId()
+	if ret != EW_OK || cnc_id != "12345678-9ABCDEF0-0F1E2D3C-4B5A6978" {
+		t.Fatalf("GetCncId: %q, %d", cnc_id, ret)
+	}
+}
+
+func TestNativeFree(t *testing.T) {
+	client := StartTestFocasServer(t, NewTestFocasState())
+	if ret := client.Free(); ret != EW_OK {
+		t.Fatalf("Free: %d", ret)
+	}
+	if _, ret := client.GetStatInfo(); ret != EW_HANDLE {
+		t.Fatalf("GetStatInfo after Free: %d, expected %d", ret, EW_HANDLE)
+	}
+}
+
+func TestNativeUncheckedFunctions(t *testing.T) {
+	client := ConnectTestFocasServer(t, NewTestFocasState(), NewNativeClient().(*NativeClient))
+	if _, ret := client.GetCncId(); ret != EW_FUNC {
+		t.Fatalf("GetCncId of an unchecked function: %d, expected %d", ret, EW_FUNC)
+	}
+	if _, ret := client.GetToolNumber(); ret != EW_FUNC {
+		t.Fatalf("GetToolNumber of an unchecked function: %d, expected %d", ret, EW_FUNC)
+	}
+	// checked functions are sent
+	if _, ret := client.GetStatInfo(); ret != EW_OK {
+		t.Fatalf("GetStatInfo: %d", ret)
+	}
+}
+
+// listener answering the open request with the given bytes
+func StartTestHandshake(t *testing.T, response []byte) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if _, _, err := ReadFocasPacket(conn); err != nil {
+			return
+		}
+		if response == nil {
+			return
+		}
+		conn.Write(response)
+		io.Copy(io.Discard, conn)
+	}()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestNativeHandshakeFailure(t *testing.T) {
+	var open_error bytes.Buffer
+	WriteFocasPacket(&open_error, focas_open_response, EncodeNative(EW_BUSY))
+	var wrong_type bytes.Buffer
+	WriteFocasPacket(&wrong_type, focas_data_response, EncodeNative(EW_OK))
+	cases := map[string]struct {
+		response []byte
+		expected int16
+	}{
+		"open error":  {open_error.Bytes(), EW_BUSY},
+		"wrong type":  {wrong_type.Bytes(), EW_PROTOCOL},
+		"wrong magic": {[]byte{0x00, 0x01, 0x02, 0x03, 0x00, 0x01, 0x01, 0x02, 0x00, 0x02, 0x00, 0x00}, EW_PROTOCOL},
+		"closed":      {nil, EW_SOCKET},
+	}
+	for name, test_case := range cases {
+		t.Run(name, func(t *testing.T) {
+			port := StartTestHandshake(t, test_case.response)
+			client := NewNativeClient()
+			if ret := client.Connect("127.0.0.1", port, 2); ret != test_case.expected {
+				t.Fatalf("Connect: %d, expected %d", ret, test_case.expected)
+			}
+			if ret := client.Free(); ret != EW_HANDLE {
+				t.Fatalf("Free after failed connect: %d, expected %d", ret, EW_HANDLE)
+			}
+		})
+	}
+}
+
+func TestNativeConnectRefused(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	if ret := NewNativeClient().Connect("127.0.0.1", port, 1); ret != EW_SOCKET {
+		t.Fatalf("Connect: %d, expected %d", ret, EW_SOCKET)
+	}
+}
+
+func AssertFloatMap(t *testing.T, name string, values map[string]float64, expected map[string]float64) {
+	t.Helper()
+	if len(values) != len(expected) {
+		t.Fatalf("%s: %v, expected %v", name, values, expected)
+	}
+	for key, value := range expected {
+		if diff := values[key] - value; diff > 1e-9 || diff < -1e-9 {
+			t.Fatalf("%s: %v, expected %v", name, values, expected)
+		}
+	}
+}
+
+func TestFocasStateBadArguments(t *testing.T) {
+	state := NewTestFocasState()
+	state.ToolLifeGroups = []FocasToolLifeGroupState{{Group: 1, Tools: []FocasToolLifeToolState{{Number: 101}}}}
+	state.Programs = []FocasProgramState{{Number: 1, Name: "O0001"}}
+	state.ToolOffsets = map[int16][]int32{1: {100, 200}}
+	cases := map[string]struct {
+		request  FocasRequest
+		expected int16
+	}{
+		"tool offset type":       {FocasRequest{Class: focas_class_cnc, Function: fn_rdtofsr, Args: [5]int32{1, -1, 1}}, EW_ATTRIB},
+		"tool offset number":     {FocasRequest{Class: focas_class_cnc, Function: fn_rdtofsr, Args: [5]int32{0, 0, 1}}, EW_NUMBER},
+		"tool life group count":  {FocasRequest{Class: focas_class_cnc, Function: fn_rdtlgrp, Args: [5]int32{1, -1}}, EW_LENGTH},
+		"tool life group start":  {FocasRequest{Class: focas_class_cnc, Function: fn_rdtlgrp, Args: [5]int32{-5, 1}}, EW_NUMBER},
+		"tool life tool count":   {FocasRequest{Class: focas_class_cnc, Function: fn_rdtltool, Args: [5]int32{1, 1, -1}}, EW_LENGTH},
+		"program start":          {FocasRequest{Class: focas_class_cnc, Function: fn_rdprogdir3, Args: [5]int32{2, -1, 10}}, EW_NUMBER},
+		"program count":          {FocasRequest{Class: focas_class_cnc, Function: fn_rdprogdir3, Args: [5]int32{2, 0, -1}}, EW_LENGTH},
+		"exec program length":    {FocasRequest{Class: focas_class_cnc, Function: fn_rdexecprog, Args: [5]int32{-1}}, EW_LENGTH},
+		"macro range overflow":   {FocasRequest{Class: focas_class_cnc, Function: fn_rdmacror, Args: [5]int32{math.MinInt32, math.MaxInt32}}, EW_LENGTH},
+		"pmc negative address":   {FocasRequest{Class: focas_class_pmc, Function: fn_pmc_rdpmcrng, Args: [5]int32{-10, 0, 5}}, EW_NUMBER},
+		"pmc range overflow":     {FocasRequest{Class: focas_class_pmc, Function: fn_pmc_rdpmcrng, Args: [5]int32{0, math.MaxInt32, 5}}, EW_NUMBER},
+		"tool offsets in range":  {FocasRequest{Class: focas_class_cnc, Function: fn_rdtofsr, Args: [5]int32{1, 1, 1}}, EW_OK},
+		"programs after the end": {FocasRequest{Class: focas_class_cnc, Function: fn_rdprogdir3, Args: [5]int32{2, 100, 10}}, EW_OK},
+	}
+	for name, test_case := range cases {
+		if response := state.HandleRequest(test_case.request); response.Error != test_case.expected {
+			t.Fatalf("%s: %d, expected %d", name, response.Error, test_case.expected)
+		}
+	}
+}
@@ -0,0 +1,551 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// FOCAS return codes
+const (
+	EW_PROTOCOL int16 = -17
+	EW_SOCKET   int16 = -16
+	EW_NODLL    int16 = -15
+	EW_HANDLE   int16 = -8
+	EW_BUSY     int16 = -1
+	EW_OK       int16 = 0
+	EW_FUNC     int16 = 1
+	EW_LENGTH   int16 = 2
+	EW_NUMBER   int16 = 3
+	EW_ATTRIB   int16 = 4
+	EW_DATA     int16 = 5
+	EW_NOOPT    int16 = 6
+	EW_PATH     int16 = 11
+)
+
+// FOCAS2 Ethernet packet types
+const (
+	focas_open_request   uint16 = 0x0101
+	focas_open_response  uint16 = 0x0102
+	focas_close_request  uint16 = 0x0201
+	focas_close_response uint16 = 0x0202
+	focas_data_request   uint16 = 0x2101
+	focas_data_response  uint16 = 0x2102
+)
+
+// FOCAS2 Ethernet request classes
+const (
+	focas_class_cnc uint16 = 0x0001
+	focas_class_pmc uint16 = 0x0002
+)
+
+// FOCAS2 Ethernet function codes of the PMC class
+const (
+	fn_pmc_rdpmcrng uint16 = 0x8001
+)
+
+// FOCAS2 Ethernet function codes
+const (
+	fn_rdparam      uint16 = 0x000e
+	fn_rdmacror     uint16 = 0x0015
+	fn_sysinfo      uint16 = 0x0018
+	fn_statinfo     uint16 = 0x0019
+	fn_rdprgnum     uint16 = 0x001c
+	fn_rdseqnum     uint16 = 0x001d
+	fn_modal        uint16 = 0x0020
+	fn_rdgcode      uint16 = 0x0021
+	fn_rdspeed      uint16 = 0x0024
+	fn_rdposition   uint16 = 0x0026
+	fn_rdexecprog   uint16 = 0x0029
+	fn_exeprgname2  uint16 = 0x002a
+	fn_rdtofsr      uint16 = 0x002b
+	fn_rdtofsinfo   uint16 = 0x002c
+	fn_rdtlinfo     uint16 = 0x002d
+	fn_rdtlusegrp   uint16 = 0x002e
+	fn_rdtlgrp      uint16 = 0x002f
+	fn_diagnoss     uint16 = 0x0030
+	fn_rdtltool     uint16 = 0x0031
+	fn_toolnum      uint16 = 0x0032
+	fn_rdzofsr      uint16 = 0x0033
+	fn_rdzofsinfo   uint16 = 0x0034
+	fn_rdalmmsg2    uint16 = 0x0035
+	fn_rdalmhisno   uint16 = 0x0036
+	fn_rdalmhistry  uint16 = 0x0037
+	fn_rdopmsg3     uint16 = 0x0038
+	fn_rdwkcdshft   uint16 = 0x0039
+	fn_rdblkcount   uint16 = 0x003a
+	fn_rdprogdir3   uint16 = 0x003b
+	fn_rdpdf_alldir uint16 = 0x003c
+	fn_upload4      uint16 = 0x003d
+	fn_rdopnlsgnl   uint16 = 0x0040
+	fn_rdspdlalm    uint16 = 0x0041
+	fn_rdtimer      uint16 = 0x0042
+	fn_rdsvmeter    uint16 = 0x0056
+	fn_rdspmeter    uint16 = 0x0057
+	fn_rdcncid      uint16 = 0x0090
+	fn_getpath      uint16 = 0x0091
+	fn_setpath      uint16 = 0x0092
+	fn_rdaxisname   uint16 = 0x0093
+	fn_rdspdlname   uint16 = 0x0094
+	fn_sysinfo_ex   uint16 = 0x0095
+	fn_rdaxisdata   uint16 = 0x0174
+)
+
+// function codes not checked against a real CNC, served only by the built-in simulator,
+// the native client returns EW_FUNC for them on other devices
+var focas_unchecked_functions = map[uint16]bool{
+	fn_exeprgname2:  true,
+	fn_rdtofsr:      true,
+	fn_rdtofsinfo:   true,
+	fn_rdtlinfo:     true,
+	fn_rdtlusegrp:   true,
+	fn_rdtlgrp:      true,
+	fn_rdtltool:     true,
+	fn_toolnum:      true,
+	fn_rdzofsr:      true,
+	fn_rdzofsinfo:   true,
+	fn_rdalmmsg2:    true,
+	fn_rdalmhisno:   true,
+	fn_rdalmhistry:  true,
+	fn_rdopmsg3:     true,
+	fn_rdwkcdshft:   true,
+	fn_rdblkcount:   true,
+	fn_rdprogdir3:   true,
+	fn_rdpdf_alldir: true,
+	fn_upload4:      true,
+	fn_rdopnlsgnl:   true,
+	fn_rdspdlalm:    true,
+	fn_rdtimer:      true,
+	fn_rdcncid:      true,
+	fn_getpath:      true,
+	fn_setpath:      true,
+	fn_rdaxisname:   true,
+	fn_rdspdlname:   true,
+	fn_sysinfo_ex:   true,
+}
+
+var focas_magic = [4]byte{0xA0, 0xA0, 0xA0, 0xA0}
+
+const focas_version uint16 = 0x0001
+const focas_header_size = 10
+const focas_request_block_size = 28
+const focas_response_block_size = 12
+
+var ErrFocasMagic = errors.New("некорректный заголовок пакета FOCAS")
+var ErrFocasBlock = errors.New("некорректный блок пакета FOCAS")
+
+type FocasRequest struct {
+	Class    uint16
+	Path     uint16
+	Function uint16
+	Args     [5]int32
+	Extra    []byte
+}
+
+type FocasResponse struct {
+	Class    uint16
+	Path     uint16
+	Function uint16
+	Error    int16
+	Data     []byte
+}
+
+// Wire images of the fwlib32.h structures, long is 32 bit on the wire
+type NativeODBST struct {
+	Dummy     [2]int16
+	Aut       int16
+	Manual    int16
+	Run       int16
+	Edit      int16
+	Motion    int16
+	Mstb      int16
+	Emergency int16
+	Write     int16
+	Labelskip int16
+	Alarm     int16
+	Warning   int16
+	Battery   int16
+}
+
+type NativeODBSYS struct {
+	Addinfo int16
+	MaxAxis int16
+	CncType [2]byte
+	MtType  [2]byte
+	Series  [4]byte
+	Version [4]byte
+	Axes    [2]byte
+}
+
+type NativeODBSYSEX struct {
+	MaxAxis  int16
+	MaxSpdl  int16
+	MaxPath  int16
+	MaxMchn  int16
+	CtrlAxis int16
+	CtrlSrvo int16
+	CtrlSpdl int16
+	CtrlPath int16
+	CtrlMchn int16
+	Addinfo  int16
+	Reserved [2]int16
+}
+
+type NativeODBPRO struct {
+	Dummy [2]int16
+	Data  int32
+	Mdata int32
+}
+
+type NativeODBSEQ struct {
+	Dummy [2]int16
+	Data  int32
+}
+
+type NativeODBTLIFE4 struct {
+	Datano int16
+	Type   int16
+	Data   int32
+}
+
+type NativeODBMDL struct {
+	Datano  int16
+	Type    int16
+	GData   byte
+	Reserve [3]byte
+}
+
+// ODBMDL of M, S, T, F, H, D codes
+type NativeODBMDLAUX struct {
+	Datano  int16
+	Type    int16
+	AuxData int32
+	Flag1   byte
+	Flag2   byte
+	Reserve [2]byte
+}
+
+type NativeODBGCD struct {
+	Group int16
+	Flag  int16
+	Code  [8]byte
+}
+
+type NativeIODBPSD struct {
+	Datano int16
+	Type   int16
+	Value  int32
+	Dec    int32
+}
+
+type NativeIODBSGNL struct {
+	Datano    int16
+	Type      int16
+	Mode      int16
+	HndlAx    int16
+	HndlMv    int16
+	RpdOvrd   int16
+	JogOvrd   int16
+	FeedOvrd  int16
+	SpdlOvrd  int16
+	BlckDel   int16
+	SnglBlck  int16
+	MachnLock int16
+	DryRun    int16
+	MemPrtct  int16
+	FeedHold  int16
+	ManualRpd int16
+	Dummy     [2]int16
+}
+
+// common element of position, load meter, speed and axis data arrays
+type NativeAxisElement struct {
+	Name    [4]byte
+	Data    int32
+	Dec     int16
+	Unit    int16
+	Flag    int16
+	Reserve int16
+}
+
+type NativeODBALMMSG2 struct {
+	AlmNo  int32
+	Type   int16
+	Axis   int16
+	Dummy  int16
+	MsgLen int16
+	AlmMsg [64]byte
+}
+
+// alm_his element of ODBAHIS
+type NativeODBAHISEntry struct {
+	Dummy  int16
+	AlmGrp int16
+	AlmNo  int16
+	AxisNo byte
+	Year   byte
+	Month  byte
+	Day    byte
+	Hour   byte
+	Minute byte
+	Second byte
+	Dummy2 byte
+	LenMsg int16
+	AlmMsg [32]byte
+}
+
+type NativeOPMSG3 struct {
+	Datano  int16
+	Type    int16
+	CharNum int16
+	Data    [256]byte
+}
+
+// IODBMR data item
+type NativeIODBMR struct {
+	McrVal int32
+	DecVal int16
+	Dummy  int16
+}
+
+// PRGDIR3 of cnc_rdprogdir3 and ODBPDFADIR of cnc_rdpdf_alldir
+type NativePRGDIR struct {
+	Number  int32
+	Length  int32
+	Name    [36]byte
+	Comment [52]byte
+	Year    int16
+	Month   int16
+	Day     int16
+	Hour    int16
+	Minute  int16
+	Dummy   int16
+}
+
+type NativeODBTLINF struct {
+	OfsType int16
+	UseNo   int16
+}
+
+type NativeODBTLINFO struct {
+	MaxGroup  int32
+	MaxTool   int32
+	MaxMinute int32
+	MaxCycle  int32
+}
+
+type NativeODBUSEGRP struct {
+	Next    int32
+	Use     int32
+	Slct    int32
+	OptNext int32
+	OptUse  int32
+	OptSlct int32
+}
+
+type NativeIODBTLGRP struct {
+	Ntool     int32
+	Nfree     int32
+	Life      int32
+	Count     int32
+	UseTool   int32
+	OptGrpno  int32
+	LifeRest  int32
+	RestSig   int16
+	CountType int16
+}
+
+type NativeIODBTLTOOL struct {
+	ToolNum int32
+	HCode   int32
+	DCode   int32
+	ToolInf int32
+}
+
+type NativeIODBTIME struct {
+	Minute int32
+	Msec   int32
+}
+
+type NativeODBAXISNAME struct {
+	Name byte
+	Suff byte
+}
+
+type NativeODBSPDLNAME struct {
+	Name  byte
+	Suff1 byte
+	Suff2 byte
+	Suff3 byte
+}
+
+func WriteFocasPacket(writer io.Writer, packet_type uint16, body []byte) error {
+	var buf bytes.Buffer
+	buf.Write(focas_magic[:])
+	binary.Write(&buf, binary.BigEndian, focas_version)
+	binary.Write(&buf, binary.BigEndian, packet_type)
+	binary.Write(&buf, binary.BigEndian, uint16(len(body)))
+	buf.Write(body)
+	_, err := writer.Write(buf.Bytes())
+	return err
+}
+
+func ReadFocasPacket(reader io.Reader) (uint16, []byte, error) {
+	var header [focas_header_size]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		return 0, nil, err
+	}
+	if !bytes.Equal(header[0:4], focas_magic[:]) {
+		return 0, nil, ErrFocasMagic
+	}
+	packet_type := binary.BigEndian.Uint16(header[6:8])
+	length := binary.BigEndian.Uint16(header[8:10])
+	body := make([]byte, length)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return 0, nil, err
+	}
+	return packet_type, body, nil
+}
+
+func EncodeFocasRequests(requests []FocasRequest) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, uint16(len(requests)))
+	for _, request := range requests {
+		binary.Write(&buf, binary.BigEndian, uint16(focas_request_block_size+len(request.Extra)))
+		binary.Write(&buf, binary.BigEndian, request.Class)
+		binary.Write(&buf, binary.BigEndian, request.Path)
+		binary.Write(&buf, binary.BigEndian, request.Function)
+		binary.Write(&buf, binary.BigEndian, request.Args)
+		buf.Write(request.Extra)
+	}
+	return buf.Bytes()
+}
+
+func DecodeFocasRequests(body []byte) ([]FocasRequest, error) {
+	if len(body) < 2 {
+		return nil, ErrFocasBlock
+	}
+	count := int(binary.BigEndian.Uint16(body[0:2]))
+	offset := 2
+	requests := make([]FocasRequest, 0, count)
+	for range count {
+		if offset+focas_request_block_size > len(body) {
+			return nil, ErrFocasBlock
+		}
+		length := int(binary.BigEndian.Uint16(body[offset : offset+2]))
+		if length < focas_request_block_size || offset+length > len(body) {
+			return nil, ErrFocasBlock
+		}
+		block := body[offset : offset+length]
+		var request FocasRequest
+		request.Class = binary.BigEndian.Uint16(block[2:4])
+		request.Path = binary.BigEndian.Uint16(block[4:6])
+		request.Function = binary.BigEndian.Uint16(block[6:8])
+		for index := range request.Args {
+			request.Args[index] = int32(binary.BigEndian.Uint32(block[8+index*4 : 12+index*4]))
+		}
+		request.Extra = block[focas_request_block_size:]
+		requests = append(requests, request)
+		offset += length
+	}
+	return requests, nil
+}
+
+func EncodeFocasResponses(responses []FocasResponse) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, uint16(len(responses)))
+	for _, response := range responses {
+		binary.Write(&buf, binary.BigEndian, uint16(focas_response_block_size+len(response.Data)))
+		binary.Write(&buf, binary.BigEndian, response.Class)
+		binary.Write(&buf, binary.BigEndian, response.Path)
+		binary.Write(&buf, binary.BigEndian, response.Function)
+		binary.Write(&buf, binary.BigEndian, response.Error)
+		binary.Write(&buf, binary.BigEndian, uint16(len(response.Data)))
+		buf.Write(response.Data)
+	}
+	return buf.Bytes()
+}
+
+func DecodeFocasResponses(body []byte) ([]FocasResponse, error) {
+	if len(body) < 2 {
+		return nil, ErrFocasBlock
+	}
+	count := int(binary.BigEndian.Uint16(body[0:2]))
+	offset := 2
+	responses := make([]FocasResponse, 0, count)
+	for range count {
+		if offset+focas_response_block_size > len(body) {
+			return nil, ErrFocasBlock
+		}
+		length := int(binary.BigEndian.Uint16(body[offset : offset+2]))
+		if length < focas_response_block_size || offset+length > len(body) {
+			return nil, ErrFocasBlock
+		}
+		block := body[offset : offset+length]
+		var response FocasResponse
+		response.Class = binary.BigEndian.Uint16(block[2:4])
+		response.Path = binary.BigEndian.Uint16(block[4:6])
+		response.Function = binary.BigEndian.Uint16(block[6:8])
+		response.Error = int16(binary.BigEndian.Uint16(block[8:10]))
+		data_length := int(binary.BigEndian.Uint16(block[10:12]))
+		if focas_response_block_size+data_length > length {
+			return nil, ErrFocasBlock
+		}
+		response.Data = block[focas_response_block_size : focas_response_block_size+data_length]
+		responses = append(responses, response)
+		offset += length
+	}
+	return responses, nil
+}
+
+func EncodeNative(data any) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, data)
+	return buf.Bytes()
+}
+
+func DecodeNative(data []byte, out any) int16 {
+	if binary.Size(out) > len(data) {
+		return EW_PROTOCOL
+	}
+	if err := binary.Read(bytes.NewReader(data), binary.BigEndian, out); err != nil {
+		return EW_PROTOCOL
+	}
+	return EW_OK
+}
+
+func DecodeNativeElements(data []byte) ([]NativeAxisElement, int16) {
+	size := binary.Size(NativeAxisElement{})
+	elements := make([]NativeAxisElement, len(data)/size)
+	if len(elements) == 0 {
+		return elements, EW_OK
+	}
+	return elements, DecodeNative(data[:len(elements)*size], elements)
+}
+
+func NativeString(data []byte) string {
+	if index := bytes.IndexByte(data, 0); index >= 0 {
+		return string(data[:index])
+	}
+	return string(data)
+}
+
+func NativeName(name string) [4]byte {
+	var result [4]byte
+	copy(result[:], name)
+	return result
+}
+
+func NativeElement(name string, value float64, dec int16) NativeAxisElement {
+	return NativeAxisElement{
+		Name: NativeName(name),
+		Data: int32(math.Round(value * math.Pow(10, float64(dec)))),
+		Dec:  dec,
+	}
+}
+
+func (element NativeAxisElement) Value() float64 {
+	return float64(element.Data) * math.Pow(10, -float64(element.Dec))
+}
@@ -0,0 +1,809 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"maps"
+	"math"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type FocasHandler func(request FocasRequest) FocasResponse
+
+// loopback stand-in speaking the FOCAS2 Ethernet framing
+type FocasServer struct {
+	listener net.Listener
+	handler  FocasHandler
+	conns    map[net.Conn]bool
+	mutex    sync.Mutex
+	wait     sync.WaitGroup
+}
+
+func StartFocasServer(address string, handler FocasHandler) (*FocasServer, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	focas_server := &FocasServer{
+		listener: listener,
+		handler:  handler,
+		conns:    make(map[net.Conn]bool),
+	}
+	focas_server.wait.Add(1)
+	go focas_server.acceptLoop()
+	return focas_server, nil
+}
+
+func (focas_server *FocasServer) Addr() *net.TCPAddr {
+	return focas_server.listener.Addr().(*net.TCPAddr)
+}
+
+func (focas_server *FocasServer) Close() {
+	focas_server.listener.Close()
+	focas_server.mutex.Lock()
+	for conn := range focas_server.conns {
+		conn.Close()
+	}
+	focas_server.mutex.Unlock()
+	focas_server.wait.Wait()
+}
+
+func (focas_server *FocasServer) acceptLoop() {
+	defer focas_server.wait.Done()
+	for {
+		conn, err := focas_server.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				logger.Println("Ошибка приема соединения FOCAS:", err)
+			}
+			return
+		}
+		focas_server.mutex.Lock()
+		focas_server.conns[conn] = true
+		focas_server.mutex.Unlock()
+		focas_server.wait.Add(1)
+		go focas_server.serve(conn)
+	}
+}
+
+func (focas_server *FocasServer) serve(conn net.Conn) {
+	defer focas_server.wait.Done()
+	defer func() {
+		focas_server.mutex.Lock()
+		delete(focas_server.conns, conn)
+		focas_server.mutex.Unlock()
+		conn.Close()
+	}()
+	for {
+		packet_type, body, err := ReadFocasPacket(conn)
+		if err != nil {
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				logger.Println("Ошибка чтения пакета FOCAS:", err)
+			}
+			return
+		}
+		switch packet_type {
+		case focas_open_request:
+			err = WriteFocasPacket(conn, focas_open_response, EncodeNative(EW_OK))
+		case focas_close_request:
+			WriteFocasPacket(conn, focas_close_response, nil)
+			return
+		case focas_data_request:
+			requests, decode_err := DecodeFocasRequests(body)
+			if decode_err != nil {
+				logger.Println("Ошибка разбора запроса FOCAS:", decode_err)
+				return
+			}
+			responses := make([]FocasResponse, 0, len(requests))
+			for _, request := range requests {
+				response := focas_server.handler(request)
+				response.Class = request.Class
+				response.Path = request.Path
+				response.Function = request.Function
+				responses = append(responses, response)
+			}
+			err = WriteFocasPacket(conn, focas_data_response, EncodeFocasResponses(responses))
+		default:
+			logger.Printf("Неизвестный тип пакета FOCAS: 0x%04X", packet_type)
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+type FocasAxisState struct {
+	Name               string  `yaml:"name"`
+	Absolute           float64 `yaml:"absolute"`
+	Machine            float64 `yaml:"machine"`
+	Relative           float64 `yaml:"relative"`
+	DistanceToGo       float64 `yaml:"distance_to_go"`
+	ServoLoad          float64 `yaml:"servo_load"`
+	CurrentLoad        float64 `yaml:"current_load"`
+	CurrentLoadPercent float64 `yaml:"current_load_percent"`
+	JogSpeed           float64 `yaml:"jog_speed"`
+	JogDisabled        bool    `yaml:"jog_disabled"`
+	FeedPrg            float64 `yaml:"feed_prg"`
+	FeedNote           float64 `yaml:"feed_note"`
+}
+
+type FocasSpindleState struct {
+	Name       string  `yaml:"name"`
+	Speed      float64 `yaml:"speed"`
+	MotorSpeed float64 `yaml:"motor_speed"`
+	Load       float64 `yaml:"load"`
+	// alarm number of cnc_rdspdlalm
+	Alarm uint8 `yaml:"alarm"`
+}
+
+type FocasAlarmState struct {
+	Number  int32  `yaml:"number"`
+	Type    int16  `yaml:"type"`
+	Axis    int16  `yaml:"axis"`
+	Message string `yaml:"message"`
+}
+
+type FocasOperatorMessageState struct {
+	Number  int16  `yaml:"number"`
+	Type    int16  `yaml:"type"`
+	Message string `yaml:"message"`
+}
+
+// program directory entry, modified in "2006-01-02 15:04" format
+type FocasProgramState struct {
+	Number   int32  `yaml:"number"`
+	Name     string `yaml:"name"`
+	Comment  string `yaml:"comment"`
+	Size     int32  `yaml:"size"`
+	Modified string `yaml:"modified"`
+	// text of cnc_upload4
+	Content string `yaml:"content"`
+}
+
+// tool of the tool life group, state 0 - unused, 1 - in use, 2 - expired, 3 - skipped
+type FocasToolLifeToolState struct {
+	Number int32 `yaml:"number"`
+	State  int32 `yaml:"state"`
+}
+
+// count type 0 - cycles, 1 - minutes
+type FocasToolLifeGroupState struct {
+	Group     int32                    `yaml:"group"`
+	Life      int32                    `yaml:"life"`
+	Count     int32                    `yaml:"count"`
+	CountType int16                    `yaml:"count_type"`
+	Tools     []FocasToolLifeToolState `yaml:"tools"`
+}
+
+// alarm history entry, time in "2006-01-02 15:04:05" format
+type FocasAlarmHistoryState struct {
+	FocasAlarmState `yaml:",inline"`
+	Time            string `yaml:"time"`
+}
+
+// ms and minute parameters of cnc_rdtimer types, power on time has minutes only
+var timer_type_params = map[int32][2]int32{
+	0: {0, 6750},
+	1: {6751, 6752},
+	2: {6753, 6754},
+	3: {6757, 6758},
+	4: {6755, 6756},
+}
+
+// controller image served by the stand-in and the simulator
+type FocasState struct {
+	mutex            sync.Mutex
+	Series           string                      `yaml:"series"`
+	Version          string                      `yaml:"version"`
+	CncType          string                      `yaml:"cnc_type"`
+	MtType           string                      `yaml:"mt_type"`
+	CncId            [4]uint32                   `yaml:"cnc_id"`
+	Aut              int16                       `yaml:"aut"`
+	Run              int16                       `yaml:"run"`
+	Edit             int16                       `yaml:"edit"`
+	Motion           int16                       `yaml:"motion"`
+	Mstb             int16                       `yaml:"mstb"`
+	Emergency        int16                       `yaml:"emergency"`
+	Alarm            int16                       `yaml:"alarm"`
+	AlarmMessages    []FocasAlarmState           `yaml:"alarm_messages"`
+	AlarmHistory     []FocasAlarmHistoryState    `yaml:"alarm_history"`
+	OperatorMessages []FocasOperatorMessageState `yaml:"operator_messages"`
+	Programs         []FocasProgramState         `yaml:"programs"`
+	// area -> address -> byte
+	Pmc map[string]map[uint16]byte `yaml:"pmc"`
+	// custom macro variables, absent are vacant
+	Macros map[int32]float64 `yaml:"macros"`
+	G00    bool              `yaml:"g00"`
+	// group -> G code, motion group follows g00
+	GCodes map[int16]string `yaml:"g_codes"`
+	// m, h, d codes, t, s, f follow tool number, spindle speed and feedrate
+	ModalCodes     map[string]int32 `yaml:"modal_codes"`
+	Program        string           `yaml:"program"`
+	MainProgram    int32            `yaml:"main_program"`
+	RunningProgram int32            `yaml:"running_program"`
+	// empty - O number of the running program in the memory
+	ProgramPath    string `yaml:"program_path"`
+	SequenceNumber int32  `yaml:"sequence_number"`
+	// blocks of program before the executing one
+	ExecutedBlocks int16 `yaml:"executed_blocks"`
+	BlockCount     int32 `yaml:"block_count"`
+	ToolNumber     int32 `yaml:"tool_number"`
+	// offset number -> values by cnc_rdtofsr type
+	ToolOffsets      map[int16][]int32         `yaml:"tool_offsets"`
+	ToolOffsetType   int16                     `yaml:"tool_offset_type"`
+	ToolOffsetCount  int16                     `yaml:"tool_offset_count"`
+	ToolLifeGroups   []FocasToolLifeGroupState `yaml:"tool_life_groups"`
+	ToolLifeUseGroup int32                     `yaml:"tool_life_use_group"`
+	// offset number -> values by axis, 0 - external, 1-6 - G54-G59, 7 - G54.1 P1
+	WorkOffsets     map[int16][]int32 `yaml:"work_offsets"`
+	WorkOffsetCount int16             `yaml:"work_offset_count"`
+	// absent without the function
+	WorkShift       []int32             `yaml:"work_shift"`
+	Feedrate        float64             `yaml:"feedrate"`
+	SpindleSpeed    float64             `yaml:"spindle_speed"`
+	FeedOverride    int16               `yaml:"feed_override"`
+	JogOverride     int16               `yaml:"jog_override"`
+	SpindleOverride int16               `yaml:"spindle_override"`
+	Axes            []FocasAxisState    `yaml:"axes"`
+	Spindles        []FocasSpindleState `yaml:"spindles"`
+	Parameters      map[int32]int32     `yaml:"parameters"`
+	// the same value for all axes
+	Diagnostics map[int32]int32 `yaml:"diagnostics"`
+	// number -> values by axis or spindle number from 1
+	AxisDiagnostics map[int32][]int32 `yaml:"axis_diagnostics"`
+	OtherPaths      []*FocasState     `yaml:"other_paths"`
+}
+
+func NewFocasState() *FocasState {
+	return &FocasState{
+		Series:       "D4F1",
+		Version:      "40.0",
+		CncType:      "0",
+		MtType:       "T",
+		CncId:        [4]uint32{0x12345678, 0x9ABCDEF0, 0x0F1E2D3C, 0x4B5A6978},
+		Aut:          1,
+		Program:      "N10 G00 X0 Z0\nN20 G01 X10 F100\nN30 M00\n",
+		MainProgram:  1000,
+		FeedOverride: 100,
+		JogOverride:  100,
+		Axes: []FocasAxisState{
+			{Name: "X"},
+			{Name: "Z"},
+		},
+		Spindles: []FocasSpindleState{
+			{Name: "S1"},
+		},
+		GCodes: map[int16]string{
+			2: "G18", 3: "G90", 5: "G95", 6: "G21", 7: "G40", 14: "G54",
+		},
+		Parameters:      map[int32]int32{},
+		ToolOffsetCount: 16,
+	}
+}
+
+func (state *FocasState) PathCount() int16 {
+	return int16(1 + len(state.OtherPaths))
+}
+
+// path 0 is the default path of the session
+func (state *FocasState) PathState(path uint16) *FocasState {
+	if path <= 1 {
+		return state
+	}
+	if int(path)-2 < len(state.OtherPaths) {
+		return state.OtherPaths[path-2]
+	}
+	return nil
+}
+
+func (state *FocasState) HandleRequest(request FocasRequest) FocasResponse {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	if request.Class == focas_class_pmc {
+		return state.handlePmcRequest(request)
+	}
+	if request.Class != focas_class_cnc {
+		return FocasResponse{Error: EW_FUNC}
+	}
+	path_state := state.PathState(request.Path)
+	if path_state == nil {
+		return FocasResponse{Error: EW_PATH}
+	}
+	switch request.Function {
+	case fn_getpath:
+		path := max(int16(request.Path), 1)
+		return FocasResponse{Data: EncodeNative([2]int16{path, state.PathCount()})}
+	case fn_setpath:
+		if request.Args[0] < 1 || request.Args[0] > int32(state.PathCount()) {
+			return FocasResponse{Error: EW_PATH}
+		}
+		return FocasResponse{}
+	case fn_sysinfo, fn_sysinfo_ex, fn_rdcncid:
+		return state.handlePathRequest(request, state)
+	}
+	return path_state.handlePathRequest(request, state)
+}
+
+func (state *FocasState) handlePathRequest(request FocasRequest, root *FocasState) FocasResponse {
+	args := request.Args
+	var data any
+	switch request.Function {
+	case fn_sysinfo:
+		var buf NativeODBSYS
+		buf.MaxAxis = native_max_axis
+		copy(buf.CncType[:], state.CncType)
+		copy(buf.MtType[:], state.MtType)
+		copy(buf.Series[:], state.Series)
+		copy(buf.Version[:], state.Version)
+		copy(buf.Axes[:], []byte{'0' + byte(len(state.Axes)/10), '0' + byte(len(state.Axes)%10)})
+		data = buf
+	case fn_sysinfo_ex:
+		data = NativeODBSYSEX{
+			MaxAxis:  native_max_axis,
+			MaxSpdl:  native_max_spindles,
+			MaxPath:  state.PathCount(),
+			CtrlAxis: int16(len(state.Axes)),
+			CtrlSrvo: int16(len(state.Axes)),
+			CtrlSpdl: int16(len(state.Spindles)),
+			CtrlPath: state.PathCount(),
+		}
+	case fn_statinfo:
+		data = NativeODBST{
+			Aut:       state.Aut,
+			Run:       state.Run,
+			Edit:      state.Edit,
+			Motion:    state.Motion,
+			Mstb:      state.Mstb,
+			Emergency: state.Emergency,
+			Alarm:     state.Alarm,
+		}
+	case fn_modal:
+		if args[0] >= 100 {
+			return state.modalCode(args[0])
+		}
+		var buf NativeODBMDL
+		if !state.G00 {
+			buf.GData = 1
+		}
+		data = buf
+	case fn_rdgcode:
+		codes := []NativeODBGCD{{Group: 0}}
+		copy(codes[0].Code[:], "G01")
+		if state.G00 {
+			copy(codes[0].Code[:], "G00")
+		}
+		for _, group := range slices.Sorted(maps.Keys(state.GCodes)) {
+			if group < 2 || len(codes) >= int(args[2]) {
+				continue
+			}
+			code := NativeODBGCD{Group: group - 1}
+			copy(code.Code[:], state.GCodes[group])
+			codes = append(codes, code)
+		}
+		data = codes
+	case fn_rdexecprog:
+		if args[0] < 0 {
+			return FocasResponse{Error: EW_LENGTH}
+		}
+		text := []byte(state.Program)
+		if int(args[0]) < len(text) {
+			text = text[:args[0]]
+		}
+		header := EncodeNative([2]uint16{uint16(len(text)), uint16(state.ExecutedBlocks)})
+		return FocasResponse{Data: append(header, text...)}
+	case fn_rdprgnum:
+		data = NativeODBPRO{Data: state.RunningProgram, Mdata: state.MainProgram}
+	case fn_exeprgname2:
+		path := state.ProgramPath
+		if path == "" {
+			path = "//CNC_MEM/USER/PATH1/" + FormatProgramName(state.RunningProgram)
+		}
+		return FocasResponse{Data: append([]byte(path), 0)}
+	case fn_rdblkcount:
+		data = state.BlockCount
+	case fn_rdseqnum:
+		data = NativeODBSEQ{Data: state.SequenceNumber}
+	case fn_toolnum:
+		data = NativeODBTLIFE4{Data: state.ToolNumber}
+	case fn_rdtofsinfo:
+		data = NativeODBTLINF{OfsType: state.ToolOffsetType, UseNo: state.ToolOffsetCount}
+	case fn_rdtlinfo:
+		info := NativeODBTLINFO{}
+		for _, group := range state.ToolLifeGroups {
+			info.MaxGroup = max(info.MaxGroup, group.Group)
+			info.MaxTool = max(info.MaxTool, int32(len(group.Tools)))
+		}
+		data = info
+	case fn_rdtlusegrp:
+		data = NativeODBUSEGRP{Use: state.ToolLifeUseGroup}
+	case fn_rdtlgrp:
+		if args[0] < 1 {
+			return FocasResponse{Error: EW_NUMBER}
+		}
+		if args[1] < 0 || args[1] > tool_life_group_batch {
+			return FocasResponse{Error: EW_LENGTH}
+		}
+		groups := make([]NativeIODBTLGRP, args[1])
+		for _, group := range state.ToolLifeGroups {
+			index := group.Group - args[0]
+			if index < 0 || index >= args[1] {
+				continue
+			}
+			groups[index] = NativeIODBTLGRP{Ntool: int32(len(group.Tools)), Life: group.Life, Count: group.Count, CountType: group.CountType}
+			for _, tool := range group.Tools {
+				if tool.State == 0 {
+					groups[index].Nfree++
+				} else if tool.State == 1 {
+					groups[index].UseTool = tool.Number
+				}
+			}
+		}
+		data = groups
+	case fn_rdtltool:
+		index := slices.IndexFunc(state.ToolLifeGroups, func(group FocasToolLifeGroupState) bool {
+			return group.Group == args[0]
+		})
+		if index < 0 {
+			return FocasResponse{Error: EW_NUMBER}
+		}
+		if args[2] < 0 {
+			return FocasResponse{Error: EW_LENGTH}
+		}
+		group_tools := state.ToolLifeGroups[index].Tools
+		start := min(max(int(args[1])-1, 0), len(group_tools))
+		tools := make([]NativeIODBTLTOOL, 0, min(int(args[2]), len(group_tools)))
+		for _, tool := range group_tools[start:min(start+int(args[2]), len(group_tools))] {
+			tools = append(tools, NativeIODBTLTOOL{ToolNum: tool.Number, ToolInf: tool.State})
+		}
+		data = tools
+	case fn_rdzofsinfo:
+		data = state.WorkOffsetCount
+	case fn_rdzofsr:
+		start, end := args[0], args[2]
+		if start < 0 || end < start || end > int32(len(work_offset_names))-1+int32(state.WorkOffsetCount) {
+			return FocasResponse{Error: EW_NUMBER}
+		}
+		values := make([]int32, 0, int(end-start+1)*len(state.Axes))
+		for number := start; number <= end; number++ {
+			offsets := state.WorkOffsets[int16(number)]
+			for index := range state.Axes {
+				if index < len(offsets) {
+					values = append(values, offsets[index])
+				} else {
+					values = append(values, 0)
+				}
+			}
+		}
+		data = values
+	case fn_rdwkcdshft:
+		if state.WorkShift == nil {
+			return FocasResponse{Error: EW_FUNC}
+		}
+		values := make([]int32, len(state.Axes))
+		copy(values, state.WorkShift)
+		data = values
+	case fn_rdtofsr:
+		start, offset_type, end := args[0], args[1], args[2]
+		if start < 1 || end < start || end > int32(state.ToolOffsetCount) {
+			return FocasResponse{Error: EW_NUMBER}
+		}
+		if offset_type < 0 {
+			return FocasResponse{Error: EW_ATTRIB}
+		}
+		values := make([]int32, 0, end-start+1)
+		for number := start; number <= end; number++ {
+			offsets := state.ToolOffsets[int16(number)]
+			if int(offset_type) < len(offsets) {
+				values = append(values, offsets[offset_type])
+			} else {
+				values = append(values, 0)
+			}
+		}
+		data = values
+	case fn_rdparam:
+		value, ok := state.Parameters[args[0]]
+		if !ok {
+			// machine parameters are kept by the first path
+			value, ok = root.Parameters[args[0]]
+		}
+		if !ok {
+			return FocasResponse{Error: EW_NUMBER}
+		}
+		data = NativeIODBPSD{Datano: int16(args[0]), Type: int16(args[1]), Value: value}
+	case fn_diagnoss:
+		value, ok := state.Diagnostics[args[0]]
+		if values, axis_ok := state.AxisDiagnostics[args[0]]; axis_ok && args[1] > 0 {
+			if int(args[1]) > len(values) {
+				return FocasResponse{Error: EW_ATTRIB}
+			}
+			value, ok = values[args[1]-1], true
+		}
+		if !ok {
+			return FocasResponse{Error: EW_NUMBER}
+		}
+		data = NativeIODBPSD{Datano: int16(args[0]), Type: int16(args[1]), Value: value}
+	case fn_rdmacror:
+		start, end := args[0], args[1]
+		if end < start || int64(end)-int64(start) >= macro_batch {
+			return FocasResponse{Error: EW_LENGTH}
+		}
+		values := make([]NativeIODBMR, 0, end-start+1)
+		for number := start; number <= end; number++ {
+			value := CncMacroValue{Exponent: -1}
+			if macro, ok := state.Macros[number]; ok {
+				value = NewMacroValue(macro)
+			}
+			values = append(values, NativeIODBMR{McrVal: value.Mantissa, DecVal: value.Exponent})
+		}
+		data = values
+	case fn_rdspeed:
+		switch args[0] {
+		case 0:
+			data = NativeElement("F", state.Feedrate, 0)
+		case 1:
+			data = NativeElement("S", state.SpindleSpeed, 0)
+		default:
+			return FocasResponse{Error: EW_NUMBER}
+		}
+	case fn_rdposition:
+		elements := make([]NativeAxisElement, 0, len(state.Axes))
+		for _, axis := range state.Axes {
+			switch args[0] {
+			case 0:
+				elements = append(elements, NativeElement(axis.Name, axis.Absolute, 3))
+			case 1:
+				elements = append(elements, NativeElement(axis.Name, axis.Machine, 3))
+			case 2:
+				elements = append(elements, NativeElement(axis.Name, axis.Relative, 3))
+			case 3:
+				elements = append(elements, NativeElement(axis.Name, axis.DistanceToGo, 3))
+			case -1:
+				elements = append(elements,
+					NativeElement(axis.Name, axis.Absolute, 3),
+					NativeElement(axis.Name, axis.Machine, 3),
+					NativeElement(axis.Name, axis.Relative, 3),
+					NativeElement(axis.Name, axis.DistanceToGo, 3))
+			default:
+				return FocasResponse{Error: EW_NUMBER}
+			}
+		}
+		data = elements
+	case fn_rdsvmeter:
+		elements := make([]NativeAxisElement, 0, len(state.Axes))
+		for _, axis := range state.Axes {
+			elements = append(elements, NativeElement(axis.Name, axis.ServoLoad, 0))
+		}
+		data = elements
+	case fn_rdtimer:
+		numbers, ok := timer_type_params[args[0]]
+		if !ok {
+			return FocasResponse{Error: EW_NUMBER}
+		}
+		data = NativeIODBTIME{Minute: root.Parameters[numbers[1]], Msec: root.Parameters[numbers[0]]}
+	case fn_rdspdlalm:
+		alarms := make([]uint8, native_max_spindles)
+		for index, spindle := range state.Spindles[:min(len(state.Spindles), native_max_spindles)] {
+			alarms[index] = spindle.Alarm
+		}
+		data = alarms
+	case fn_rdspmeter:
+		elements := make([]NativeAxisElement, 0, len(state.Spindles))
+		for _, spindle := range state.Spindles {
+			switch args[0] {
+			case 0:
+				elements = append(elements, NativeElement(spindle.Name, spindle.Load, 0))
+			case 1:
+				elements = append(elements, NativeElement(spindle.Name, spindle.MotorSpeed, 0))
+			default:
+				return FocasResponse{Error: EW_NUMBER}
+			}
+		}
+		data = elements
+	case fn_rdaxisdata:
+		elements, ret := state.axisData(args[0], args[1])
+		if ret != EW_OK {
+			return FocasResponse{Error: ret}
+		}
+		data = elements
+	case fn_rdopnlsgnl:
+		data = NativeIODBSGNL{
+			Type:     int16(args[0]),
+			JogOvrd:  state.JogOverride,
+			FeedOvrd: state.FeedOverride,
+			SpdlOvrd: state.SpindleOverride,
+		}
+	case fn_rdalmmsg2:
+		alarms := make([]NativeODBALMMSG2, 0, len(state.AlarmMessages))
+		for _, alarm := range state.AlarmMessages {
+			if args[0] != -1 && args[0] != int32(alarm.Type) {
+				continue
+			}
+			if len(alarms) >= int(args[1]) {
+				break
+			}
+			buf := NativeODBALMMSG2{AlmNo: alarm.Number, Type: alarm.Type, Axis: alarm.Axis}
+			buf.MsgLen = int16(copy(buf.AlmMsg[:], alarm.Message))
+			alarms = append(alarms, buf)
+		}
+		data = alarms
+	case fn_rdalmhisno:
+		data = uint16(len(state.AlarmHistory))
+	case fn_rdalmhistry:
+		if args[0] < 1 || args[1] < args[0] {
+			return FocasResponse{Error: EW_NUMBER}
+		}
+		entries := make([]NativeODBAHISEntry, 0, alarm_history_batch)
+		for number := args[0]; number <= args[1] && int(number) <= len(state.AlarmHistory) && len(entries) < alarm_history_batch; number++ {
+			entries = append(entries, state.AlarmHistory[number-1].Native())
+		}
+		data = entries
+	case fn_rdopmsg3:
+		messages := make([]NativeOPMSG3, 0, len(state.OperatorMessages))
+		for _, message := range state.OperatorMessages {
+			if len(messages) >= int(args[1]) {
+				break
+			}
+			buf := NativeOPMSG3{Datano: message.Number, Type: message.Type}
+			buf.CharNum = int16(copy(buf.Data[:], message.Message))
+			messages = append(messages, buf)
+		}
+		data = messages
+	case fn_rdprogdir3, fn_rdpdf_alldir:
+		// numbered programs of the memory or all programs of the folder
+		programs := make([]NativePRGDIR, 0)
+		for _, program := range state.Programs {
+			if request.Function == fn_rdprogdir3 && program.Number == 0 {
+				continue
+			}
+			programs = append(programs, program.Native())
+		}
+		if args[1] < 0 {
+			return FocasResponse{Error: EW_NUMBER}
+		}
+		if args[2] < 0 || args[2] > program_dir_batch {
+			return FocasResponse{Error: EW_LENGTH}
+		}
+		start := min(int(args[1]), len(programs))
+		data = programs[start:min(start+int(args[2]), len(programs))]
+	case fn_upload4:
+		path := string(request.Extra)
+		for _, program := range state.Programs {
+			if path == program.Name || strings.HasSuffix(path, "/"+program.Name) {
+				return FocasResponse{Data: []byte(program.Content)}
+			}
+		}
+		return FocasResponse{Error: EW_DATA}
+	case fn_rdcncid:
+		data = state.CncId
+	case fn_rdaxisname:
+		names := make([]NativeODBAXISNAME, 0, len(state.Axes))
+		for _, axis := range state.Axes {
+			name := NativeName(axis.Name)
+			names = append(names, NativeODBAXISNAME{Name: name[0], Suff: name[1]})
+		}
+		data = names
+	case fn_rdspdlname:
+		names := make([]NativeODBSPDLNAME, 0, len(state.Spindles))
+		for _, spindle := range state.Spindles {
+			name := NativeName(spindle.Name)
+			names = append(names, NativeODBSPDLNAME{Name: name[0], Suff1: name[1], Suff2: name[2], Suff3: name[3]})
+		}
+		data = names
+	default:
+		return FocasResponse{Error: EW_FUNC}
+	}
+	return FocasResponse{Data: EncodeNative(data)}
+}
+
+func (state *FocasState) handlePmcRequest(request FocasRequest) FocasResponse {
+	if request.Function != fn_pmc_rdpmcrng {
+		return FocasResponse{Error: EW_FUNC}
+	}
+	start, end, area := request.Args[0], request.Args[1], request.Args[2]
+	if start < 0 || end > math.MaxUint16 {
+		return FocasResponse{Error: EW_NUMBER}
+	}
+	if end < start || end-start >= pmc_max_range {
+		return FocasResponse{Error: EW_LENGTH}
+	}
+	var area_data map[uint16]byte
+	for name, code := range pmc_areas {
+		if int32(code) == area {
+			area_data = state.Pmc[name]
+		}
+	}
+	data := make([]byte, end-start+1)
+	for address := start; address <= end; address++ {
+		data[address-start] = area_data[uint16(address)]
+	}
+	return FocasResponse{Data: data}
+}
+
+func (state *FocasState) modalCode(code_type int32) FocasResponse {
+	buf := NativeODBMDLAUX{Type: int16(code_type)}
+	switch code_type {
+	case int32(modal_code_types["t"]):
+		buf.AuxData = state.ToolNumber
+	case int32(modal_code_types["s"]):
+		buf.AuxData = int32(state.SpindleSpeed)
+	case int32(modal_code_types["f"]):
+		buf.AuxData = int32(state.Feedrate)
+	default:
+		for name, modal_type := range modal_code_types {
+			if int32(modal_type) == code_type {
+				buf.AuxData = state.ModalCodes[name]
+			}
+		}
+	}
+	return FocasResponse{Data: EncodeNative(buf)}
+}
+
+func (program FocasProgramState) Native() NativePRGDIR {
+	entry := NativePRGDIR{Number: program.Number, Length: program.Size}
+	copy(entry.Name[:], program.Name)
+	copy(entry.Comment[:], program.Comment)
+	if modified, err := time.Parse("2006-01-02 15:04", program.Modified); err == nil {
+		entry.Year, entry.Month, entry.Day = int16(modified.Year()), int16(modified.Month()), int16(modified.Day())
+		entry.Hour, entry.Minute = int16(modified.Hour()), int16(modified.Minute())
+	}
+	return entry
+}
+
+func (alarm FocasAlarmHistoryState) Native() NativeODBAHISEntry {
+	alarm_time, _ := time.ParseInLocation(time.DateTime, alarm.Time, time.Local)
+	entry := NativeODBAHISEntry{
+		AlmGrp: alarm.Type,
+		AlmNo:  int16(alarm.Number),
+		AxisNo: byte(alarm.Axis),
+		Year:   byte(alarm_time.Year() % 100),
+		Month:  byte(alarm_time.Month()),
+		Day:    byte(alarm_time.Day()),
+		Hour:   byte(alarm_time.Hour()),
+		Minute: byte(alarm_time.Minute()),
+		Second: byte(alarm_time.Second()),
+	}
+	entry.LenMsg = int16(copy(entry.AlmMsg[:], alarm.Message))
+	return entry
+}
+
+func (state *FocasState) axisData(cls int32, data_type int32) ([]NativeAxisElement, int16) {
+	var elements []NativeAxisElement
+	switch {
+	case cls == 2 && data_type == 1:
+		for _, axis := range state.Axes {
+			elements = append(elements, NativeElement(axis.Name, axis.CurrentLoadPercent, 0))
+		}
+	case cls == 2 && data_type == 2:
+		for _, axis := range state.Axes {
+			elements = append(elements, NativeElement(axis.Name, axis.CurrentLoad, 1))
+		}
+	case cls == 3 && data_type == 2:
+		for _, spindle := range state.Spindles {
+			elements = append(elements, NativeElement(spindle.Name, spindle.Speed, 0))
+		}
+	case cls == 5 && data_type == 0:
+		for _, axis := range state.Axes {
+			elements = append(elements, NativeElement(axis.Name, axis.FeedPrg, 0))
+		}
+	case cls == 5 && data_type == 5:
+		for _, axis := range state.Axes {
+			elements = append(elements, NativeElement(axis.Name, axis.FeedNote, 0))
+		}
+	case cls == 5 && data_type == 2:
+		for _, axis := range state.Axes {
+			element := NativeElement(axis.Name, axis.JogSpeed, 0)
+			if axis.JogDisabled {
+				element.Flag |= 1 << 1
+			}
+			elements = append(elements, element)
+		}
+	default:
+		return nil, EW_NUMBER
+	}
+	return elements, EW_OK
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type SimulatorAxisStep struct {
+	Target      *float64 `yaml:"target"`
+	Load        *float64 `yaml:"load"`
+	Current     *float64 `yaml:"current"`
+	JogSpeed    *float64 `yaml:"jog_speed"`
+	JogDisabled *bool    `yaml:"jog_disabled"`
+}
+
+type SimulatorSpindleStep struct {
+	Speed      *float64 `yaml:"speed"`
+	MotorSpeed *float64 `yaml:"motor_speed"`
+	Load       *float64 `yaml:"load"`
+}
+
+type SimulatorStep struct {
+	Name             string                       `yaml:"name"`
+	DurationMs       int                          `yaml:"duration_ms"`
+	Aut              *int16                       `yaml:"aut"`
+	Run              *int16                       `yaml:"run"`
+	Edit             *int16                       `yaml:"edit"`
+	Motion           *int16                       `yaml:"motion"`
+	Mstb             *int16                       `yaml:"mstb"`
+	Emergency        *int16                       `yaml:"emergency"`
+	Alarm            *int16                       `yaml:"alarm"`
+	AlarmMessages    *[]FocasAlarmState           `yaml:"alarm_messages"`
+	OperatorMessages *[]FocasOperatorMessageState `yaml:"operator_messages"`
+	Programs         *[]FocasProgramState         `yaml:"programs"`
+	Pmc              map[string]map[uint16]byte   `yaml:"pmc"`
+	// null makes the variable vacant
+	Macros           map[int32]*float64              `yaml:"macros"`
+	G00              *bool                           `yaml:"g00"`
+	GCodes           map[int16]string                `yaml:"g_codes"`
+	ModalCodes       map[string]int32                `yaml:"modal_codes"`
+	Program          *string                         `yaml:"program"`
+	MainProgram      *int32                          `yaml:"main_program"`
+	RunningProgram   *int32                          `yaml:"running_program"`
+	ProgramPath      *string                         `yaml:"program_path"`
+	SequenceNumber   *int32                          `yaml:"sequence_number"`
+	ExecutedBlocks   *int16                          `yaml:"executed_blocks"`
+	BlockCount       *int32                          `yaml:"block_count"`
+	ToolNumber       *int32                          `yaml:"tool_number"`
+	ToolOffsets      map[int16][]int32               `yaml:"tool_offsets"`
+	ToolLifeGroups   *[]FocasToolLifeGroupState      `yaml:"tool_life_groups"`
+	ToolLifeUseGroup *int32                          `yaml:"tool_life_use_group"`
+	WorkOffsets      map[int16][]int32               `yaml:"work_offsets"`
+	Feedrate         *float64                        `yaml:"feedrate"`
+	FeedOverride     *int16                          `yaml:"feed_override"`
+	JogOverride      *int16                          `yaml:"jog_override"`
+	SpindleOverride  *int16                          `yaml:"spindle_override"`
+	Axes             map[string]SimulatorAxisStep    `yaml:"axes"`
+	Spindles         map[string]SimulatorSpindleStep `yaml:"spindles"`
+	Parameters       map[int32]int32                 `yaml:"parameters"`
+	Diagnostics      map[int32]int32                 `yaml:"diagnostics"`
+	AxisDiagnostics  map[int32][]int32               `yaml:"axis_diagnostics"`
+	PartsIncrement   int32                           `yaml:"parts_increment"`
+	Paths            map[int16]SimulatorStep         `yaml:"paths"`
+}
+
+type SimulatorScenario struct {
+	TickMs  int             `yaml:"tick_ms"`
+	Loop    bool            `yaml:"loop"`
+	Initial *FocasState     `yaml:"initial"`
+	Steps   []SimulatorStep `yaml:"steps"`
+}
+
+type Simulator struct {
+	name         string
+	state        *FocasState
+	scenario     SimulatorScenario
+	focas_server *FocasServer
+	stop         chan struct{}
+	wait         sync.WaitGroup
+	// scenario position
+	step_index int
+	step_time  time.Duration
+	step_start map[*FocasState][]float64
+	// operating counters in ms
+	power_on_ms  int64
+	operation_ms int64
+	cutting_ms   int64
+	cycle_ms     int64
+}
+
+func LoadSimulatorScenario(scenario_path string) (SimulatorScenario, error) {
+	scenario := SimulatorScenario{TickMs: 100, Loop: true, Initial: NewFocasState()}
+	if scenario_path == "" {
+		return scenario, nil
+	}
+	if !filepath.IsAbs(scenario_path) {
+		scenario_path = filepath.Join(plugin_dir, scenario_path)
+	}
+	file_content, err := os.ReadFile(scenario_path)
+	if err != nil {
+		return scenario, err
+	}
+	err = yaml.Unmarshal(file_content, &scenario)
+	if err != nil {
+		return scenario, err
+	}
+	if scenario.Initial == nil {
+		scenario.Initial = NewFocasState()
+	}
+	for path := int16(1); path <= scenario.Initial.PathCount(); path++ {
+		path_state := scenario.Initial.PathState(uint16(path))
+		if path_state == nil {
+			return scenario, fmt.Errorf("канал %d: пустое описание other_paths", path)
+		}
+		if path_state.Parameters == nil {
+			path_state.Parameters = map[int32]int32{}
+		}
+	}
+	if scenario.TickMs <= 0 {
+		scenario.TickMs = 100
+	}
+	for index, step := range scenario.Steps {
+		if step.DurationMs <= 0 {
+			return scenario, fmt.Errorf("шаг %d: некорректный duration_ms", index)
+		}
+	}
+	return scenario, nil
+}
+
+func StartSimulator(device *Device) (*Simulator, error) {
+	scenario, err := LoadSimulatorScenario(device.Scenario)
+	if err != nil {
+		return nil, err
+	}
+	simulator := &Simulator{
+		name:     device.Name,
+		state:    scenario.Initial,
+		scenario: scenario,
+		stop:     make(chan struct{}),
+	}
+	parameters := simulator.state.Parameters
+	simulator.power_on_ms = int64(parameters[6750]) * 60000
+	simulator.operation_ms = GetTimeParams(parameters, 6751, 6752)
+	simulator.cutting_ms = GetTimeParams(parameters, 6753, 6754)
+	simulator.cycle_ms = GetTimeParams(parameters, 6757, 6758)
+	simulator.updateCounters(0)
+	simulator.beginStep()
+	simulator.focas_server, err = StartFocasServer(FormatAddress(device.Address, device.Port), simulator.state.HandleRequest)
+	if err != nil {
+		return nil, err
+	}
+	simulator.wait.Add(1)
+	go simulator.run()
+	logger.Printf("Симулятор %s запущен на %s", device.Name, simulator.focas_server.Addr().String())
+	return simulator, nil
+}
+
+func (simulator *Simulator) Close() {
+	close(simulator.stop)
+	simulator.wait.Wait()
+	simulator.focas_server.Close()
+}
+
+func (simulator *Simulator) run() {
+	defer simulator.wait.Done()
+	tick := time.Duration(simulator.scenario.TickMs) * time.Millisecond
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-simulator.stop:
+			return
+		case <-ticker.C:
+			simulator.state.mutex.Lock()
+			simulator.tick(tick)
+			simulator.state.mutex.Unlock()
+		}
+	}
+}
+
+func (simulator *Simulator) beginStep() {
+	simulator.step_time = 0
+	simulator.step_start = make(map[*FocasState][]float64)
+	for path := int16(1); path <= simulator.state.PathCount(); path++ {
+		path_state := simulator.state.PathState(uint16(path))
+		step_start := make([]float64, len(path_state.Axes))
+		for index, axis := range path_state.Axes {
+			step_start[index] = axis.Absolute
+		}
+		simulator.step_start[path_state] = step_start
+	}
+	if simulator.step_index < len(simulator.scenario.Steps) {
+		for path_state, path_step := range simulator.pathSteps(simulator.scenario.Steps[simulator.step_index]) {
+			ApplySimulatorStep(path_state, path_step)
+		}
+	}
+}
+
+// step of the first path with the overrides of the other paths
+func (simulator *Simulator) pathSteps(step SimulatorStep) map[*FocasState]SimulatorStep {
+	result := map[*FocasState]SimulatorStep{simulator.state: step}
+	for path, path_step := range step.Paths {
+		if path_state := simulator.state.PathState(uint16(path)); path_state != nil && path > 1 {
+			result[path_state] = path_step
+		}
+	}
+	return result
+}
+
+func (simulator *Simulator) tick(tick time.Duration) {
+	simulator.updateCounters(tick)
+	if simulator.step_index >= len(simulator.scenario.Steps) {
+		return
+	}
+	step := simulator.scenario.Steps[simulator.step_index]
+	simulator.step_time += tick
+	duration := time.Duration(step.DurationMs) * time.Millisecond
+	progress := min(float64(simulator.step_time)/float64(duration), 1)
+	path_steps := simulator.pathSteps(step)
+	for path_state, path_step := range path_steps {
+		MoveSimulatorAxes(path_state, simulator.step_start[path_state], path_step, progress)
+	}
+	if simulator.step_time < duration {
+		return
+	}
+	// finish step
+	for path_state, path_step := range path_steps {
+		if path_step.PartsIncrement != 0 {
+			path_state.Parameters[6711] += path_step.PartsIncrement
+		}
+	}
+	if step.PartsIncrement != 0 {
+		simulator.cycle_ms = 0
+	}
+	simulator.step_index++
+	if simulator.step_index >= len(simulator.scenario.Steps) && simulator.scenario.Loop {
+		simulator.step_index = 0
+	}
+	simulator.beginStep()
+}
+
+func ApplySimulatorStep(state *FocasState, step SimulatorStep) {
+	SetIfPresent(&state.Aut, step.Aut)
+	SetIfPresent(&state.Run, step.Run)
+	SetIfPresent(&state.Edit, step.Edit)
+	SetIfPresent(&state.Motion, step.Motion)
+	SetIfPresent(&state.Mstb, step.Mstb)
+	SetIfPresent(&state.Emergency, step.Emergency)
+	SetIfPresent(&state.Alarm, step.Alarm)
+	if step.AlarmMessages != nil {
+		// new alarms go to the top of the history
+		alarm_time := time.Now().Format(time.DateTime)
+		for _, alarm := range *step.AlarmMessages {
+			if !slices.Contains(state.AlarmMessages, alarm) {
+				entry := FocasAlarmHistoryState{FocasAlarmState: alarm, Time: alarm_time}
+				state.AlarmHistory = slices.Insert(state.AlarmHistory, 0, entry)
+			}
+		}
+		state.AlarmMessages = *step.AlarmMessages
+	}
+	SetIfPresent(&state.OperatorMessages, step.OperatorMessages)
+	SetIfPresent(&state.Programs, step.Programs)
+	for area, values := range step.Pmc {
+		if state.Pmc == nil {
+			state.Pmc = make(map[string]map[uint16]byte)
+		}
+		if state.Pmc[area] == nil {
+			state.Pmc[area] = make(map[uint16]byte)
+		}
+		maps.Copy(state.Pmc[area], values)
+	}
+	for number, value := range step.Macros {
+		if state.Macros == nil {
+			state.Macros = make(map[int32]float64)
+		}
+		if value == nil {
+			delete(state.Macros, number)
+		} else {
+			state.Macros[number] = *value
+		}
+	}
+	SetIfPresent(&state.G00, step.G00)
+	for group, code := range step.GCodes {
+		if state.GCodes == nil {
+			state.GCodes = make(map[int16]string)
+		}
+		state.GCodes[group] = code
+	}
+	for name, code := range step.ModalCodes {
+		if state.ModalCodes == nil {
+			state.ModalCodes = make(map[string]int32)
+		}
+		state.ModalCodes[name] = code
+	}
+	SetIfPresent(&state.Program, step.Program)
+	SetIfPresent(&state.MainProgram, step.MainProgram)
+	SetIfPresent(&state.RunningProgram, step.RunningProgram)
+	SetIfPresent(&state.ProgramPath, step.ProgramPath)
+	SetIfPresent(&state.SequenceNumber, step.SequenceNumber)
+	SetIfPresent(&state.ExecutedBlocks, step.ExecutedBlocks)
+	SetIfPresent(&state.BlockCount, step.BlockCount)
+	SetIfPresent(&state.ToolNumber, step.ToolNumber)
+	for number, offsets := range step.ToolOffsets {
+		if state.ToolOffsets == nil {
+			state.ToolOffsets = make(map[int16][]int32)
+		}
+		state.ToolOffsets[number] = offsets
+	}
+	SetIfPresent(&state.ToolLifeGroups, step.ToolLifeGroups)
+	SetIfPresent(&state.ToolLifeUseGroup, step.ToolLifeUseGroup)
+	for number, offsets := range step.WorkOffsets {
+		if state.WorkOffsets == nil {
+			state.WorkOffsets = make(map[int16][]int32)
+		}
+		state.WorkOffsets[number] = offsets
+	}
+	SetIfPresent(&state.Feedrate, step.Feedrate)
+	SetIfPresent(&state.FeedOverride, step.FeedOverride)
+	SetIfPresent(&state.JogOverride, step.JogOverride)
+	SetIfPresent(&state.SpindleOverride, step.SpindleOverride)
+	for number, value := range step.Parameters {
+		state.Parameters[number] = value
+	}
+	for number, value := range step.Diagnostics {
+		if state.Diagnostics == nil {
+			state.Diagnostics = make(map[int32]int32)
+		}
+		state.Diagnostics[number] = value
+	}
+	for number, values := range step.AxisDiagnostics {
+		if state.AxisDiagnostics == nil {
+			state.AxisDiagnostics = make(map[int32][]int32)
+		}
+		state.AxisDiagnostics[number] = values
+	}
+	for index := range state.Axes {
+		axis := &state.Axes[index]
+		axis_step, ok := step.Axes[axis.Name]
+		if !ok {
+			continue
+		}
+		SetIfPresent(&axis.ServoLoad, axis_step.Load)
+		SetIfPresent(&axis.CurrentLoadPercent, axis_step.Load)
+		SetIfPresent(&axis.CurrentLoad, axis_step.Current)
+		SetIfPresent(&axis.JogSpeed, axis_step.JogSpeed)
+		SetIfPresent(&axis.JogDisabled, axis_step.JogDisabled)
+		if step.Feedrate != nil {
+			axis.FeedPrg = *step.Feedrate
+			axis.FeedNote = *step.Feedrate
+		}
+	}
+	for index := range state.Spindles {
+		spindle := &state.Spindles[index]
+		spindle_step, ok := step.Spindles[spindle.Name]
+		if !ok {
+			continue
+		}
+		SetIfPresent(&spindle.Speed, spindle_step.Speed)
+		SetIfPresent(&spindle.MotorSpeed, spindle_step.MotorSpeed)
+		SetIfPresent(&spindle.Load, spindle_step.Load)
+		if index == 0 {
+			state.SpindleSpeed = spindle.Speed
+		}
+	}
+}
+
+func MoveSimulatorAxes(state *FocasState, step_start []float64, step SimulatorStep, progress float64) {
+	for index := range state.Axes {
+		axis := &state.Axes[index]
+		axis_step, ok := step.Axes[axis.Name]
+		if !ok || axis_step.Target == nil || index >= len(step_start) {
+			continue
+		}
+		position := step_start[index] + (*axis_step.Target-step_start[index])*progress
+		delta := position - axis.Absolute
+		axis.Absolute = position
+		axis.Machine += delta
+		axis.Relative += delta
+		axis.DistanceToGo = *axis_step.Target - position
+	}
+}
+
+func (simulator *Simulator) updateCounters(tick time.Duration) {
+	state := simulator.state
+	tick_ms := tick.Milliseconds()
+	simulator.power_on_ms += tick_ms
+	if state.Run == 3 {
+		simulator.operation_ms += tick_ms
+		simulator.cycle_ms += tick_ms
+		if state.Motion == 1 && !state.G00 {
+			simulator.cutting_ms += tick_ms
+		}
+	}
+	state.Parameters[6750] = int32(simulator.power_on_ms / 60000)
+	SetTimeParams(state.Parameters, 6751, 6752, simulator.operation_ms)
+	SetTimeParams(state.Parameters, 6753, 6754, simulator.cutting_ms)
+	SetTimeParams(state.Parameters, 6757, 6758, simulator.cycle_ms)
+}
+
+func GetTimeParams(parameters map[int32]int32, ms_number int32, min_number int32) int64 {
+	return int64(parameters[min_number])*60000 + int64(parameters[ms_number])
+}
+
+func SetTimeParams(parameters map[int32]int32, ms_number int32, min_number int32, value_ms int64) {
+	parameters[ms_number] = int32(value_ms % 60000)
+	parameters[min_number] = int32(value_ms / 60000)
+}
+
+func SetIfPresent[T any](target *T, value *T) {
+	if value != nil {
+		*target = *value
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+)
+
+// value types of macro variables
+var macro_types = map[string]string{
+	"float": "float64",
+	"int":   "int32",
+	"bool":  "bool",
+}
+
+// variables per cnc_rdmacror2 call and the gap still read as one range
+const macro_batch = 100
+const macro_max_gap = 10
+
+// variable numbers of the CNC have up to 8 digits
+const max_macro_number = 99999999
+
+// single variable or count variables from number
+type MacroVariable struct {
+	Number int32  `json:"number" yaml:"number"`
+	Count  int32  `json:"count" yaml:"count"`
+	Type   string `json:"type" yaml:"type"`
+}
+
+// field of the macros tag
+type MacroField struct {
+	Number int32
+	Type   string
+}
+
+type MacroRange struct {
+	Start int32
+	End   int32
+}
+
+// OPC UA type of the field value
+func (field MacroField) TagType() string {
+	return macro_types[field.Type]
+}
+
+// variables of the tags pack with device overrides, type defaults to float
+func GetMacroVariables(device *Device) map[string]MacroVariable {
+	variables := maps.Clone(config.Server.Macros[device.TagsPackName])
+	if variables == nil {
+		variables = make(map[string]MacroVariable)
+	}
+	maps.Copy(variables, device.Macros)
+	for name, variable := range variables {
+		if variable.Type == "" {
+			variable.Type = "float"
+			variables[name] = variable
+		}
+	}
+	return variables
+}
+
+// ranges are expanded to name_number fields
+func GetMacroFields(device *Device) map[string]MacroField {
+	fields := make(map[string]MacroField)
+	for name, variable := range GetMacroVariables(device) {
+		if variable.Count <= 1 {
+			fields[name] = MacroField{Number: variable.Number, Type: variable.Type}
+			continue
+		}
+		for number := variable.Number; number < variable.Number+variable.Count; number++ {
+			fields[name+"_"+strconv.Itoa(int(number))] = MacroField{Number: number, Type: variable.Type}
+		}
+	}
+	return fields
+}
+
+// as few ranges as possible, sorted by number
+func GetMacroRanges(fields map[string]MacroField) []MacroRange {
+	var ranges []MacroRange
+	numbers := make([]int32, 0, len(fields))
+	for _, field := range fields {
+		numbers = append(numbers, field.Number)
+	}
+	slices.Sort(numbers)
+	for _, number := range slices.Compact(numbers) {
+		if len(ranges) != 0 {
+			last := &ranges[len(ranges)-1]
+			if number <= last.End+macro_max_gap+1 && number-last.Start < macro_batch {
+				last.End = number
+				continue
+			}
+		}
+		ranges = append(ranges, MacroRange{Start: number, End: number})
+	}
+	return ranges
+}
+
+// value is mantissa / 10^exponent, nil for vacant variable
+func GetMacroValue(field MacroField, value CncMacroValue) any {
+	if value.IsVacant() {
+		return nil
+	}
+	float_value := value.Float()
+	switch field.Type {
+	case "int":
+		return int32(math.Round(float_value))
+	case "bool":
+		return float_value != 0
+	}
+	return float_value
+}
+
+func ReadMacroVariables(client CNCClient, fields map[string]MacroField) (map[string]any, int16) {
+	result := make(map[string]any)
+	for _, macro_range := range GetMacroRanges(fields) {
+		values, ret := client.ReadMacroRange(macro_range.Start, macro_range.End)
+		if ret != 0 {
+			return make(map[string]any), ret
+		}
+		for name, field := range fields {
+			if field.Number < macro_range.Start || field.Number > macro_range.End {
+				continue
+			}
+			index := int(field.Number - macro_range.Start)
+			if index >= len(values) {
+				return make(map[string]any), EW_LENGTH
+			}
+			result[name] = GetMacroValue(field, values[index])
+		}
+	}
+	return result, 0
+}
@@ -0,0 +1,84 @@
+package main
+
+import "fmt"
+
+// names of the modal G code groups, numbering of M series
+var modal_group_names = map[int16]string{
+	1:  "motion",
+	2:  "plane",
+	3:  "distance",
+	4:  "stroke_check",
+	5:  "feed_mode",
+	6:  "units",
+	7:  "cutter_compensation",
+	8:  "length_compensation",
+	9:  "canned_cycle",
+	10: "return_mode",
+	11: "scaling",
+	12: "macro_modal",
+	13: "surface_speed",
+	14: "work_offset",
+	15: "cutting_mode",
+	16: "rotation",
+	17: "polar_coordinates",
+	18: "polar_interpolation",
+	22: "mirror",
+}
+
+// cnc_modal types of the other modal codes
+var modal_code_types = map[string]int16{
+	"d": 100,
+	"h": 102,
+	"m": 104,
+	"s": 110,
+	"t": 111,
+	"f": 118,
+}
+
+// number of G code groups of cnc_rdgcode
+const max_modal_groups = 32
+
+// group is the FANUC group number
+type CncGCode struct {
+	Group int16  `json:"group"`
+	Code  string `json:"code"`
+}
+
+func GetModalGroupName(group int16) string {
+	if name, ok := modal_group_names[group]; ok {
+		return name
+	}
+	return fmt.Sprintf("group_%02d", group)
+}
+
+// group name or code letter -> OPC UA type
+func GetModalFieldTypes() map[string]string {
+	field_types := make(map[string]string)
+	for _, name := range modal_group_names {
+		field_types[name] = "string"
+	}
+	for name := range modal_code_types {
+		field_types[name] = "int32"
+	}
+	return field_types
+}
+
+// G codes by group name and M, S, T, F, H, D codes
+func ReadModal(client CNCClient) (map[string]any, int16) {
+	result := make(map[string]any)
+	g_codes, ret := client.GetGCodes()
+	if ret != 0 {
+		return result, ret
+	}
+	codes, ret := client.GetModalCodes()
+	if ret != 0 {
+		return make(map[string]any), ret
+	}
+	for _, g_code := range g_codes {
+		result[GetModalGroupName(g_code.Group)] = g_code.Code
+	}
+	for name, code := range codes {
+		result[name] = code
+	}
+	return result, 0
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"maps"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -24,16 +25,21 @@ type ImportEndpoint struct {
 }
 
 type Server struct {
-	Status       bool                         `yaml:"status"`
-	Debug        bool                         `yaml:"debug"`
-	MakeCert     bool                         `yaml:"make_cert"`
-	MakeCSV      bool                         `yaml:"make_csv"`
-	AuthModes    []string                     `yaml:"auth_modes"`
-	TrustedCerts []string                     `yaml:"trusted_certs"`
-	TrustedKeys  []string                     `yaml:"trusted_keys"`
-	Endpoints    []ImportEndpoint             `yaml:"endpoints"`
-	Security     map[string]string            `yaml:"security"`
-	TagPacks     map[string]map[string]string `yaml:"tag_packs"`
+	Status       bool                                `yaml:"status"`
+	Debug        bool                                `yaml:"debug"`
+	MakeCert     bool                                `yaml:"make_cert"`
+	MakeCSV      bool                                `yaml:"make_csv"`
+	AuthModes    []string                            `yaml:"auth_modes"`
+	TrustedCerts []string                            `yaml:"trusted_certs"`
+	TrustedKeys  []string                            `yaml:"trusted_keys"`
+	Endpoints    []ImportEndpoint                    `yaml:"endpoints"`
+	Security     map[string]string                   `yaml:"security"`
+	TagPacks     map[string]map[string]string        `yaml:"tag_packs"`
+	TagGroups    map[string]map[string]string        `yaml:"tag_groups"`
+	Pmc          map[string]map[string]PmcSignal     `yaml:"pmc"`
+	Macros       map[string]map[string]MacroVariable `yaml:"macros"`
+	Parameters   map[string]map[string]CncDataItem   `yaml:"parameters"`
+	Diagnostics  map[string]map[string]CncDataItem   `yaml:"diagnostics"`
 }
 
 type Device struct {
@@ -43,6 +49,35 @@ type Device struct {
 	DelayMs      int      `json:"delay_ms" yaml:"delay_ms"`
 	TagsPack     []string `json:"tags_pack" yaml:"tags_pack"`
 	TagsPackName string   `json:"tags_pack_name" yaml:"tags_pack_name"`
+	Protocol     string   `json:"protocol" yaml:"protocol"`
+	Simulate     bool     `json:"simulate" yaml:"simulate"`
+	Scenario     string   `json:"scenario" yaml:"scenario"`
+	Record       bool     `json:"record" yaml:"record"`
+	RecordFile   string   `json:"record_file" yaml:"record_file"`
+	ReplayFile   string   `json:"replay_file" yaml:"replay_file"`
+	ReplaySpeed  float64  `json:"replay_speed" yaml:"replay_speed"`
+	ReplayLoop   bool     `json:"replay_loop" yaml:"replay_loop"`
+	Paths        []int16  `json:"paths" yaml:"paths"`
+	AutoPaths    bool     `json:"auto_paths" yaml:"auto_paths"`
+	TextEncoding string   `json:"text_encoding" yaml:"text_encoding"`
+	// folder of cnc_rdpdf_alldir, empty - numbered programs of cnc_rdprogdir3
+	ProgramFolder string `json:"program_folder" yaml:"program_folder"`
+	// blocks before and after the executing block of exec_window, 0 - 3 blocks
+	ExecWindow int `json:"exec_window" yaml:"exec_window"`
+	// program backup, nil - disabled
+	Backup *BackupConfig `json:"backup" yaml:"backup"`
+	// tag polling groups
+	PollIntervals map[string]int    `json:"poll_intervals" yaml:"poll_intervals"`
+	TagGroups     map[string]string `json:"tag_groups" yaml:"tag_groups"`
+	// named PMC signals
+	Pmc map[string]PmcSignal `json:"pmc" yaml:"pmc"`
+	// named custom macro variables
+	Macros map[string]MacroVariable `json:"macros" yaml:"macros"`
+	// named parameters and diagnosis data
+	Parameters  map[string]CncDataItem `json:"parameters" yaml:"parameters"`
+	Diagnostics map[string]CncDataItem `json:"diagnostics" yaml:"diagnostics"`
+	// diagnosis numbers of drive_health, nil - defaults
+	DriveHealth *DriveHealthConfig `json:"drive_health" yaml:"drive_health"`
 }
 
 type Config struct {
@@ -61,12 +96,16 @@ var log_file *os.File
 
 var running = true
 var handles []uint16
+var simulators []*Simulator
 
 func EndPlugin() {
 	logger.Println("Завершение плагина")
 	running = false
 	time.Sleep(time.Duration(3) * time.Second)
 	FreeAllHandles(handles)
+	for _, simulator := range simulators {
+		simulator.Close()
+	}
 }
 
 func InitCrashLog() {
@@ -106,6 +145,10 @@ func OutputFanucData(json_data string) {
 }
 
 func main() {
+	// program archive commands, stdout is the command output
+	if len(os.Args) > 1 && os.Args[1] == "backup" {
+		os.Exit(RunBackupCommand(os.Args[2:]))
+	}
 	multi_writer := io.MultiWriter(os.Stdout, &log_buf)
 	logger = log.New(multi_writer, "Plugin: ", log.Ldate|log.Ltime|log.Lshortfile)
 	logger.Println("Запуск плагина")
@@ -126,6 +169,11 @@ func main() {
 	if err != nil {
 		logger.Panicf("Ошибка чтения plugin.conf (yaml) %v", err)
 	}
+	for index := range config.Devices {
+		if config.Devices[index].ReplaySpeed == 0 {
+			config.Devices[index].ReplaySpeed = 1
+		}
+	}
 
 	if config.Logfile {
 		log_path := filepath.Join(plugin_dir, "plugin.log")
@@ -152,11 +200,76 @@ func main() {
 		if slices.Contains(device_names, device.Name) {
 			logger.Panicf("Устройство с именем %s уже существует", device.Name)
 		}
-		if slices.Contains(device_addresses, device.Address) {
+		device_address := FormatAddress(device.Address, device.Port)
+		if slices.Contains(device_addresses, device_address) {
 			logger.Panicf("Устройство с адресом %s уже существует", device.Name)
 		}
+		if device.Protocol != "" && !slices.Contains(available_protocols, device.Protocol) {
+			logger.Panicf("Устройство %s: неизвестный протокол %s", device.Name, device.Protocol)
+		}
+		if device.Simulate && device.Protocol == "fwlib" {
+			logger.Panicf("Устройство %s: симулятор поддерживает только протокол native", device.Name)
+		}
+		if device.Protocol == "replay" && device.ReplayFile == "" {
+			logger.Panicf("Устройство %s: не указан replay_file", device.Name)
+		}
+		if slices.ContainsFunc(device.Paths, func(path int16) bool { return path < 1 }) {
+			logger.Panicf("Устройство %s: некорректный номер канала в paths", device.Name)
+		}
+		for name, signal := range GetPmcSignals(&device) {
+			if _, ok := pmc_areas[signal.Area]; !ok {
+				logger.Panicf("Устройство %s: неизвестная область PMC %s сигнала %s", device.Name, signal.Area, name)
+			}
+			if _, ok := pmc_types[signal.Type]; !ok {
+				logger.Panicf("Устройство %s: неизвестный тип %s сигнала PMC %s", device.Name, signal.Type, name)
+			}
+			if signal.Bit < 0 || signal.Bit > 7 {
+				logger.Panicf("Устройство %s: некорректный бит сигнала PMC %s", device.Name, name)
+			}
+		}
+		for name, variable := range GetMacroVariables(&device) {
+			if variable.Number < 1 || variable.Count < 0 || int64(variable.Number)+int64(max(variable.Count, 1))-1 > max_macro_number {
+				logger.Panicf("Устройство %s: некорректный номер макропеременной %s", device.Name, name)
+			}
+			if _, ok := macro_types[variable.Type]; !ok {
+				logger.Panicf("Устройство %s: неизвестный тип %s макропеременной %s", device.Name, variable.Type, name)
+			}
+		}
+		cnc_data_items := GetParameterItems(&device)
+		maps.Copy(cnc_data_items, GetDiagnosticItems(&device))
+		for name, item := range cnc_data_items {
+			if item.Number < 0 || item.Axis < 0 {
+				logger.Panicf("Устройство %s: некорректный номер или ось %s", device.Name, name)
+			}
+			if _, ok := cnc_data_types[item.Type]; !ok {
+				logger.Panicf("Устройство %s: неизвестный тип %s данных %s", device.Name, item.Type, name)
+			}
+			if item.Bit < 0 || item.Bit > 7 {
+				logger.Panicf("Устройство %s: некорректный бит данных %s", device.Name, name)
+			}
+		}
+		if device.TextEncoding != "" && !slices.Contains(available_text_encodings, device.TextEncoding) {
+			logger.Panicf("Устройство %s: неизвестная кодировка %s", device.Name, device.TextEncoding)
+		}
+		if _, ok := device.PollIntervals[once_group]; ok {
+			logger.Panicf("Устройство %s: группа %s не имеет интервала опроса", device.Name, once_group)
+		}
+		for group, interval := range device.PollIntervals {
+			if interval <= 0 {
+				logger.Panicf("Устройство %s: некорректный интервал опроса %d группы %s", device.Name, interval, group)
+			}
+		}
+		poll_intervals := GetPollIntervals(&device)
+		for tag, group := range GetTagGroups(&device) {
+			if _, ok := poll_intervals[group]; !ok && group != once_group {
+				logger.Panicf("Устройство %s: неизвестная группа опроса %s тега %s", device.Name, group, tag)
+			}
+		}
+		if GetDeviceProtocol(&device) == "fwlib" && !fwlib_available {
+			logger.Panicf("Устройство %s: протокол fwlib недоступен в данной сборке, используйте protocol: \"native\"", device.Name)
+		}
 		device_names = append(device_names, device.Name)
-		device_addresses = append(device_addresses, device.Address)
+		device_addresses = append(device_addresses, device_address)
 	}
 
 	if config.Server.Status {
@@ -165,6 +278,17 @@ func main() {
 		go StartServer()
 	}
 
+	for index := range config.Devices {
+		if !config.Devices[index].Simulate {
+			continue
+		}
+		simulator, err := StartSimulator(&config.Devices[index])
+		if err != nil {
+			logger.Panicf("Ошибка запуска симулятора %s: %v", config.Devices[index].Name, err)
+		}
+		simulators = append(simulators, simulator)
+	}
+
 	go TryFreeExtraHandles(plugin_dir)
 
 	var wait_group sync.WaitGroup
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/binary"
+	"maps"
+	"math"
+	"slices"
+)
+
+// pmc_rdpmcrng address types
+var pmc_areas = map[string]int16{
+	"G": 0, "F": 1, "Y": 2, "X": 3, "A": 4, "R": 5, "T": 6,
+	"K": 7, "C": 8, "D": 9, "M": 10, "N": 11, "E": 12, "Z": 13,
+}
+
+// signal width in bytes
+var pmc_types = map[string]uint16{
+	"bit":   1,
+	"byte":  1,
+	"word":  2,
+	"dword": 4,
+	"float": 4,
+}
+
+// bytes per pmc_rdpmcrng call and the gap still read as one range
+const pmc_max_range = 200
+const pmc_max_gap = 16
+
+type PmcSignal struct {
+	Area    string `json:"area" yaml:"area"`
+	Address uint16 `json:"address" yaml:"address"`
+	Bit     int16  `json:"bit" yaml:"bit"`
+	Type    string `json:"type" yaml:"type"`
+}
+
+type PmcRange struct {
+	Area  string
+	Start uint16
+	End   uint16
+}
+
+func (signal PmcSignal) Width() uint16 {
+	return pmc_types[signal.Type]
+}
+
+// OPC UA type of the signal value
+func (signal PmcSignal) TagType() string {
+	switch signal.Type {
+	case "bit":
+		return "bool"
+	case "byte", "word":
+		return "int16"
+	case "dword":
+		return "int32"
+	case "float":
+		return "float64"
+	}
+	return ""
+}
+
+// signals of the tags pack with device overrides, type defaults to byte
+func GetPmcSignals(device *Device) map[string]PmcSignal {
+	signals := maps.Clone(config.Server.Pmc[device.TagsPackName])
+	if signals == nil {
+		signals = make(map[string]PmcSignal)
+	}
+	maps.Copy(signals, device.Pmc)
+	for name, signal := range signals {
+		if signal.Type == "" {
+			signal.Type = "byte"
+			signals[name] = signal
+		}
+	}
+	return signals
+}
+
+// as few byte ranges as possible, sorted by area and address
+func GetPmcRanges(signals map[string]PmcSignal) []PmcRange {
+	var ranges []PmcRange
+	sorted := slices.Collect(maps.Values(signals))
+	slices.SortFunc(sorted, func(a, b PmcSignal) int {
+		if a.Area != b.Area {
+			return int(pmc_areas[a.Area]) - int(pmc_areas[b.Area])
+		}
+		return int(a.Address) - int(b.Address)
+	})
+	for _, signal := range sorted {
+		end := signal.Address + signal.Width() - 1
+		if len(ranges) != 0 {
+			last := &ranges[len(ranges)-1]
+			if last.Area == signal.Area && int(signal.Address) <= int(last.End)+pmc_max_gap+1 && int(max(end, last.End))-int(last.Start) < pmc_max_range {
+				last.End = max(end, last.End)
+				continue
+			}
+		}
+		ranges = append(ranges, PmcRange{Area: signal.Area, Start: signal.Address, End: end})
+	}
+	return ranges
+}
+
+func GetPmcValue(signal PmcSignal, data []byte) any {
+	switch signal.Type {
+	case "bit":
+		return data[0]>>signal.Bit&1 == 1
+	case "byte":
+		return int16(data[0])
+	case "word":
+		return int16(binary.LittleEndian.Uint16(data))
+	case "dword":
+		return int32(binary.LittleEndian.Uint32(data))
+	case "float":
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(data)))
+	}
+	return nil
+}
+
+func ReadPmcSignals(client CNCClient, signals map[string]PmcSignal) (map[string]any, int16) {
+	result := make(map[string]any)
+	for _, pmc_range := range GetPmcRanges(signals) {
+		data, ret := client.ReadPmcRange(pmc_areas[pmc_range.Area], pmc_range.Start, pmc_range.End)
+		if ret != 0 {
+			return make(map[string]any), ret
+		}
+		for name, signal := range signals {
+			if signal.Area != pmc_range.Area || signal.Address < pmc_range.Start || signal.Address > pmc_range.End {
+				continue
+			}
+			offset := int(signal.Address - pmc_range.Start)
+			if offset+int(signal.Width()) > len(data) {
+				return make(map[string]any), EW_LENGTH
+			}
+			result[name] = GetPmcValue(signal, data[offset:])
+		}
+	}
+	return result, 0
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// programs per cnc_rdprogdir3 and cnc_rdpdf_alldir call
+const program_dir_batch = 10
+
+// cnc_upload4 returns EW_BUFFER until the CNC has prepared the data, 5 s at most
+const upload_buffer_attempts = 500
+const upload_buffer_delay = 10 * time.Millisecond
+
+// entry of the program directory, modified is "2006-01-02 15:04"
+type CncProgram struct {
+	Number   int32  `json:"number"`
+	Name     string `json:"name"`
+	Comment  string `json:"comment"`
+	Size     int32  `json:"size"`
+	Modified string `json:"modified"`
+}
+
+// program_events tag item, event is added, deleted or modified
+type ProgramEvent struct {
+	Event    string `json:"event"`
+	Number   int32  `json:"number"`
+	Name     string `json:"name"`
+	Modified string `json:"modified"`
+}
+
+var program_event_names = map[string]string{
+	"added":    "добавлена",
+	"deleted":  "удалена",
+	"modified": "изменена",
+}
+
+// last listed directory of every device scope, kept between reconnects
+type ProgramDirectories struct {
+	mutex       sync.Mutex
+	directories map[string]map[string]CncProgram
+}
+
+var program_directories = ProgramDirectories{
+	directories: make(map[string]map[string]CncProgram),
+}
+
+// changes since the previous listing, the first listing has no events
+func (directories *ProgramDirectories) Update(scope_name string, programs []CncProgram) []ProgramEvent {
+	directories.mutex.Lock()
+	defer directories.mutex.Unlock()
+	events := make([]ProgramEvent, 0)
+	current := make(map[string]CncProgram)
+	for _, program := range programs {
+		current[program.Name] = program
+	}
+	previous, ok := directories.directories[scope_name]
+	directories.directories[scope_name] = current
+	if !ok {
+		return events
+	}
+	for _, name := range slices.Sorted(maps.Keys(current)) {
+		program := current[name]
+		old_program, ok := previous[name]
+		if !ok {
+			events = append(events, NewProgramEvent("added", program))
+		} else if program != old_program {
+			events = append(events, NewProgramEvent("modified", program))
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(previous)) {
+		if _, ok := current[name]; !ok {
+			events = append(events, NewProgramEvent("deleted", previous[name]))
+		}
+	}
+	for _, event := range events {
+		logger.Printf("Программа %s %s: %s", program_event_names[event.Event], scope_name, event.Name)
+	}
+	return events
+}
+
+// O0001 - O9999 with 4 digits, 8-digit program numbers as they are
+func FormatProgramName(number int32) string {
+	if number < 10000 {
+		return fmt.Sprintf("O%04d", number)
+	}
+	return fmt.Sprintf("O%d", number)
+}
+
+// empty for programs without date
+func FormatProgramDate(year, month, day, hour, minute int16) string {
+	if year == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%04d-%02d-%02d %02d:%02d", year, month, day, hour, minute)
+}
+
+func NewProgramEvent(event string, program CncProgram) ProgramEvent {
+	return ProgramEvent{Event: event, Number: program.Number, Name: program.Name, Modified: program.Modified}
+}
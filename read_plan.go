@@ -0,0 +1,276 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// result of a FOCAS call shared by several tags
+type CachedCall[T any] struct {
+	done  bool
+	value T
+	err   int16
+}
+
+func (call *CachedCall[T]) Get(read func() (T, int16)) (T, int16) {
+	if !call.done {
+		call.value, call.err = read()
+		call.done = true
+	}
+	return call.value, call.err
+}
+
+// per cycle read plan, tags of the same FOCAS call are derived from one buffer
+type ReadPlan struct {
+	client           CNCClient
+	scope_key        string
+	text_encoding    string
+	program_folder   string
+	exec_window      int
+	pmc_signals      map[string]PmcSignal
+	macro_fields     map[string]MacroField
+	parameters       map[string]CncDataItem
+	diagnostics      map[string]CncDataItem
+	servo_health     map[string]CncDataItem
+	spindle_health   map[string]CncDataItem
+	stat_info        CachedCall[CncStatInfo]
+	exec_blocks      CachedCall[CncExecBlocks]
+	program_path     CachedCall[string]
+	programs         CachedCall[[]CncProgram]
+	tool_offset_info CachedCall[CncToolOffsetInfo]
+	tool_offsets     CachedCall[map[string]map[string]float64]
+	tool_number      CachedCall[int64]
+	active_offsets   CachedCall[map[string]float64]
+	work_offsets     CachedCall[map[string]map[string]float64]
+	frame_number     CachedCall[int64]
+	// ms and minute parameters -> values, only the parameters of the polled tags are read
+	timer_params map[[2]int32]*CachedCall[map[int32]int64]
+}
+
+// scope is the path number of multi-path CNC
+func NewReadPlan(device *Device, client CNCClient, scope string) *ReadPlan {
+	return &ReadPlan{
+		client:         client,
+		scope_key:      GetScopeKey(device, scope),
+		text_encoding:  device.TextEncoding,
+		program_folder: device.ProgramFolder,
+		exec_window:    GetExecWindowSize(device),
+		pmc_signals:    GetPmcSignals(device),
+		macro_fields:   GetMacroFields(device),
+		parameters:     GetParameterItems(device),
+		diagnostics:    GetDiagnosticItems(device),
+		servo_health:   GetServoHealthItems(device),
+		spindle_health: GetSpindleHealthItems(device),
+		timer_params:   make(map[[2]int32]*CachedCall[map[int32]int64]),
+	}
+}
+
+func (plan *ReadPlan) StatInfo() (CncStatInfo, int16) {
+	return plan.stat_info.Get(plan.client.GetStatInfo)
+}
+
+// cnc_rdexecprog buffer of the program, frame, shutdowns and exec window tags
+func (plan *ReadPlan) ExecBlocks() (CncExecBlocks, int16) {
+	return plan.exec_blocks.Get(plan.client.GetExecBlocks)
+}
+
+func (plan *ReadPlan) ExecProgram() (string, int16) {
+	blocks, ret := plan.ExecBlocks()
+	return blocks.Text, ret
+}
+
+func (plan *ReadPlan) ProgramPath() (string, int16) {
+	return plan.program_path.Get(plan.client.GetProgramPath)
+}
+
+// last part of the program path
+func (plan *ReadPlan) ProgramName() (string, int16) {
+	program_path, ret := plan.ProgramPath()
+	if ret != 0 {
+		return "", ret
+	}
+	return program_path[strings.LastIndex(program_path, "/")+1:], 0
+}
+
+func (plan *ReadPlan) Programs() ([]CncProgram, int16) {
+	return plan.programs.Get(func() ([]CncProgram, int16) {
+		return plan.client.GetProgramDirectory(plan.program_folder)
+	})
+}
+
+// programs added, deleted or modified since the previous listing
+func (plan *ReadPlan) ProgramEvents() ([]ProgramEvent, int16) {
+	programs, ret := plan.Programs()
+	if ret != 0 {
+		return make([]ProgramEvent, 0), ret
+	}
+	return program_directories.Update(plan.scope_key, programs), 0
+}
+
+func (plan *ReadPlan) ToolOffsetInfo() (CncToolOffsetInfo, int16) {
+	return plan.tool_offset_info.Get(plan.client.GetToolOffsetInfo)
+}
+
+// offset number -> wear and geometry offsets
+func (plan *ReadPlan) ToolOffsets() (map[string]map[string]float64, int16) {
+	return plan.tool_offsets.Get(func() (map[string]map[string]float64, int16) {
+		info, ret := plan.ToolOffsetInfo()
+		if ret != 0 {
+			return make(map[string]map[string]float64), ret
+		}
+		return ReadToolOffsets(plan.client, info)
+	})
+}
+
+// offsets changed since the previous read of the tag
+func (plan *ReadPlan) ToolOffsetChanges() (map[string]map[string]float64, int16) {
+	table, ret := plan.ToolOffsets()
+	if ret != 0 {
+		return table, ret
+	}
+	return tool_offset_tables.Changes(plan.scope_key, table), 0
+}
+
+// offset -> axis -> value
+func (plan *ReadPlan) WorkOffsets() (map[string]map[string]float64, int16) {
+	return plan.work_offsets.Get(func() (map[string]map[string]float64, int16) {
+		return ReadWorkOffsets(plan.client)
+	})
+}
+
+// changed values since the previous read of the tag
+func (plan *ReadPlan) WorkOffsetEvents() ([]WorkOffsetEvent, int16) {
+	table, ret := plan.WorkOffsets()
+	if ret != 0 {
+		return make([]WorkOffsetEvent, 0), ret
+	}
+	return work_offset_tables.Update(plan.scope_key, table), 0
+}
+
+func (plan *ReadPlan) ToolNumber() (int64, int16) {
+	return plan.tool_number.Get(plan.client.GetToolNumber)
+}
+
+// offsets of one offset number, taken from the table when it is already read in the cycle
+func (plan *ReadPlan) ToolOffsetValues(info CncToolOffsetInfo, offset_number int64) (map[string]float64, int16) {
+	return plan.active_offsets.Get(func() (map[string]float64, int16) {
+		if plan.tool_offsets.done && plan.tool_offsets.err == 0 {
+			return plan.tool_offsets.value[strconv.FormatInt(offset_number, 10)], 0
+		}
+		result := make(map[string]float64)
+		for _, field := range GetToolOffsetFields(info) {
+			values, ret := plan.client.GetToolOffsets(field.Type, int16(offset_number), int16(offset_number))
+			if ret != 0 {
+				return make(map[string]float64), ret
+			}
+			if len(values) != 0 {
+				result[field.Name] = float64(values[0]) / math.Pow10(tool_offset_decimals)
+			}
+		}
+		return result, 0
+	})
+}
+
+// offsets of the active tool with tool and offset numbers
+func (plan *ReadPlan) ActiveToolOffsets() (map[string]any, int16) {
+	result := make(map[string]any)
+	tool_number, ret := plan.ToolNumber()
+	if ret != 0 {
+		return result, ret
+	}
+	info, ret := plan.ToolOffsetInfo()
+	if ret != 0 {
+		return result, ret
+	}
+	offset_number := GetToolOffsetNumber(info, tool_number)
+	result["tool"] = tool_number
+	result["offset_number"] = offset_number
+	if offset_number < 1 || offset_number > int64(info.Count) {
+		return result, 0
+	}
+	offsets, ret := plan.ToolOffsetValues(info, offset_number)
+	if ret != 0 {
+		return make(map[string]any), ret
+	}
+	for name, value := range offsets {
+		result[name] = value
+	}
+	return result, 0
+}
+
+func (plan *ReadPlan) FrameNumber() (int64, int16) {
+	return plan.frame_number.Get(plan.client.GetFrameNumber)
+}
+
+func (plan *ReadPlan) TimerParams(ms_number int32, min_number int32) (map[int32]int64, int16) {
+	key := [2]int32{ms_number, min_number}
+	call, ok := plan.timer_params[key]
+	if !ok {
+		call = &CachedCall[map[int32]int64]{}
+		plan.timer_params[key] = call
+	}
+	return call.Get(func() (map[int32]int64, int16) {
+		return plan.client.GetTimerParams(slices.Compact([]int32{ms_number, min_number}))
+	})
+}
+
+func (plan *ReadPlan) Frame() (string, int16) {
+	program, ret := plan.ExecProgram()
+	if ret != 0 {
+		return "", ret
+	}
+	frame_number, frame_number_error := plan.FrameNumber()
+	return ParseFrame(program, frame_number, frame_number_error), 0
+}
+
+// blocks around the executing block
+func (plan *ReadPlan) ExecWindow() ([]ExecBlock, int16) {
+	blocks, ret := plan.ExecBlocks()
+	if ret != 0 {
+		return make([]ExecBlock, 0), ret
+	}
+	return exec_histories.Window(plan.scope_key, blocks, plan.exec_window), 0
+}
+
+func (plan *ReadPlan) Shutdowns() (int16, int16) {
+	program, ret := plan.ExecProgram()
+	if ret != 0 {
+		return 0, ret
+	}
+	return ParseShutdowns(program), 0
+}
+
+func (plan *ReadPlan) OperatorMessages() ([]OperatorMessage, int16) {
+	result := make([]OperatorMessage, 0)
+	messages, ret := plan.client.GetOperatorMessages()
+	if ret != 0 {
+		return result, ret
+	}
+	for _, message := range messages {
+		result = append(result, OperatorMessage{
+			Number:  message.Number,
+			Type:    message.Type,
+			Message: DecodeCncText(message.Data, plan.text_encoding),
+		})
+	}
+	return result, 0
+}
+
+// power on time has minutes only
+func (plan *ReadPlan) PowerOnTime() (int64, int16) {
+	params, ret := plan.TimerParams(6750, 6750)
+	if ret != 0 {
+		return 0, ret
+	}
+	return params[6750], 0
+}
+
+func (plan *ReadPlan) TimeParams(ms_number int32, min_number int32) (float64, int16) {
+	params, ret := plan.TimerParams(ms_number, min_number)
+	if ret != 0 {
+		return 0, ret
+	}
+	return JoinTimeParams(params[ms_number], params[min_number]), 0
+}
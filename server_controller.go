@@ -12,6 +12,7 @@ import (
 	"path/filepath"
 	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gopcua/opcua/debug"
@@ -50,6 +51,7 @@ var available_auth_modes = []string{
 var _server *server.Server
 var fanuc_ns = int(1)
 var device_map map[string]string
+var nodes_mutex sync.Mutex
 
 type Logger int
 
@@ -107,19 +109,24 @@ func GetDeviceNodes(device_name string) []*server.Node {
 		return result
 	}
 	var tags_pack_name string
+	var paths []int16
 	for _, device := range config.Devices {
 		if device_name == device.Name {
 			tags_pack_name = device.TagsPackName
+			paths = device.Paths
 			break
 		}
 	}
 	if tags_pack, ok := config.Server.TagPacks[tags_pack_name]; ok {
 		node_ns := GetNodeNamespace(_server, fanuc_ns)
 		if node_ns != nil {
-			for tag_name := range tags_pack {
-				node := GetNodeAtAddress(node_ns, device_map[device_name]+"/"+tag_name)
-				if node != nil {
-					result = append(result, node)
+			for tag_name, tag_type := range tags_pack {
+				result = append(result, GetTagNodes(node_ns, device_map[device_name]+"/"+tag_name, tag_type)...)
+			}
+			for _, path := range paths {
+				for tag_name, tag_type := range tags_pack {
+					path_address := device_map[device_name] + "/" + GetPathFolderName(GetPathKey(path))
+					result = append(result, GetTagNodes(node_ns, path_address+"/"+tag_name, tag_type)...)
 				}
 			}
 		}
@@ -144,29 +151,72 @@ func UpdateCollector(json_data string) {
 		logger.Println("(Update node value) устройство отсутсвует:", device_name)
 		return
 	}
-	var tag_sliced []string
-	var converted_value any
-	var tags_pack_name string
+	var device *Device
 	for index := range config.Devices {
 		if device_name == config.Devices[index].Name {
-			tags_pack_name = config.Devices[index].TagsPackName
+			device = &config.Devices[index]
 			break
 		}
 	}
-	for tag_name, tag_type := range config.Server.TagPacks[tags_pack_name] {
+	tags_pack := config.Server.TagPacks[device.TagsPackName]
+	UpdateTagNodes(node_ns, device_address, decode_data, tags_pack)
+	for tag_name, tag_type := range tags_pack {
+		if field_types := GetFieldTypes(device, tag_type); field_types != nil {
+			UpdateFieldNodes(node_ns, device_address+"/"+tag_name, decode_data[tag_name], field_types)
+		}
+	}
+	paths_data, ok := decode_data["paths"].(map[string]any)
+	if !ok {
+		return
+	}
+	for path_key, path_data := range paths_data {
+		path_map, ok := path_data.(map[string]any)
+		if !ok {
+			continue
+		}
+		path_address := device_address + "/" + GetPathFolderName(path_key)
+		if GetNodeAtAddress(node_ns, path_address) == nil {
+			// auto discovered path
+			CreatePathNodes(node_ns, device, device_address, path_key)
+		}
+		UpdateTagNodes(node_ns, path_address, path_map, tags_pack)
+		for tag_name, tag_type := range tags_pack {
+			if field_types := GetFieldTypes(device, tag_type); field_types != nil && IsPathTag(tag_name) {
+				UpdateFieldNodes(node_ns, path_address+"/"+tag_name, path_map[tag_name], field_types)
+			}
+		}
+	}
+}
+
+func UpdateTagNodes(node_ns *server.NodeNameSpace, base_address string, data map[string]any, tags_pack map[string]string) {
+	var tag_sliced []string
+	var converted_value any
+	for tag_name, tag_type := range tags_pack {
 		tag_sliced = GetStrSliceByDot(tag_name)
 		switch len(tag_sliced) {
 		case 1:
-			converted_value = ConvertValueByType(decode_data[tag_sliced[0]], tag_type)
+			if fields, ok := folder_tag_types[tag_type]; ok {
+				UpdateFolderTagNodes(node_ns, base_address+"/"+tag_name, data[tag_name], fields)
+				continue
+			}
+			if value_type, ok := nested_tag_types[tag_type]; ok {
+				UpdateNestedTagNodes(node_ns, base_address+"/"+tag_name, data[tag_name], value_type)
+				continue
+			}
+			converted_value = ConvertValueByType(data[tag_sliced[0]], tag_type)
 		case 2:
-			converted_value = ConvertMapValueAtKey(tag_sliced[1], decode_data[tag_sliced[0]], tag_type)
+			if IsWildcardTag(tag_sliced) {
+				UpdateWildcardNodes(node_ns, base_address, tag_sliced[0], data[tag_sliced[0]], tag_type)
+				continue
+			}
+			converted_value = ConvertMapValueAtKey(tag_sliced[1], data[tag_sliced[0]], tag_type)
 		default:
 			continue
 		}
 		if converted_value == nil {
 			continue
 		}
-		UpdateNodeValueAtAddress(node_ns, device_address+"/"+tag_name, converted_value)
+		UpdateNodeValueAtAddress(node_ns, base_address+"/"+tag_name, converted_value)
 	}
 }
 
@@ -181,12 +231,197 @@ func CreateDeviceNodes(devices []Device, node_ns *server.NodeNameSpace) {
 			pack_tags := config.Server.TagPacks[tags_pack]
 			for tag_name, tag_type := range pack_tags {
 				tag_info = GetStrSliceByDot(tag_name)
-				if len(tag_info) <= 2 {
-					AddVariableNode(node_ns, device_folder, tag_name, GetZeroValueByTagType(tag_type))
+				if field_types := GetFieldTypes(&device, tag_type); field_types != nil {
+					CreateFieldNodes(node_ns, device_folder, tag_name, field_types)
+				} else if len(tag_info) <= 2 && !IsWildcardTag(tag_info) {
+					AddTagNode(node_ns, device_folder, tag_name, tag_type)
 				}
 			}
 		}
 		device_map[device.Name] = device_folder.ID().String()
+		for _, path := range device.Paths {
+			CreatePathNodes(node_ns, &device, device_map[device.Name], GetPathKey(path))
+		}
+	}
+}
+
+// variable node of the tag or field nodes of the folder tag
+func GetTagNodes(node_ns *server.NodeNameSpace, address string, tag_type string) []*server.Node {
+	var result []*server.Node
+	addresses := []string{address}
+	if fields, ok := folder_tag_types[tag_type]; ok {
+		addresses = addresses[:0]
+		for _, field := range fields {
+			addresses = append(addresses, address+"/"+field.Name)
+		}
+	}
+	for _, node_address := range addresses {
+		if node := GetNodeAtAddress(node_ns, node_address); node != nil {
+			result = append(result, node)
+		}
+	}
+	return result
+}
+
+func IsWildcardTag(tag_sliced []string) bool {
+	return len(tag_sliced) == 2 && tag_sliced[1] == "*"
+}
+
+func UpdateWildcardNodes(node_ns *server.NodeNameSpace, base_address string, tag string, map_data any, tag_type string) {
+	values, ok := map_data.(map[string]any)
+	if !ok {
+		return
+	}
+	for key := range values {
+		converted_value := ConvertMapValueAtKey(key, map_data, tag_type)
+		if converted_value == nil {
+			continue
+		}
+		UpdateNodeValueAtAddress(node_ns, base_address+"/"+tag+"."+strings.ToLower(key), converted_value)
+	}
+}
+
+// one variable per discovered axis or spindle for tags like absolute_positions.*
+func CreateAxisNodes(device_name string, path_key string, axes []string, spindles []string) {
+	node_ns := GetNodeNamespace(_server, fanuc_ns)
+	if node_ns == nil {
+		return
+	}
+	device_address, exists := device_map[device_name]
+	if !exists {
+		return
+	}
+	var device *Device
+	for index := range config.Devices {
+		if config.Devices[index].Name == device_name {
+			device = &config.Devices[index]
+			break
+		}
+	}
+	if device == nil {
+		return
+	}
+	tags_pack := config.Server.TagPacks[device.TagsPackName]
+	folder_address := device_address
+	if path_key != "" {
+		CreatePathNodes(node_ns, device, device_address, path_key)
+		folder_address += "/" + GetPathFolderName(path_key)
+	}
+	nodes_mutex.Lock()
+	defer nodes_mutex.Unlock()
+	folder := GetNodeAtAddress(node_ns, folder_address)
+	if folder == nil {
+		return
+	}
+	for tag_name, tag_type := range tags_pack {
+		tag_info := GetStrSliceByDot(tag_name)
+		if !IsWildcardTag(tag_info) || (path_key != "" && !IsPathTag(tag_info[0])) {
+			continue
+		}
+		names := axes
+		if IsSpindleTag(tag_info[0]) {
+			names = spindles
+		}
+		for _, name := range names {
+			node_name := tag_info[0] + "." + strings.ToLower(name)
+			if GetNodeAtAddress(node_ns, folder_address+"/"+node_name) == nil {
+				AddVariableNode(node_ns, folder, node_name, GetZeroValueByTagType(tag_type))
+			}
+		}
+	}
+}
+
+func HasWildcardTags(tags_pack_name string) bool {
+	for tag_name := range config.Server.TagPacks[tags_pack_name] {
+		if IsWildcardTag(GetStrSliceByDot(tag_name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// field name -> OPC UA type of the configured fields tag, nil for other tag types
+func GetFieldTypes(device *Device, tag_type string) map[string]string {
+	field_types := make(map[string]string)
+	switch tag_type {
+	case "pmc":
+		for name, signal := range GetPmcSignals(device) {
+			field_types[name] = signal.TagType()
+		}
+	case "macros":
+		for name, field := range GetMacroFields(device) {
+			field_types[name] = field.TagType()
+		}
+	case "modal":
+		field_types = GetModalFieldTypes()
+	case "active_tool_offsets":
+		field_types = GetToolOffsetFieldTypes()
+	case "timers":
+		field_types = GetTimerFieldTypes()
+	case "parameters":
+		for name, item := range GetParameterItems(device) {
+			field_types[name] = item.TagType()
+		}
+	case "diagnostics":
+		for name, item := range GetDiagnosticItems(device) {
+			field_types[name] = item.TagType()
+		}
+	default:
+		return nil
+	}
+	return field_types
+}
+
+// folder with a typed variable per field
+func CreateFieldNodes(node_ns *server.NodeNameSpace, device_folder *server.Node, tag_name string, field_types map[string]string) {
+	folder := GetFolderNode(node_ns, device_folder, tag_name)
+	for name, field_type := range field_types {
+		AddVariableNode(node_ns, folder, name, GetZeroValueByTagType(field_type))
+	}
+}
+
+// vacant values keep the last value of the node
+func UpdateFieldNodes(node_ns *server.NodeNameSpace, address string, values any, field_types map[string]string) {
+	values_map, ok := values.(map[string]any)
+	if !ok {
+		return
+	}
+	for name, value := range values_map {
+		field_type, ok := field_types[name]
+		if !ok {
+			continue
+		}
+		if converted_value := ConvertValueByType(value, field_type); converted_value != nil {
+			UpdateNodeValueAtAddress(node_ns, address+"/"+name, converted_value)
+		}
+	}
+}
+
+func GetPathFolderName(path_key string) string {
+	return "path_" + path_key
+}
+
+func CreatePathNodes(node_ns *server.NodeNameSpace, device *Device, device_address string, path_key string) {
+	nodes_mutex.Lock()
+	defer nodes_mutex.Unlock()
+	if GetNodeAtAddress(node_ns, device_address+"/"+GetPathFolderName(path_key)) != nil {
+		return
+	}
+	device_folder := GetNodeAtAddress(node_ns, device_address)
+	if device_folder == nil {
+		return
+	}
+	path_folder := GetFolderNode(node_ns, device_folder, GetPathFolderName(path_key))
+	for tag_name, tag_type := range config.Server.TagPacks[device.TagsPackName] {
+		tag_info := GetStrSliceByDot(tag_name)
+		if len(tag_info) > 2 || !IsPathTag(tag_info[0]) || IsWildcardTag(tag_info) {
+			continue
+		}
+		if field_types := GetFieldTypes(device, tag_type); field_types != nil {
+			CreateFieldNodes(node_ns, path_folder, tag_name, field_types)
+		} else {
+			AddTagNode(node_ns, path_folder, tag_name, tag_type)
+		}
 	}
 }
 
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gopcua/opcua/server"
+)
+
+func UseTestServer(t *testing.T, devices []Device, tag_packs map[string]map[string]string) *server.NodeNameSpace {
+	t.Helper()
+	saved_config, saved_server, saved_map := config, _server, device_map
+	t.Cleanup(func() {
+		config, _server, device_map = saved_config, saved_server, saved_map
+	})
+	config = Config{Devices: devices, Server: Server{TagPacks: tag_packs}}
+	_server = server.New()
+	// value changes are not notified without subscriptions of a started server
+	_server.MonitoredItemService = &server.MonitoredItemService{}
+	node_ns := server.NewNodeNameSpace(_server, "Fanuc Devices")
+	CreateDeviceNodes(config.Devices, node_ns)
+	return node_ns
+}
+
+func NodeValue(node_ns *server.NodeNameSpace, address string) any {
+	node := GetNodeAtAddress(node_ns, address)
+	if node == nil {
+		return nil
+	}
+	return node.Value().Value.Value()
+}
+
+func TestPathFieldNodes(t *testing.T) {
+	device := NewTestDevice()
+	device.TagsPackName = "pack"
+	device.Paths = []int16{1, 2}
+	node_ns := UseTestServer(t, []Device{device}, map[string]map[string]string{
+		"pack": {"modal": "modal", "timers": "timers", "aut": "int32"},
+	})
+	path_address := device_map[device.Name] + "/" + GetPathFolderName("2")
+	if GetNodeAtAddress(node_ns, path_address+"/modal/motion") == nil || GetNodeAtAddress(node_ns, path_address+"/timers/cycle") == nil {
+		t.Fatal("no field nodes in the path folder")
+	}
+	UpdateCollector(`{"name": "test", "paths": {"2": {"aut": 1, "modal": {"motion": "G01", "t": 12}, "timers": {"cycle": 30.5}}}}`)
+	if value := NodeValue(node_ns, path_address+"/modal/motion"); value != "G01" {
+		t.Fatalf("path_2/modal/motion: %v", value)
+	}
+	if value := NodeValue(node_ns, path_address+"/modal/t"); value != int32(12) {
+		t.Fatalf("path_2/modal/t: %v", value)
+	}
+	if value := NodeValue(node_ns, path_address+"/timers/cycle"); value != 30.5 {
+		t.Fatalf("path_2/timers/cycle: %v", value)
+	}
+	// auto discovered path folder
+	UpdateCollector(`{"name": "test", "paths": {"3": {"modal": {"motion": "G00"}}}}`)
+	if value := NodeValue(node_ns, device_map[device.Name]+"/"+GetPathFolderName("3")+"/modal/motion"); value != "G00" {
+		t.Fatalf("path_3/modal/motion: %v", value)
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/json"
 	"encoding/pem"
 	"fmt"
 	"os"
@@ -127,6 +128,12 @@ func GetZeroValueByTagType(tag_type string) any {
 		return make([]int64, 0)
 	case "[]float64":
 		return make([]float64, 0)
+	case "[]string", "[]json":
+		return make([]string, 0)
+	case "[]bool":
+		return make([]bool, 0)
+	case "json":
+		return ""
 	default:
 		return nil
 	}
@@ -178,6 +185,42 @@ func ConvertValueByType(value any, data_type string) any {
 			}
 			return data
 		}
+	case "[]string":
+		if raw_slice, ok := value.([]interface{}); ok {
+			data := make([]string, 0, len(raw_slice))
+			for _, v := range raw_slice {
+				if str, ok := v.(string); ok {
+					data = append(data, str)
+				}
+			}
+			return data
+		}
+	case "[]bool":
+		if raw_slice, ok := value.([]interface{}); ok {
+			data := make([]bool, 0, len(raw_slice))
+			for _, v := range raw_slice {
+				if flag, ok := v.(bool); ok {
+					data = append(data, flag)
+				}
+			}
+			return data
+		}
+	case "[]json":
+		if raw_slice, ok := value.([]interface{}); ok {
+			data := make([]string, 0, len(raw_slice))
+			for _, v := range raw_slice {
+				if buf, err := json.Marshal(v); err == nil {
+					data = append(data, string(buf))
+				}
+			}
+			return data
+		}
+	case "json":
+		if value != nil {
+			if data, err := json.Marshal(value); err == nil {
+				return string(data)
+			}
+		}
 	}
 	return nil
 }
@@ -207,6 +250,8 @@ func ConvertMapValueAtKey(key string, map_data any, data_type string) any {
 				}
 			}
 			return float64(0)
+		default:
+			return ConvertValueByType(map_data[key], data_type)
 		}
 	}
 	return nil
@@ -381,3 +426,155 @@ func AddPK(key_path string) server.Option {
 	}
 	return server.PrivateKey(private_key)
 }
+
+// field of the folder tag, array of the item values or the number of items
+type FolderTagField struct {
+	Name string
+	Type string
+	Key  string
+}
+
+// tag types of arrays of structures, created as a folder with a node per field
+var folder_tag_types = map[string][]FolderTagField{
+	"alarms": {
+		{Name: "count", Type: "int64"},
+		{Name: "number", Type: "[]int64", Key: "number"},
+		{Name: "type", Type: "[]string", Key: "type_name"},
+		{Name: "axis", Type: "[]int64", Key: "axis"},
+		{Name: "message", Type: "[]string", Key: "message"},
+	},
+	"operator_messages": {
+		{Name: "count", Type: "int64"},
+		{Name: "number", Type: "[]int64", Key: "number"},
+		{Name: "type", Type: "[]int64", Key: "type"},
+		{Name: "message", Type: "[]string", Key: "message"},
+	},
+	"alarm_history": {
+		{Name: "count", Type: "int64"},
+		{Name: "time", Type: "[]string", Key: "time"},
+		{Name: "number", Type: "[]int64", Key: "number"},
+		{Name: "type", Type: "[]string", Key: "type_name"},
+		{Name: "axis", Type: "[]int64", Key: "axis"},
+		{Name: "message", Type: "[]string", Key: "message"},
+	},
+	"programs": {
+		{Name: "count", Type: "int64"},
+		{Name: "number", Type: "[]int64", Key: "number"},
+		{Name: "name", Type: "[]string", Key: "name"},
+		{Name: "comment", Type: "[]string", Key: "comment"},
+		{Name: "size", Type: "[]int64", Key: "size"},
+		{Name: "modified", Type: "[]string", Key: "modified"},
+	},
+	"program_events": {
+		{Name: "count", Type: "int64"},
+		{Name: "event", Type: "[]string", Key: "event"},
+		{Name: "number", Type: "[]int64", Key: "number"},
+		{Name: "name", Type: "[]string", Key: "name"},
+		{Name: "modified", Type: "[]string", Key: "modified"},
+	},
+	"tool_life": {
+		{Name: "count", Type: "int64"},
+		{Name: "group", Type: "[]int64", Key: "group"},
+		{Name: "state", Type: "[]string", Key: "state"},
+		{Name: "life_count", Type: "[]int64", Key: "life_count"},
+		{Name: "life_limit", Type: "[]int64", Key: "life_limit"},
+		{Name: "remaining", Type: "[]int64", Key: "remaining"},
+		{Name: "count_type", Type: "[]string", Key: "count_type"},
+		{Name: "notice", Type: "[]bool", Key: "notice"},
+		{Name: "tool", Type: "[]int64", Key: "tool"},
+		{Name: "tools", Type: "[]json", Key: "tools"},
+	},
+	"exec_window": {
+		{Name: "count", Type: "int64"},
+		{Name: "offset", Type: "[]int64", Key: "offset"},
+		{Name: "block", Type: "[]string", Key: "block"},
+	},
+	"work_offset_events": {
+		{Name: "count", Type: "int64"},
+		{Name: "offset", Type: "[]string", Key: "offset"},
+		{Name: "axis", Type: "[]string", Key: "axis"},
+		{Name: "old", Type: "[]float64", Key: "old"},
+		{Name: "new", Type: "[]float64", Key: "new"},
+	},
+}
+
+// tag type -> value type of nested map tags, folder per key with a variable per inner key
+var nested_tag_types = map[string]string{
+	"work_offsets": "float64",
+	"drive_health": "float64",
+}
+
+func AddTagNode(node_ns *server.NodeNameSpace, node *server.Node, name string, tag_type string) {
+	if _, ok := nested_tag_types[tag_type]; ok {
+		GetFolderNode(node_ns, node, name)
+		return
+	}
+	fields, ok := folder_tag_types[tag_type]
+	if !ok {
+		AddVariableNode(node_ns, node, name, GetZeroValueByTagType(tag_type))
+		return
+	}
+	folder := GetFolderNode(node_ns, node, name)
+	for _, field := range fields {
+		AddVariableNode(node_ns, folder, field.Name, GetZeroValueByTagType(field.Type))
+	}
+}
+
+func UpdateFolderTagNodes(node_ns *server.NodeNameSpace, address string, value any, fields []FolderTagField) {
+	items, ok := value.([]any)
+	if !ok {
+		return
+	}
+	for _, field := range fields {
+		var converted_value any
+		if field.Key == "" {
+			converted_value = int64(len(items))
+		} else {
+			column := make([]any, 0, len(items))
+			for _, item := range items {
+				if item_map, ok := item.(map[string]any); ok {
+					column = append(column, item_map[field.Key])
+				}
+			}
+			converted_value = ConvertValueByType(column, field.Type)
+		}
+		if converted_value != nil {
+			UpdateNodeValueAtAddress(node_ns, address+"/"+field.Name, converted_value)
+		}
+	}
+}
+
+// inner folders and variables are created with the first value of the key
+func UpdateNestedTagNodes(node_ns *server.NodeNameSpace, address string, value any, value_type string) {
+	values, ok := value.(map[string]any)
+	if !ok {
+		return
+	}
+	nodes_mutex.Lock()
+	defer nodes_mutex.Unlock()
+	for key, inner_value := range values {
+		inner_values, ok := inner_value.(map[string]any)
+		if !ok {
+			continue
+		}
+		folder := GetNodeAtAddress(node_ns, address+"/"+key)
+		if folder == nil {
+			parent := GetNodeAtAddress(node_ns, address)
+			if parent == nil {
+				return
+			}
+			folder = GetFolderNode(node_ns, parent, key)
+		}
+		for name, data := range inner_values {
+			converted_value := ConvertValueByType(data, value_type)
+			if converted_value == nil {
+				continue
+			}
+			if GetNodeAtAddress(node_ns, address+"/"+key+"/"+name) == nil {
+				AddVariableNode(node_ns, folder, name, converted_value)
+			} else {
+				UpdateNodeValueAtAddress(node_ns, address+"/"+key+"/"+name, converted_value)
+			}
+		}
+	}
+}
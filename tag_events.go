@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// state of the tags with logged transitions, kept between reconnects
+type TagEvents struct {
+	mutex sync.Mutex
+	// device scope -> alarm key -> alarm
+	active_alarms map[string]map[string]CncAlarmMessage
+}
+
+var tag_events = TagEvents{
+	active_alarms: make(map[string]map[string]CncAlarmMessage),
+}
+
+// device name with path number for the state files
+func GetScopeKey(device *Device, scope string) string {
+	if scope == "" {
+		return device.Name
+	}
+	return device.Name + "/" + scope
+}
+
+// device name with path number for the log messages
+func GetScopeName(device *Device, scope string) string {
+	if scope == "" {
+		return device.Name
+	}
+	return fmt.Sprintf("%s (канал %s)", device.Name, scope)
+}
+
+func TrackTagEvents(device *Device, scope string, tag string, tag_map map[string]any, errors map[string]int16) {
+	if errors[tag] != 0 {
+		return
+	}
+	switch tag {
+	case "alarm_messages":
+		if alarms, ok := tag_map[tag].([]CncAlarmMessage); ok {
+			tag_events.LogAlarmTransitions(GetScopeName(device, scope), alarms)
+		}
+	}
+}
+
+func (events *TagEvents) LogAlarmTransitions(scope_name string, alarms []CncAlarmMessage) {
+	events.mutex.Lock()
+	defer events.mutex.Unlock()
+	previous := events.active_alarms[scope_name]
+	current := make(map[string]CncAlarmMessage)
+	for _, alarm := range alarms {
+		key := fmt.Sprintf("%s/%d", alarm.Code(), alarm.Axis)
+		current[key] = alarm
+		if _, ok := previous[key]; !ok {
+			logger.Printf("Авария %s: %s %s", scope_name, alarm.Code(), alarm.Message)
+		}
+	}
+	for key, alarm := range previous {
+		if _, ok := current[key]; !ok {
+			logger.Printf("Авария снята %s: %s %s", scope_name, alarm.Code(), alarm.Message)
+		}
+	}
+	events.active_alarms[scope_name] = current
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"time"
+)
+
+// group read only once after connect
+const once_group = "once"
+const default_group = "normal"
+
+// tags with new entries only, not repeated from the cache
+var event_tags = []string{"alarm_history", "program_events", "tool_offset_changes", "work_offset_events"}
+
+type CachedTag struct {
+	value any
+	err   int16
+}
+
+// polling schedule of the tag groups, lives for one collector thread
+type TagSchedule struct {
+	tag_groups map[string]string
+	intervals  map[string]time.Duration
+	last_read  map[string]time.Time
+	due        map[string]bool
+	// scope ("" - device, path key) -> tag -> last read result
+	cache map[string]map[string]CachedTag
+}
+
+func GetPollIntervals(device *Device) map[string]int {
+	intervals := map[string]int{
+		"fast":        device.DelayMs,
+		default_group: device.DelayMs,
+		"slow":        10000,
+	}
+	maps.Copy(intervals, device.PollIntervals)
+	return intervals
+}
+
+func GetTagGroups(device *Device) map[string]string {
+	// tags without a group are in the default group
+	tag_groups := make(map[string]string)
+	maps.Copy(tag_groups, config.Server.TagGroups[device.TagsPackName])
+	maps.Copy(tag_groups, device.TagGroups)
+	return tag_groups
+}
+
+func NewTagSchedule(device *Device) *TagSchedule {
+	schedule := &TagSchedule{
+		tag_groups: GetTagGroups(device),
+		intervals:  make(map[string]time.Duration),
+		last_read:  make(map[string]time.Time),
+		due:        make(map[string]bool),
+		cache:      make(map[string]map[string]CachedTag),
+	}
+	for group, interval_ms := range GetPollIntervals(device) {
+		schedule.intervals[group] = time.Duration(interval_ms) * time.Millisecond
+	}
+	return schedule
+}
+
+func (schedule *TagSchedule) Group(tag string) string {
+	if group, ok := schedule.tag_groups[tag]; ok {
+		return group
+	}
+	return default_group
+}
+
+// collector cycle period, the shortest interval of the groups in use
+func (schedule *TagSchedule) Period(tags []string) time.Duration {
+	period := schedule.intervals[default_group]
+	for _, tag := range tags {
+		group := schedule.Group(tag)
+		if group == once_group {
+			continue
+		}
+		if interval := schedule.intervals[group]; interval < period {
+			period = interval
+		}
+	}
+	return period
+}
+
+// select groups to read in this cycle
+func (schedule *TagSchedule) Begin(now time.Time) {
+	clear(schedule.due)
+	for group, interval := range schedule.intervals {
+		last_read, ok := schedule.last_read[group]
+		if !ok || now.Sub(last_read) >= interval {
+			schedule.due[group] = true
+			schedule.last_read[group] = now
+		}
+	}
+	if _, ok := schedule.last_read[once_group]; !ok {
+		schedule.due[once_group] = true
+		schedule.last_read[once_group] = now
+	}
+}
+
+// once tags are read again in the next cycle
+func (schedule *TagSchedule) Reset() {
+	delete(schedule.last_read, once_group)
+}
+
+func (schedule *TagSchedule) IsDue(tag string) bool {
+	return schedule.due[schedule.Group(tag)]
+}
+
+func (schedule *TagSchedule) Store(scope string, tag string, tag_map map[string]any, errors map[string]int16) {
+	// not a data tag
+	if _, ok := errors[tag]; !ok || slices.Contains(event_tags, tag) {
+		return
+	}
+	if schedule.cache[scope] == nil {
+		schedule.cache[scope] = make(map[string]CachedTag)
+	}
+	// connection errors are not repeated in the next cycles, the last value is kept
+	if IsProtocolError(errors[tag]) {
+		if schedule.Group(tag) == once_group {
+			schedule.Reset()
+		}
+		return
+	}
+	schedule.cache[scope][tag] = CachedTag{value: tag_map[tag], err: errors[tag]}
+}
+
+// re-emit the last value of a tag that is not due
+func (schedule *TagSchedule) Restore(scope string, tag string, tag_map map[string]any, errors map[string]int16) {
+	cached, ok := schedule.cache[scope][tag]
+	if !ok {
+		return
+	}
+	tag_map[tag], errors[tag] = cached.value, cached.err
+}
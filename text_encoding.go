@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+var available_text_encodings = []string{
+	"utf-8",
+	"cp1251",
+	"latin1",
+	"jis_x0201",
+}
+
+// upper half of windows-1251
+var cp1251_table = [128]rune{
+	'Ђ', 'Ѓ', '‚', 'ѓ', '„', '…', '†', '‡', '€', '‰', 'Љ', '‹', 'Њ', 'Ќ', 'Ћ', 'Џ',
+	'ђ', '‘', '’', '“', '”', '•', '–', '—', '?', '™', 'љ', '›', 'њ', 'ќ', 'ћ', 'џ',
+	' ', 'Ў', 'ў', 'Ј', '¤', 'Ґ', '¦', '§', 'Ё', '©', 'Є', '«', '¬', '­', '®', 'Ї',
+	'°', '±', 'І', 'і', 'ґ', 'µ', '¶', '·', 'ё', '№', 'є', '»', 'ј', 'Ѕ', 'ѕ', 'ї',
+	'А', 'Б', 'В', 'Г', 'Д', 'Е', 'Ж', 'З', 'И', 'Й', 'К', 'Л', 'М', 'Н', 'О', 'П',
+	'Р', 'С', 'Т', 'У', 'Ф', 'Х', 'Ц', 'Ч', 'Ш', 'Щ', 'Ъ', 'Ы', 'Ь', 'Э', 'Ю', 'Я',
+	'а', 'б', 'в', 'г', 'д', 'е', 'ж', 'з', 'и', 'й', 'к', 'л', 'м', 'н', 'о', 'п',
+	'р', 'с', 'т', 'у', 'ф', 'х', 'ц', 'ч', 'ш', 'щ', 'ъ', 'ы', 'ь', 'э', 'ю', 'я',
+}
+
+// text of CNC messages in the device encoding, up to the first NUL
+func DecodeCncText(data []byte, encoding string) string {
+	if index := strings.IndexByte(string(data), 0); index >= 0 {
+		data = data[:index]
+	}
+	var builder strings.Builder
+	switch encoding {
+	case "cp1251":
+		for _, char := range data {
+			if char < 0x80 {
+				builder.WriteByte(char)
+			} else {
+				builder.WriteRune(cp1251_table[char-0x80])
+			}
+		}
+	case "latin1":
+		for _, char := range data {
+			builder.WriteRune(rune(char))
+		}
+	case "jis_x0201":
+		// half-width katakana of FANUC screens, kanji are not supported
+		for _, char := range data {
+			switch {
+			case char < 0x80:
+				builder.WriteByte(char)
+			case char >= 0xA1 && char <= 0xDF:
+				builder.WriteRune(0xFF61 + rune(char-0xA1))
+			default:
+				builder.WriteRune(utf8.RuneError)
+			}
+		}
+	default:
+		builder.WriteString(strings.ToValidUTF8(string(data), string(utf8.RuneError)))
+	}
+	return strings.TrimSpace(builder.String())
+}
@@ -0,0 +1,73 @@
+package main
+
+// cnc_rdtimer types
+var timer_types = map[string]int16{
+	"power_on":  0,
+	"operating": 1,
+	"cutting":   2,
+	"cycle":     3,
+	"free":      4,
+}
+
+// reads of a timer after the first one until two reads agree
+const timer_read_attempts = 3
+
+// ms from the minute rollover, a read this close may have the minute of the other side
+const timer_rollover_ms = 1000
+
+func (timer CncTimer) Seconds() float64 {
+	return JoinTimeParams(int64(timer.Msec), int64(timer.Minute))
+}
+
+func GetTimerFieldTypes() map[string]string {
+	field_types := make(map[string]string)
+	for name := range timer_types {
+		field_types[name] = "float64"
+	}
+	return field_types
+}
+
+func (timer CncTimer) NearRollover() bool {
+	return timer.Msec < timer_rollover_ms || timer.Msec >= 60000-timer_rollover_ms
+}
+
+// seconds of a consistent read, the timer is re-read only near the minute rollover
+// until the minute stays the same, EW_DATA when it never does
+func ReadTimer(client CNCClient, timer_type int16) (float64, int16) {
+	timer, ret := client.GetTimer(timer_type)
+	if ret != 0 {
+		return 0, ret
+	}
+	for range timer_read_attempts {
+		if !timer.NearRollover() {
+			return timer.Seconds(), 0
+		}
+		next, ret := client.GetTimer(timer_type)
+		if ret != 0 {
+			return 0, ret
+		}
+		// reset or no rollover between the reads
+		if next.Minute == timer.Minute && next.Msec >= 0 && next.Msec < 60000 {
+			return next.Seconds(), 0
+		}
+		timer = next
+	}
+	return 0, EW_DATA
+}
+
+// timer name -> seconds, timers with errors (not supported by the CNC) are null
+func ReadTimers(client CNCClient) (map[string]any, int16) {
+	result := make(map[string]any)
+	for name, timer_type := range timer_types {
+		seconds, ret := ReadTimer(client, timer_type)
+		if IsProtocolError(ret) {
+			return make(map[string]any), ret
+		}
+		if ret != 0 {
+			result[name] = nil
+			continue
+		}
+		result[name] = seconds
+	}
+	return result, 0
+}
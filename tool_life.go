@@ -0,0 +1,98 @@
+package main
+
+// groups per cnc_rdtlgrp call
+const tool_life_group_batch = 16
+
+// tool information of cnc_rdtltool
+var tool_life_states = map[int32]string{
+	0: "unused",
+	1: "in_use",
+	2: "expired",
+	3: "skipped",
+}
+
+// life counter type of cnc_rdtlgrp
+var tool_life_count_types = map[int16]string{
+	0: "cycles",
+	1: "minutes",
+}
+
+// tool_life tag item, state is in_use, expired or available
+type ToolLifeGroup struct {
+	Group     int32          `json:"group"`
+	State     string         `json:"state"`
+	LifeCount int32          `json:"life_count"`
+	LifeLimit int32          `json:"life_limit"`
+	Remaining int32          `json:"remaining"`
+	CountType string         `json:"count_type"`
+	Notice    bool           `json:"notice"`
+	Tool      int32          `json:"tool"`
+	Tools     []ToolLifeTool `json:"tools"`
+}
+
+// state is unused, in_use, expired or skipped
+type ToolLifeTool struct {
+	Number int32  `json:"number"`
+	State  string `json:"state"`
+}
+
+func GetToolLifeState(info int32) string {
+	if state, ok := tool_life_states[info]; ok {
+		return state
+	}
+	return "unused"
+}
+
+// the group is expired when all its tools are expired or skipped
+func GetToolLifeGroupState(info CncToolLifeInfo, group CncToolLifeGroup, tools []ToolLifeTool) string {
+	if group.Group == info.UseGroup {
+		return "in_use"
+	}
+	for _, tool := range tools {
+		if tool.State == "unused" || tool.State == "in_use" {
+			return "available"
+		}
+	}
+	return "expired"
+}
+
+// groups with tools, empty groups are skipped
+func ReadToolLife(client CNCClient) ([]ToolLifeGroup, int16) {
+	result := make([]ToolLifeGroup, 0)
+	info, ret := client.GetToolLifeInfo()
+	if ret != 0 {
+		return result, ret
+	}
+	for start := int32(1); start <= info.MaxGroups; start += tool_life_group_batch {
+		count := int16(min(tool_life_group_batch, info.MaxGroups-start+1))
+		groups, ret := client.GetToolLifeGroups(start, count)
+		if ret != 0 {
+			return make([]ToolLifeGroup, 0), ret
+		}
+		for _, group := range groups {
+			if group.ToolCount == 0 {
+				continue
+			}
+			cnc_tools, ret := client.GetToolLifeTools(group.Group, int16(group.ToolCount))
+			if ret != 0 {
+				return make([]ToolLifeGroup, 0), ret
+			}
+			tools := make([]ToolLifeTool, 0, len(cnc_tools))
+			for _, tool := range cnc_tools {
+				tools = append(tools, ToolLifeTool{Number: tool.Number, State: GetToolLifeState(tool.Info)})
+			}
+			result = append(result, ToolLifeGroup{
+				Group:     group.Group,
+				State:     GetToolLifeGroupState(info, group, tools),
+				LifeCount: group.Count,
+				LifeLimit: group.Life,
+				Remaining: max(group.Life-group.Count, 0),
+				CountType: tool_life_count_types[group.CountType],
+				Notice:    group.RestSignal,
+				Tool:      group.UseTool,
+				Tools:     tools,
+			})
+		}
+	}
+	return result, 0
+}
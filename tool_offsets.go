@@ -0,0 +1,129 @@
+package main
+
+import (
+	"maps"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// offsets per cnc_rdtofsr call
+const tool_offset_batch = 50
+
+// offsets are in the least input increment of IS-B, 0.001 mm
+const tool_offset_decimals = 3
+
+type ToolOffsetField struct {
+	Name string
+	Type int16
+}
+
+// cnc_rdtofsr types of T series
+var lathe_offset_fields = []ToolOffsetField{
+	{Name: "x_wear", Type: 0},
+	{Name: "x_geometry", Type: 1},
+	{Name: "z_wear", Type: 2},
+	{Name: "z_geometry", Type: 3},
+	{Name: "nose_r_wear", Type: 4},
+	{Name: "nose_r_geometry", Type: 5},
+}
+
+// cnc_rdtofsr types of M series by tool offset memory A, B, C
+var mill_offset_fields = map[int16][]ToolOffsetField{
+	0: {
+		{Name: "offset", Type: 0},
+	},
+	1: {
+		{Name: "wear", Type: 0},
+		{Name: "geometry", Type: 1},
+	},
+	2: {
+		{Name: "radius_wear", Type: 0},
+		{Name: "radius_geometry", Type: 1},
+		{Name: "length_wear", Type: 2},
+		{Name: "length_geometry", Type: 3},
+	},
+}
+
+// offset number -> field -> value, last read table of every device scope
+type ToolOffsetTables struct {
+	mutex  sync.Mutex
+	tables map[string]map[string]map[string]float64
+}
+
+var tool_offset_tables = ToolOffsetTables{
+	tables: make(map[string]map[string]map[string]float64),
+}
+
+func IsLathe(mt_type string) bool {
+	return strings.HasPrefix(strings.TrimSpace(mt_type), "T")
+}
+
+func GetToolOffsetFields(info CncToolOffsetInfo) []ToolOffsetField {
+	if IsLathe(info.MtType) {
+		return lathe_offset_fields
+	}
+	return mill_offset_fields[info.Type]
+}
+
+// names of all offset fields for OPC UA nodes
+func GetToolOffsetFieldTypes() map[string]string {
+	field_types := map[string]string{"tool": "int64", "offset_number": "int64"}
+	for _, field := range lathe_offset_fields {
+		field_types[field.Name] = "float64"
+	}
+	for _, fields := range mill_offset_fields {
+		for _, field := range fields {
+			field_types[field.Name] = "float64"
+		}
+	}
+	return field_types
+}
+
+// offset number of the tool, T code of T series is tool and offset numbers
+func GetToolOffsetNumber(info CncToolOffsetInfo, tool_number int64) int64 {
+	if IsLathe(info.MtType) && tool_number > 99 {
+		return tool_number % 100
+	}
+	return tool_number
+}
+
+func ReadToolOffsets(client CNCClient, info CncToolOffsetInfo) (map[string]map[string]float64, int16) {
+	result := make(map[string]map[string]float64)
+	for number := int16(1); number <= info.Count; number++ {
+		result[strconv.Itoa(int(number))] = make(map[string]float64)
+	}
+	scale := math.Pow10(tool_offset_decimals)
+	for _, field := range GetToolOffsetFields(info) {
+		for start := int16(1); start <= info.Count; start += tool_offset_batch {
+			end := min(start+tool_offset_batch-1, info.Count)
+			values, ret := client.GetToolOffsets(field.Type, start, end)
+			if ret != 0 {
+				return make(map[string]map[string]float64), ret
+			}
+			for index, value := range values {
+				result[strconv.Itoa(int(start)+index)][field.Name] = float64(value) / scale
+			}
+		}
+	}
+	return result, 0
+}
+
+// offsets changed since the previous table, the first table is returned whole
+func (tables *ToolOffsetTables) Changes(scope_key string, table map[string]map[string]float64) map[string]map[string]float64 {
+	tables.mutex.Lock()
+	defer tables.mutex.Unlock()
+	previous, ok := tables.tables[scope_key]
+	tables.tables[scope_key] = table
+	if !ok {
+		return table
+	}
+	changes := make(map[string]map[string]float64)
+	for number, offsets := range table {
+		if !maps.Equal(offsets, previous[number]) {
+			changes[number] = offsets
+		}
+	}
+	return changes
+}
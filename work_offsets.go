@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"sync"
+)
+
+// offsets per cnc_rdzofsr call
+const work_offset_batch = 7
+
+// offsets are in the least input increment of IS-B, 0.001 mm
+const work_offset_decimals = 3
+
+// cnc_rdzofs numbers of the external and G54-G59 offsets, G54.1 P1 is 7
+var work_offset_names = []string{"ext", "g54", "g55", "g56", "g57", "g58", "g59"}
+
+// work_offset_events tag item
+type WorkOffsetEvent struct {
+	Offset string  `json:"offset"`
+	Axis   string  `json:"axis"`
+	Old    float64 `json:"old"`
+	New    float64 `json:"new"`
+}
+
+// offset -> axis -> value, last read offsets of every device scope
+type WorkOffsetTables struct {
+	mutex  sync.Mutex
+	tables map[string]map[string]map[string]float64
+}
+
+var work_offset_tables = WorkOffsetTables{
+	tables: make(map[string]map[string]map[string]float64),
+}
+
+// g54, ext or g54_1_p<n>
+func GetWorkOffsetName(number int16) string {
+	if int(number) < len(work_offset_names) {
+		return work_offset_names[number]
+	}
+	return fmt.Sprintf("g54_1_p%d", int(number)-len(work_offset_names)+1)
+}
+
+// the function or the option is absent
+func IsMissingFunction(ret int16) bool {
+	return ret == EW_FUNC || ret == EW_NOOPT
+}
+
+// standard, extended offsets and work coordinate shift by axis names
+func ReadWorkOffsets(client CNCClient) (map[string]map[string]float64, int16) {
+	result := make(map[string]map[string]float64)
+	axes, ret := client.GetAxisNames()
+	if ret != 0 {
+		return result, ret
+	}
+	extended, ret := client.GetWorkOffsetCount()
+	if IsMissingFunction(ret) {
+		extended = 0
+	} else if ret != 0 {
+		return result, ret
+	}
+	axes_count := int16(len(axes))
+	scale := math.Pow10(work_offset_decimals)
+	last := int16(len(work_offset_names)) - 1 + extended
+	for start := int16(0); start <= last; start += work_offset_batch {
+		end := min(start+work_offset_batch-1, last)
+		offsets, ret := client.GetWorkOffsets(start, end, axes_count)
+		if ret != 0 {
+			return make(map[string]map[string]float64), ret
+		}
+		for index, values := range offsets {
+			result[GetWorkOffsetName(start+int16(index))] = GetAxisValues(axes, values, scale)
+		}
+	}
+	shift, ret := client.GetWorkShift(axes_count)
+	if ret == 0 {
+		result["shift"] = GetAxisValues(axes, shift, scale)
+	} else if !IsMissingFunction(ret) {
+		return make(map[string]map[string]float64), ret
+	}
+	return result, 0
+}
+
+func GetAxisValues(axes []string, values []int32, scale float64) map[string]float64 {
+	result := make(map[string]float64)
+	for index, value := range values[:min(len(axes), len(values))] {
+		result[axes[index]] = float64(value) / scale
+	}
+	return result
+}
+
+// changed values since the previous offsets, the first offsets have no events
+func (tables *WorkOffsetTables) Update(scope_key string, table map[string]map[string]float64) []WorkOffsetEvent {
+	tables.mutex.Lock()
+	defer tables.mutex.Unlock()
+	events := make([]WorkOffsetEvent, 0)
+	previous, ok := tables.tables[scope_key]
+	tables.tables[scope_key] = table
+	if !ok {
+		return events
+	}
+	for _, offset := range slices.Sorted(maps.Keys(table)) {
+		old_values, ok := previous[offset]
+		if !ok {
+			continue
+		}
+		for _, axis := range slices.Sorted(maps.Keys(table[offset])) {
+			value := table[offset][axis]
+			if old_value, ok := old_values[axis]; ok && old_value != value {
+				events = append(events, WorkOffsetEvent{Offset: offset, Axis: axis, Old: old_value, New: value})
+			}
+		}
+	}
+	for _, event := range events {
+		logger.Printf("Изменено смещение %s %s %s: %v -> %v", scope_key, event.Offset, event.Axis, event.Old, event.New)
+	}
+	return events
+}